@@ -12,7 +12,7 @@ import (
 var applyManifestPath string
 
 func newApplyCmd() *cobra.Command {
-	var applyDryRun bool
+	var applyDryRun, applyForce bool
 
 	cmd := &cobra.Command{
 		Use:   "apply [target-name...]",
@@ -26,10 +26,34 @@ For 'own' mode targets, the target file is entirely written from the
 concatenated partials (the file is fully managed by Parts).
 
 If target names are specified, only those targets are applied.
-If no target names are specified, all targets are applied.`,
+If no target names are specified, all targets are applied.
+
+Targets and partials with a 'when' condition (host, os, user, env) that
+doesn't hold on this machine are skipped; --dry-run reports why.
+
+Apply is all-or-nothing: every target is rendered before any file is
+written, and each file is written to a temporary file and renamed into
+place. If any target fails, targets already written are rolled back.
+
+A target with 'validate' set is checked before anything is written: by a
+built-in validator (yaml, json, ssh, hosts) or by a command run on a
+temporary copy of the rendered file, e.g. 'sshd -t -f {}'. If validation
+fails, its output is shown and no target is modified.
+
+Hooks run around the write: 'pre_apply' hooks run once every target has
+rendered, and a failing one skips its target (and the targets after it in
+the same file) while the others are written; 'post_apply' hooks run after
+all files are written. Hooks with 'changed_only: true' run only for targets
+whose content changes.
+
+A target with 'checksum: true' records a hash of what apply wrote, along
+with the parts version and the time, in a comment line of the managed
+content. Apply refuses to overwrite content that was edited since: run
+'parts sync' to keep the edits, or pass --force to discard them.`,
 		Example: `  parts apply            # Apply all targets
   parts apply ssh        # Apply only the 'ssh' target
-  parts apply --dry-run  # Preview changes without modifying files`,
+  parts apply --dry-run  # Preview changes without modifying files
+  parts apply --force    # Overwrite hand edits to checksummed targets`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			manifestPath := applyManifestPath
 			if manifestPath == "" {
@@ -51,55 +75,156 @@ If no target names are specified, all targets are applied.`,
 				return err
 			}
 
+			report := newReporter(cmd, "apply", applyDryRun)
+			defer report.flush()
+			messages := messageWriter(cmd)
+
 			var errors []error
+			var planned []plannedTarget
+
+			// Render every target before touching any file. Targets sharing a
+			// file are rendered on the content planned before them.
+			plannedFS := src.NewPlannedFS(nil)
+			facts := src.CurrentFacts()
 			for _, name := range names {
 				target := manifest.ResolvedTarget(name)
+				// Targets named explicitly are reported even outside dry-run
+				if skipTarget(report, name, target, facts, applyDryRun || len(args) > 0) {
+					continue
+				}
 
-				switch target.Mode {
-				case "merge":
-					// NewPartialsBuildCommand handles tilde expansion internally
-					buildCmd, buildErr := src.NewPartialsBuildCommand(target.Target, target.Partials, target.Comment)
-					if buildErr != nil {
-						errors = append(errors, fmt.Errorf("target '%s': %w", name, buildErr))
-						continue
-					}
-					buildCmd.SetDryRun(applyDryRun)
-					if runErr := buildCmd.Run(); runErr != nil {
-						errors = append(errors, fmt.Errorf("target '%s': %w", name, runErr))
-					}
+				backups, backupErr := targetBackupStore(manifest, target)
+				if backupErr != nil {
+					errors = append(errors, report.failed(name, target, backupErr))
+					continue
+				}
 
-				case "own":
-					// Own mode needs manual tilde expansion
-					expandedTarget, expandErr := src.ExpandTildePrefix(target.Target)
-					if expandErr != nil {
-						errors = append(errors, fmt.Errorf("target '%s': %w", name, expandErr))
-						continue
-					}
-					expandedPartials, expandErr := src.ExpandTildePrefix(target.Partials)
-					if expandErr != nil {
-						errors = append(errors, fmt.Errorf("target '%s': %w", name, expandErr))
+				targetCmd, cmdErr := newTargetCommand(target)
+				if cmdErr != nil {
+					errors = append(errors, report.failed(name, target, cmdErr))
+					continue
+				}
+				targetCmd.SetFS(plannedFS)
+				targetCmd.SetOutput(messages)
+				targetCmd.SetForce(applyForce)
+
+				change, planErr := targetCmd.Plan()
+				if planErr != nil {
+					errors = append(errors, report.failed(name, target, planErr))
+					continue
+				}
+
+				if applyDryRun {
+					targetCmd.SetDryRun(true)
+					if runErr := targetCmd.Run(); runErr != nil {
+						errors = append(errors, report.failed(name, target, runErr))
 						continue
 					}
+					plannedFS.Plan(change)
+					report.add(appliedReport(report, name, target, change))
+					previewHooks(cmd, name, target, src.HookPreApply, src.HookPostApply)
+					continue
+				}
 
-					ownCmd := src.NewPartialsOwnCommand(expandedTarget, expandedPartials, target.Comment)
-					ownCmd.SetDryRun(applyDryRun)
-					if runErr := ownCmd.Run(); runErr != nil {
-						errors = append(errors, fmt.Errorf("target '%s': %w", name, runErr))
-					}
+				if validateErr := targetCmd.Validate(change); validateErr != nil {
+					errors = append(errors, report.failed(name, target, validateErr))
+					continue
+				}
+				if editErr := targetCmd.CheckEdits(change); editErr != nil {
+					errors = append(errors, report.failed(name, target, editErr))
+					continue
 				}
+				plannedFS.Plan(change)
+				planned = append(planned, plannedTarget{name: name, target: target, change: change, backups: backups})
 			}
 
 			if len(errors) > 0 {
 				for _, e := range errors {
 					fmt.Fprintf(cmd.ErrOrStderr(), "Error: %v\n", e)
 				}
+				if !applyDryRun {
+					fmt.Fprintln(cmd.ErrOrStderr(), "No targets were modified")
+				}
 				return fmt.Errorf("%d target(s) failed", len(errors))
 			}
 
-			return nil
+			// A failing pre_apply hook skips its target, along with the targets
+			// rendered on top of it in the same file
+			var writes []plannedTarget
+			var skipped []error
+			failedPaths := map[string]string{}
+			for _, p := range planned {
+				path := filepath.Clean(p.change.Path)
+				if failed, ok := failedPaths[path]; ok {
+					skipped = append(skipped, report.failed(p.name, p.target, fmt.Errorf("not modified: target '%s' failed on the same file", failed)))
+					continue
+				}
+				if hookErr := runHook(cmd, manifest, p.name, p.target, src.HookPreApply, p.change.Path, p.change.Changed()); hookErr != nil {
+					skipped = append(skipped, report.failed(p.name, p.target, hookErr))
+					failedPaths[path] = p.name
+					continue
+				}
+				writes = append(writes, p)
+			}
+			for _, e := range skipped {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Error: %v\n", e)
+			}
+
+			// Write all targets as one transaction: a failure rolls back the others
+			var tx src.Transaction
+			for _, p := range writes {
+				tx.Add(p.change, p.backups)
+			}
+			if err := tx.Commit(); err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Error: %v\n", err)
+				for _, p := range writes {
+					report.failed(p.name, p.target, fmt.Errorf("rolled back: %w", err))
+				}
+				return fmt.Errorf("apply failed, all targets were rolled back")
+			}
+			var appliedNames []string
+			for _, p := range writes {
+				fmt.Fprintln(messages, appliedMessage(p.target, p.change))
+				report.add(appliedReport(report, p.name, p.target, p.change))
+				appliedNames = append(appliedNames, p.name)
+			}
+			recordTargets(cmd, manifest, appliedNames, "apply")
+
+			// Files are written by now; a failing post_apply hook only fails the command
+			var hookErrors []error
+			for _, p := range writes {
+				if hookErr := runHook(cmd, manifest, p.name, p.target, src.HookPostApply, p.change.Path, p.change.Changed()); hookErr != nil {
+					hookErrors = append(hookErrors, fmt.Errorf("target '%s': %w", p.name, hookErr))
+				}
+			}
+			hookErr := hooksFailed(cmd, hookErrors)
+			if len(skipped) > 0 {
+				return fmt.Errorf("%d target(s) failed", len(skipped))
+			}
+			return hookErr
 		},
 	}
 
 	cmd.Flags().BoolVarP(&applyDryRun, "dry-run", "n", false, "preview changes without modifying files")
+	cmd.Flags().BoolVar(&applyForce, "force", false, "overwrite managed content that was edited by hand")
 	return cmd
 }
+
+// appliedReport describes a rendered target, written or previewed
+func appliedReport(report *reporter, name string, target src.TargetConfig, change *src.FileChange) targetReport {
+	result := report.newReport(name, target)
+	result.Changed = change.Changed()
+	for _, path := range change.Partials {
+		result.Partials = append(result.Partials, reportPath("", path))
+	}
+	result.Bytes = len(change.Content)
+	return result
+}
+
+// plannedTarget is a rendered target waiting to be written
+type plannedTarget struct {
+	name    string
+	target  src.TargetConfig
+	change  *src.FileChange
+	backups *src.BackupStore
+}
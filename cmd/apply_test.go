@@ -1,10 +1,14 @@
 package cmd
 
 import (
+	"bytes"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/cageis/parts/src"
 )
 
 func TestApplyCommand_MergeMode(t *testing.T) {
@@ -219,3 +223,467 @@ func TestApplyCommand_DryRun(t *testing.T) {
 		t.Error("File should not be modified in dry-run mode")
 	}
 }
+
+func TestApplyCommand_FailedTargetModifiesNothing(t *testing.T) {
+	dir := t.TempDir()
+
+	partialsDir := filepath.Join(dir, "ssh")
+	if err := os.MkdirAll(partialsDir, 0755); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(partialsDir, "work"), []byte("Host work\n"), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	targetFile := filepath.Join(dir, "ssh-config")
+	originalContent := "# Original\n"
+	if err := os.WriteFile(targetFile, []byte(originalContent), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	// 'broken' fails to render because its partials directory is missing
+	manifest := `targets:
+  ssh:
+    target: ` + targetFile + `
+    partials: ` + partialsDir + `
+    comment: "#"
+  broken:
+    target: ` + filepath.Join(dir, "other") + `
+    partials: ` + filepath.Join(dir, "missing") + `
+    mode: own
+`
+	manifestPath := filepath.Join(dir, ".parts.yaml")
+	if err := os.WriteFile(manifestPath, []byte(manifest), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	cmd := newApplyCmd()
+	cmd.SetArgs([]string{})
+	cmd.SetErr(&strings.Builder{})
+	applyManifestPath = manifestPath
+	defer func() { applyManifestPath = "" }()
+
+	if err := cmd.Execute(); err == nil {
+		t.Fatal("Expected apply to fail")
+	}
+
+	result, _ := os.ReadFile(targetFile)
+	if string(result) != originalContent {
+		t.Errorf("No target should be written when one fails, got %q", result)
+	}
+}
+
+func TestApplyCommand_RollsBackOnWriteFailure(t *testing.T) {
+	dir := t.TempDir()
+
+	partialsDir := filepath.Join(dir, "ssh")
+	if err := os.MkdirAll(partialsDir, 0755); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(partialsDir, "work"), []byte("Host work\n"), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	targetFile := filepath.Join(dir, "ssh-config")
+	originalContent := "# Original\n"
+	if err := os.WriteFile(targetFile, []byte(originalContent), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	// 'b-own' renders fine but cannot be written: its parent is a regular file
+	blocker := filepath.Join(dir, "blocker")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	manifest := `targets:
+  a-ssh:
+    target: ` + targetFile + `
+    partials: ` + partialsDir + `
+    comment: "#"
+  b-own:
+    target: ` + filepath.Join(blocker, "vimrc") + `
+    partials: ` + partialsDir + `
+    mode: own
+`
+	manifestPath := filepath.Join(dir, ".parts.yaml")
+	if err := os.WriteFile(manifestPath, []byte(manifest), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	cmd := newApplyCmd()
+	cmd.SetArgs([]string{})
+	cmd.SetErr(&strings.Builder{})
+	applyManifestPath = manifestPath
+	defer func() { applyManifestPath = "" }()
+
+	err := cmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "rolled back") {
+		t.Fatalf("Expected rollback error, got %v", err)
+	}
+
+	result, _ := os.ReadFile(targetFile)
+	if string(result) != originalContent {
+		t.Errorf("Expected a-ssh to be rolled back, got %q", result)
+	}
+}
+
+func TestApplyCommand_SharedFileSections(t *testing.T) {
+	dir := t.TempDir()
+
+	personalDir := filepath.Join(dir, "personal")
+	teamDir := filepath.Join(dir, "team")
+	for partialsDir, host := range map[string]string{personalDir: "Host personal\n", teamDir: "Host team\n"} {
+		if err := os.MkdirAll(partialsDir, 0755); err != nil {
+			t.Fatalf("Failed: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(partialsDir, "hosts"), []byte(host), 0644); err != nil {
+			t.Fatalf("Failed: %v", err)
+		}
+	}
+
+	targetFile := filepath.Join(dir, "ssh-config")
+	if err := os.WriteFile(targetFile, []byte("# My config\n"), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	manifest := `defaults:
+  comment: "#"
+targets:
+  personal:
+    target: ` + targetFile + `
+    partials: ` + personalDir + `
+    section: personal
+  team:
+    target: ` + targetFile + `
+    partials: ` + teamDir + `
+    section: team
+`
+	manifestPath := filepath.Join(dir, ".parts.yaml")
+	if err := os.WriteFile(manifestPath, []byte(manifest), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	applyManifestPath = manifestPath
+	defer func() { applyManifestPath = "" }()
+	for _, name := range []string{"personal", "team", "personal"} {
+		cmd := newApplyCmd()
+		cmd.SetArgs([]string{name})
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("Apply %s failed: %v", name, err)
+		}
+	}
+
+	result, _ := os.ReadFile(targetFile)
+	resultStr := string(result)
+	if strings.Count(resultStr, "Host personal") != 1 || strings.Count(resultStr, "Host team") != 1 {
+		t.Errorf("Expected both sections exactly once, got:\n%s", resultStr)
+	}
+	if !strings.Contains(resultStr, "# PARTIALS>>>>> personal") || !strings.Contains(resultStr, "# PARTIALS>>>>> team") {
+		t.Errorf("Expected named markers, got:\n%s", resultStr)
+	}
+
+	// One apply of both targets renders the second on top of the first
+	if err := os.WriteFile(targetFile, []byte("# My config\n"), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	cmd := newApplyCmd()
+	var stderr bytes.Buffer
+	cmd.SetErr(&stderr)
+	cmd.SetArgs([]string{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if strings.Contains(stderr.String(), "Warning") {
+		t.Errorf("Expected no warnings, got:\n%s", stderr.String())
+	}
+	result, _ = os.ReadFile(targetFile)
+	resultStr = string(result)
+	if strings.Count(resultStr, "Host personal") != 1 || strings.Count(resultStr, "Host team") != 1 {
+		t.Errorf("Expected both sections from one apply, got:\n%s", resultStr)
+	}
+
+	loaded, err := src.LoadManifest(manifestPath)
+	if err != nil {
+		t.Fatalf("Failed to load manifest: %v", err)
+	}
+	state, err := loaded.LoadState()
+	if err != nil {
+		t.Fatalf("Failed to load state: %v", err)
+	}
+	for _, name := range []string{"personal", "team"} {
+		if recorded, ok := state.Target(name); !ok || recorded.SectionHash == "" || len(recorded.Partials) != 1 {
+			t.Errorf("Expected state of '%s' with its section, got %+v", name, recorded)
+		}
+	}
+}
+
+func TestApplyCommand_TemplateTarget(t *testing.T) {
+	dir := t.TempDir()
+	partialsDir := filepath.Join(dir, "git")
+	if err := os.MkdirAll(partialsDir, 0755); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	partial := "[user]\n    name = {{ .Vars.name }}\n    email = {{ .Vars.email }}\n"
+	if err := os.WriteFile(filepath.Join(partialsDir, "user"), []byte(partial), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	targetFile := filepath.Join(dir, "gitconfig")
+
+	manifest := `defaults:
+  vars:
+    name: Alice
+    email: alice@home.example
+targets:
+  git:
+    target: ` + targetFile + `
+    partials: ` + partialsDir + `
+    mode: own
+    template: true
+    vars:
+      email: alice@work.example
+`
+	manifestPath := filepath.Join(dir, ".parts.yaml")
+	if err := os.WriteFile(manifestPath, []byte(manifest), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	cmd := newApplyCmd()
+	cmd.SetArgs([]string{})
+	applyManifestPath = manifestPath
+	defer func() { applyManifestPath = "" }()
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	result, _ := os.ReadFile(targetFile)
+	expected := "[user]\n    name = Alice\n    email = alice@work.example\n"
+	if !strings.HasSuffix(string(result), expected) {
+		t.Errorf("Expected rendered partial %q, got %q", expected, result)
+	}
+}
+
+func TestApplyCommand_WhenSkipsTargets(t *testing.T) {
+	dir := t.TempDir()
+	partialsDir := filepath.Join(dir, "ssh")
+	if err := os.MkdirAll(partialsDir, 0755); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(partialsDir, "work"), []byte("Host work\n"), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	here := filepath.Join(dir, "here")
+	elsewhere := filepath.Join(dir, "elsewhere")
+	for _, f := range []string{here, elsewhere} {
+		if err := os.WriteFile(f, []byte("# config\n"), 0644); err != nil {
+			t.Fatalf("Failed: %v", err)
+		}
+	}
+
+	t.Setenv("PARTS_TEST_ROLE", "dev")
+	manifest := `targets:
+  here:
+    target: ` + here + `
+    partials: ` + partialsDir + `
+    when:
+      env: PARTS_TEST_ROLE=dev
+  elsewhere:
+    target: ` + elsewhere + `
+    partials: ` + partialsDir + `
+    when:
+      env: PARTS_TEST_ROLE=ci
+`
+	manifestPath := filepath.Join(dir, ".parts.yaml")
+	if err := os.WriteFile(manifestPath, []byte(manifest), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	cmd := newApplyCmd()
+	cmd.SetArgs([]string{})
+	applyManifestPath = manifestPath
+	defer func() { applyManifestPath = "" }()
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	applied, _ := os.ReadFile(here)
+	if !strings.Contains(string(applied), "Host work") {
+		t.Errorf("Expected matching target to be applied:\n%s", applied)
+	}
+	skipped, _ := os.ReadFile(elsewhere)
+	if string(skipped) != "# config\n" {
+		t.Errorf("Expected non-matching target to be left alone:\n%s", skipped)
+	}
+}
+
+func TestApplyCommand_ValidationFailureModifiesNothing(t *testing.T) {
+	dir := t.TempDir()
+
+	partialsDir := filepath.Join(dir, "ssh")
+	if err := os.MkdirAll(partialsDir, 0755); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(partialsDir, "work"), []byte("Host work\n  HostNmae work.example.com\n"), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	sshConfig := filepath.Join(dir, "ssh-config")
+	gitConfig := filepath.Join(dir, "gitconfig")
+	for _, f := range []string{sshConfig, gitConfig} {
+		if err := os.WriteFile(f, []byte("# Original\n"), 0644); err != nil {
+			t.Fatalf("Failed: %v", err)
+		}
+	}
+
+	manifest := `targets:
+  ssh:
+    target: ` + sshConfig + `
+    partials: ` + partialsDir + `
+    comment: "#"
+    validate: ssh
+  git:
+    target: ` + gitConfig + `
+    partials: ` + partialsDir + `
+    comment: "#"
+`
+	manifestPath := filepath.Join(dir, ".parts.yaml")
+	if err := os.WriteFile(manifestPath, []byte(manifest), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	var stderr strings.Builder
+	cmd := newApplyCmd()
+	cmd.SetArgs([]string{})
+	cmd.SetErr(&stderr)
+	applyManifestPath = manifestPath
+	defer func() { applyManifestPath = "" }()
+
+	if err := cmd.Execute(); err == nil {
+		t.Fatal("Expected apply to fail validation")
+	}
+	if !strings.Contains(stderr.String(), "line 7: unknown keyword 'HostNmae'") {
+		t.Errorf("Expected the validator output, got:\n%s", stderr.String())
+	}
+	for _, f := range []string{sshConfig, gitConfig} {
+		if content, _ := os.ReadFile(f); string(content) != "# Original\n" {
+			t.Errorf("Expected '%s' to be left alone, got %q", f, content)
+		}
+	}
+}
+
+func TestApplyCommand_ChecksumRefusesHandEdits(t *testing.T) {
+	dir := t.TempDir()
+	partialsDir := filepath.Join(dir, "ssh")
+	if err := os.MkdirAll(partialsDir, 0755); err != nil {
+		t.Fatalf("Failed to create partials dir: %v", err)
+	}
+	partial := filepath.Join(partialsDir, "work")
+	if err := os.WriteFile(partial, []byte("Host work\n"), 0644); err != nil {
+		t.Fatalf("Failed to create partial: %v", err)
+	}
+	targetFile := filepath.Join(dir, "ssh-config")
+	if err := os.WriteFile(targetFile, []byte("# My SSH config\n"), 0644); err != nil {
+		t.Fatalf("Failed to create target: %v", err)
+	}
+	manifest := `defaults:
+  checksum: true
+targets:
+  ssh:
+    target: ` + targetFile + `
+    partials: ` + partialsDir + `
+    comment: "#"
+`
+	manifestPath := filepath.Join(dir, ".parts.yaml")
+	if err := os.WriteFile(manifestPath, []byte(manifest), 0644); err != nil {
+		t.Fatalf("Failed to create manifest: %v", err)
+	}
+	applyManifestPath = manifestPath
+	defer func() { applyManifestPath = "" }()
+
+	apply := func(args ...string) error {
+		cmd := newApplyCmd()
+		cmd.SetArgs(args)
+		cmd.SetOut(io.Discard)
+		cmd.SetErr(io.Discard)
+		return cmd.Execute()
+	}
+	if err := apply(); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	content, _ := os.ReadFile(targetFile)
+	edited := strings.Replace(string(content), "Host work", "Host edited", 1)
+	if err := os.WriteFile(targetFile, []byte(edited), 0644); err != nil {
+		t.Fatalf("Failed to edit target: %v", err)
+	}
+	if err := os.WriteFile(partial, []byte("Host work2\n"), 0644); err != nil {
+		t.Fatalf("Failed to edit partial: %v", err)
+	}
+
+	if err := apply(); err == nil {
+		t.Fatal("Apply should refuse to overwrite hand edits")
+	}
+	if content, _ := os.ReadFile(targetFile); string(content) != edited {
+		t.Error("A refused apply must not modify the target")
+	}
+
+	if err := apply("--force"); err != nil {
+		t.Fatalf("Forced apply failed: %v", err)
+	}
+	if content, _ := os.ReadFile(targetFile); !strings.Contains(string(content), "Host work2") {
+		t.Error("A forced apply should overwrite the edits")
+	}
+}
+
+func TestApplyCommand_EscapeMarkers(t *testing.T) {
+	dir := t.TempDir()
+	partialsDir := filepath.Join(dir, "ssh")
+	if err := os.MkdirAll(partialsDir, 0755); err != nil {
+		t.Fatalf("Failed to create partials dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(partialsDir, "notes"), []byte("# Source: copied from elsewhere\nHost notes\n"), 0644); err != nil {
+		t.Fatalf("Failed to create partial: %v", err)
+	}
+	targetFile := filepath.Join(dir, "ssh-config")
+	if err := os.WriteFile(targetFile, []byte("# My SSH config\n"), 0644); err != nil {
+		t.Fatalf("Failed to create target: %v", err)
+	}
+	manifestPath := filepath.Join(dir, ".parts.yaml")
+	writeManifest := func(escape string) {
+		manifest := `targets:
+  ssh:
+    target: ` + targetFile + `
+    partials: ` + partialsDir + `
+    comment: "#"
+` + escape
+		if err := os.WriteFile(manifestPath, []byte(manifest), 0644); err != nil {
+			t.Fatalf("Failed to create manifest: %v", err)
+		}
+	}
+	applyManifestPath = manifestPath
+	defer func() { applyManifestPath = "" }()
+
+	var stderr bytes.Buffer
+	apply := func() error {
+		cmd := newApplyCmd()
+		cmd.SetArgs([]string{})
+		cmd.SetOut(io.Discard)
+		cmd.SetErr(&stderr)
+		return cmd.Execute()
+	}
+
+	writeManifest("")
+	if err := apply(); err == nil || !strings.Contains(stderr.String(), "escape_markers") {
+		t.Fatalf("Expected the colliding line to be refused, got %v:\n%s", err, stderr.String())
+	}
+
+	writeManifest("    escape_markers: true\n")
+	if err := apply(); err != nil {
+		t.Fatalf("Apply with escaping failed: %v", err)
+	}
+	if content, _ := os.ReadFile(targetFile); !strings.Contains(string(content), "# PARTIALS-ESCAPE Source: copied from elsewhere\n") {
+		t.Errorf("Expected the line to be escaped, got:\n%s", content)
+	}
+}
@@ -0,0 +1,316 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/cageis/parts/src"
+	"github.com/spf13/cobra"
+)
+
+// backupsManifestPath allows tests to override the manifest location
+var backupsManifestPath string
+
+func newBackupsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "backups",
+		Short: "List, inspect, restore and prune file backups",
+		Long: `Parts keeps a timestamped copy of a file before apply, remove or sync
+modify it when 'backup: true' is set in .parts.yaml (or --backup is passed
+to the legacy command).
+
+Backups are stored under $XDG_STATE_HOME/parts/backups (default
+~/.local/state/parts/backups) unless 'backup_dir' is set in the manifest
+defaults. Only the newest 'backup_keep' copies of each file are kept.
+
+Arguments may be manifest target names or file paths.`,
+		Example: `  parts backups list                  # List all backups
+  parts backups list ssh              # List backups of the 'ssh' target
+  parts backups show ssh              # Print the newest backup of 'ssh'
+  parts backups restore ssh           # Restore the newest backup of 'ssh'
+  parts backups restore ~/.vimrc 20260216T101500.000000000Z
+  parts backups prune --keep 3        # Keep only 3 backups per file`,
+	}
+
+	cmd.AddCommand(newBackupsListCmd())
+	cmd.AddCommand(newBackupsShowCmd())
+	cmd.AddCommand(newBackupsRestoreCmd())
+	cmd.AddCommand(newBackupsPruneCmd())
+	return cmd
+}
+
+func newBackupsListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list [target-or-file...]",
+		Short: "List stored backups, newest first",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			manifest, store, err := loadBackupStore()
+			if err != nil {
+				return err
+			}
+
+			var backups []src.Backup
+			if len(args) == 0 {
+				backups, err = store.ListAll()
+				if err != nil {
+					return err
+				}
+			}
+			for _, arg := range args {
+				path, resolveErr := resolveBackupPath(manifest, arg)
+				if resolveErr != nil {
+					return resolveErr
+				}
+				pathBackups, listErr := store.List(path)
+				if listErr != nil {
+					return listErr
+				}
+				backups = append(backups, pathBackups...)
+			}
+
+			if len(backups) == 0 {
+				fmt.Fprintf(cmd.OutOrStdout(), "No backups found in '%s'\n", store.Dir())
+				return nil
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tSIZE\tPATH")
+			for _, b := range backups {
+				fmt.Fprintf(w, "%s\t%d\t%s\n", b.ID, b.Size, b.Path)
+			}
+			return w.Flush()
+		},
+	}
+}
+
+func newBackupsShowCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "show <target-or-file> [backup-id]",
+		Short: "Print the content of a backup (newest if no ID is given)",
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			manifest, store, err := loadBackupStore()
+			if err != nil {
+				return err
+			}
+			path, err := resolveBackupPath(manifest, args[0])
+			if err != nil {
+				return err
+			}
+
+			backup, err := store.Get(path, backupIDArg(args))
+			if err != nil {
+				return err
+			}
+			content, err := store.Read(backup)
+			if err != nil {
+				return err
+			}
+
+			_, err = cmd.OutOrStdout().Write(content)
+			return err
+		},
+	}
+}
+
+func newBackupsRestoreCmd() *cobra.Command {
+	var restoreDryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "restore <target-or-file> [backup-id]",
+		Short: "Restore a file from a backup (newest if no ID is given)",
+		Long: `Writes the selected backup back to its original location.
+
+The current content of the file is backed up first, so a restore can itself
+be undone with another restore.`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			manifest, store, err := loadBackupStore()
+			if err != nil {
+				return err
+			}
+			path, err := resolveBackupPath(manifest, args[0])
+			if err != nil {
+				return err
+			}
+
+			if restoreDryRun {
+				backup, getErr := store.Get(path, backupIDArg(args))
+				if getErr != nil {
+					return getErr
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "DRY RUN: Would restore '%s' from backup %s\n", backup.Path, backup.ID)
+				return nil
+			}
+
+			backup, err := store.Restore(path, backupIDArg(args))
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Restored '%s' from backup %s\n", backup.Path, backup.ID)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&restoreDryRun, "dry-run", "n", false, "preview the restore without modifying files")
+	return cmd
+}
+
+func newBackupsPruneCmd() *cobra.Command {
+	var keep int
+	var pruneDryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "prune [target-or-file...]",
+		Short: "Delete old backups, keeping the newest copies of each file",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			manifest, store, err := loadBackupStore()
+			if err != nil {
+				return err
+			}
+			if cmd.Flags().Changed("keep") {
+				if keep < 0 {
+					return fmt.Errorf("--keep must not be negative, got %d", keep)
+				}
+			} else if keep = store.Keep(); keep < 0 {
+				// A negative backup_keep keeps every backup
+				fmt.Fprintln(cmd.OutOrStdout(), "Nothing to prune (backup_keep keeps all backups)")
+				return nil
+			}
+
+			paths, err := backupPaths(manifest, store, args)
+			if err != nil {
+				return err
+			}
+
+			total := 0
+			for _, path := range paths {
+				var removed []src.Backup
+				if pruneDryRun {
+					backups, listErr := store.List(path)
+					if listErr != nil {
+						return listErr
+					}
+					if len(backups) > keep {
+						removed = backups[keep:]
+					}
+				} else {
+					removed, err = store.Prune(path, keep)
+					if err != nil {
+						return err
+					}
+				}
+				for _, b := range removed {
+					if pruneDryRun {
+						fmt.Fprintf(cmd.OutOrStdout(), "DRY RUN: Would delete backup %s of '%s'\n", b.ID, b.Path)
+					} else {
+						fmt.Fprintf(cmd.OutOrStdout(), "Deleted backup %s of '%s'\n", b.ID, b.Path)
+					}
+				}
+				total += len(removed)
+			}
+
+			if total == 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), "Nothing to prune")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVar(&keep, "keep", src.DefaultBackupKeep, "number of backups to keep per file (default: manifest backup_keep)")
+	cmd.Flags().BoolVarP(&pruneDryRun, "dry-run", "n", false, "preview which backups would be deleted")
+	return cmd
+}
+
+// loadBackupStore returns the manifest (nil when there is none) and the backup
+// store it configures, or the default store when no manifest exists
+func loadBackupStore() (*src.Manifest, *src.BackupStore, error) {
+	manifestPath := backupsManifestPath
+	if manifestPath == "" {
+		manifestPath = resolveManifestPath()
+	}
+
+	if _, err := os.Stat(manifestPath); os.IsNotExist(err) {
+		store, storeErr := src.NewBackupStore("", src.DefaultBackupKeep)
+		return nil, store, storeErr
+	}
+
+	absManifest, err := filepath.Abs(manifestPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to resolve manifest path: %w", err)
+	}
+	manifest, err := src.LoadManifest(absManifest)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	store, err := manifest.BackupStore()
+	if err != nil {
+		return nil, nil, err
+	}
+	return manifest, store, nil
+}
+
+// resolveBackupPath maps a manifest target name or a file path to an absolute file path
+func resolveBackupPath(manifest *src.Manifest, arg string) (string, error) {
+	path := arg
+	if manifest != nil {
+		if _, exists := manifest.Targets[arg]; exists {
+			path = manifest.ResolvedTarget(arg).Target
+		}
+	}
+
+	expanded, err := src.ExpandTildePrefix(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(expanded)
+}
+
+// backupPaths resolves the given arguments, or every path in the store if none are given
+func backupPaths(manifest *src.Manifest, store *src.BackupStore, args []string) ([]string, error) {
+	if len(args) > 0 {
+		paths := make([]string, 0, len(args))
+		for _, arg := range args {
+			path, err := resolveBackupPath(manifest, arg)
+			if err != nil {
+				return nil, err
+			}
+			paths = append(paths, path)
+		}
+		return paths, nil
+	}
+
+	all, err := store.ListAll()
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	seen := make(map[string]bool)
+	for _, b := range all {
+		if !seen[b.Path] {
+			seen[b.Path] = true
+			paths = append(paths, b.Path)
+		}
+	}
+	return paths, nil
+}
+
+// backupIDArg returns the optional backup ID argument
+func backupIDArg(args []string) string {
+	if len(args) > 1 {
+		return args[1]
+	}
+	return ""
+}
+
+// targetBackupStore returns the manifest's backup store when backups are
+// enabled for the target, or nil when they are not
+func targetBackupStore(manifest *src.Manifest, target src.TargetConfig) (*src.BackupStore, error) {
+	if target.Backup == nil || !*target.Backup {
+		return nil, nil
+	}
+	return manifest.BackupStore()
+}
@@ -0,0 +1,231 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// manifestFixture holds the YAML a test adds to the manifest writeManifest writes
+type manifestFixture struct {
+	defaults string // lines under 'defaults:', indented by two spaces
+	target   string // lines under the 'ssh' target, indented by four spaces
+	targets  string // further targets, indented by two spaces
+}
+
+// writeManifest writes dir/.parts.yaml with a merge target 'ssh' that builds
+// dir/ssh/work into dir/ssh-config, plus whatever the fixture adds
+func writeManifest(t *testing.T, dir string, fixture manifestFixture) (manifestPath, targetFile, partialsDir string) {
+	t.Helper()
+	partialsDir = filepath.Join(dir, "ssh")
+	if err := os.MkdirAll(partialsDir, 0755); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(partialsDir, "work"), []byte("Host work\n"), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	targetFile = filepath.Join(dir, "ssh-config")
+	if err := os.WriteFile(targetFile, []byte("# Original\n"), 0600); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	manifest := "defaults:\n" + fixture.defaults + `targets:
+  ssh:
+    target: ` + targetFile + `
+    partials: ` + partialsDir + `
+    comment: "#"
+` + fixture.target + fixture.targets
+	manifestPath = filepath.Join(dir, ".parts.yaml")
+	if err := os.WriteFile(manifestPath, []byte(manifest), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	return manifestPath, targetFile, partialsDir
+}
+
+// writeBackupManifest writes the test manifest with backups enabled
+func writeBackupManifest(t *testing.T, dir string) (manifestPath, targetFile, partialsDir string) {
+	t.Helper()
+	return writeManifest(t, dir, manifestFixture{
+		defaults: "  backup: true\n  backup_dir: " + filepath.Join(dir, "backups") + "\n",
+	})
+}
+
+func TestApplyCommand_CreatesBackup(t *testing.T) {
+	dir := t.TempDir()
+	manifestPath, targetFile, _ := writeBackupManifest(t, dir)
+
+	applyCmd := newApplyCmd()
+	applyCmd.SetArgs([]string{})
+	applyManifestPath = manifestPath
+	backupsManifestPath = manifestPath
+	defer func() { applyManifestPath = ""; backupsManifestPath = "" }()
+
+	if err := applyCmd.Execute(); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	var out bytes.Buffer
+	showCmd := newBackupsCmd()
+	showCmd.SetOut(&out)
+	showCmd.SetArgs([]string{"show", "ssh"})
+	if err := showCmd.Execute(); err != nil {
+		t.Fatalf("backups show failed: %v", err)
+	}
+	if out.String() != "# Original\n" {
+		t.Errorf("Expected backup of original content, got %q", out.String())
+	}
+
+	out.Reset()
+	listCmd := newBackupsCmd()
+	listCmd.SetOut(&out)
+	listCmd.SetArgs([]string{"list"})
+	if err := listCmd.Execute(); err != nil {
+		t.Fatalf("backups list failed: %v", err)
+	}
+	if !strings.Contains(out.String(), targetFile) {
+		t.Errorf("Expected list to mention %s, got:\n%s", targetFile, out.String())
+	}
+}
+
+func TestBackupsCommand_Restore(t *testing.T) {
+	dir := t.TempDir()
+	manifestPath, targetFile, _ := writeBackupManifest(t, dir)
+
+	applyCmd := newApplyCmd()
+	applyCmd.SetArgs([]string{})
+	applyManifestPath = manifestPath
+	backupsManifestPath = manifestPath
+	defer func() { applyManifestPath = ""; backupsManifestPath = "" }()
+
+	if err := applyCmd.Execute(); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	restoreCmd := newBackupsCmd()
+	restoreCmd.SetOut(&bytes.Buffer{})
+	restoreCmd.SetArgs([]string{"restore", "ssh"})
+	if err := restoreCmd.Execute(); err != nil {
+		t.Fatalf("backups restore failed: %v", err)
+	}
+
+	content, _ := os.ReadFile(targetFile)
+	if string(content) != "# Original\n" {
+		t.Errorf("Expected target restored to original, got %q", content)
+	}
+}
+
+func TestBackupsCommand_Prune(t *testing.T) {
+	dir := t.TempDir()
+	manifestPath, targetFile, partialsDir := writeBackupManifest(t, dir)
+
+	applyManifestPath = manifestPath
+	backupsManifestPath = manifestPath
+	defer func() { applyManifestPath = ""; backupsManifestPath = "" }()
+
+	// Three applies with different partial content produce three backups
+	for _, host := range []string{"one", "two", "three"} {
+		if err := os.WriteFile(filepath.Join(partialsDir, "work"), []byte("Host "+host+"\n"), 0644); err != nil {
+			t.Fatalf("Failed: %v", err)
+		}
+		applyCmd := newApplyCmd()
+		applyCmd.SetArgs([]string{})
+		if err := applyCmd.Execute(); err != nil {
+			t.Fatalf("Apply failed: %v", err)
+		}
+	}
+
+	var out bytes.Buffer
+	pruneCmd := newBackupsCmd()
+	pruneCmd.SetOut(&out)
+	pruneCmd.SetArgs([]string{"prune", "--keep", "1", targetFile})
+	if err := pruneCmd.Execute(); err != nil {
+		t.Fatalf("backups prune failed: %v", err)
+	}
+	if strings.Count(out.String(), "Deleted backup") != 2 {
+		t.Errorf("Expected 2 deleted backups, got:\n%s", out.String())
+	}
+}
+
+func TestBackupsCommand_PruneKeepAll(t *testing.T) {
+	dir := t.TempDir()
+	manifestPath, targetFile, _ := writeManifest(t, dir, manifestFixture{
+		defaults: "  backup: true\n  backup_keep: -1\n  backup_dir: " + filepath.Join(dir, "backups") + "\n",
+	})
+
+	applyManifestPath = manifestPath
+	backupsManifestPath = manifestPath
+	defer func() { applyManifestPath = ""; backupsManifestPath = "" }()
+
+	applyCmd := newApplyCmd()
+	applyCmd.SetArgs([]string{})
+	if err := applyCmd.Execute(); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	for _, args := range [][]string{{"prune", targetFile}, {"prune", "--dry-run", targetFile}} {
+		var out bytes.Buffer
+		pruneCmd := newBackupsCmd()
+		pruneCmd.SetOut(&out)
+		pruneCmd.SetArgs(args)
+		if err := pruneCmd.Execute(); err != nil {
+			t.Fatalf("%v failed with backup_keep: -1: %v", args, err)
+		}
+		if !strings.Contains(out.String(), "Nothing to prune") {
+			t.Errorf("Expected nothing to prune, got:\n%s", out.String())
+		}
+	}
+
+	pruneCmd := newBackupsCmd()
+	pruneCmd.SetOut(&bytes.Buffer{})
+	pruneCmd.SetErr(&bytes.Buffer{})
+	pruneCmd.SetArgs([]string{"prune", "--keep", "-1", targetFile})
+	if err := pruneCmd.Execute(); err == nil || !strings.Contains(err.Error(), "must not be negative") {
+		t.Errorf("Expected a negative --keep to be rejected, got %v", err)
+	}
+}
+
+func TestManifestRemoveCommand_OwnModeBackup(t *testing.T) {
+	dir := t.TempDir()
+	targetFile := filepath.Join(dir, "vimrc")
+	if err := os.WriteFile(targetFile, []byte("set number\n"), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	manifest := `defaults:
+  backup: true
+  backup_dir: ` + filepath.Join(dir, "backups") + `
+targets:
+  vim:
+    target: ` + targetFile + `
+    partials: ` + dir + `
+    mode: own
+`
+	manifestPath := filepath.Join(dir, ".parts.yaml")
+	if err := os.WriteFile(manifestPath, []byte(manifest), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	rmCmd := newManifestRemoveCmd()
+	rmCmd.SetArgs([]string{})
+	manifestRemovePath = manifestPath
+	backupsManifestPath = manifestPath
+	defer func() { manifestRemovePath = ""; backupsManifestPath = "" }()
+
+	if err := rmCmd.Execute(); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+
+	var out bytes.Buffer
+	showCmd := newBackupsCmd()
+	showCmd.SetOut(&out)
+	showCmd.SetArgs([]string{"show", "vim"})
+	if err := showCmd.Execute(); err != nil {
+		t.Fatalf("backups show failed: %v", err)
+	}
+	if out.String() != "set number\n" {
+		t.Errorf("Expected deleted own-mode file to be backed up, got %q", out.String())
+	}
+}
@@ -0,0 +1,253 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cageis/parts/src"
+	"github.com/spf13/cobra"
+)
+
+// diffManifestPath allows tests to override the manifest location
+var diffManifestPath string
+
+func newDiffCmd() *cobra.Command {
+	var diffRemove, diffSync bool
+	var colorMode string
+	var contextLines int
+
+	cmd := &cobra.Command{
+		Use:   "diff [target-name...]",
+		Short: "Show a unified diff of the changes apply, remove or sync would make",
+		Long: `Reads .parts.yaml and prints a unified diff of each target's current
+content against what 'parts apply' would write. With --remove the diff shows
+what 'parts remove' would do; with --sync it shows the edits 'parts sync' would
+write back into partial files. Nothing is modified.
+
+File names are absolute paths with a/ and b/ prefixes, so the output is a
+patch that applies from the filesystem root:
+
+  parts diff > changes.patch
+  patch -d / -p1 < changes.patch`,
+		Example: `  parts diff               # Diff all targets against their rendered content
+  parts diff ssh           # Diff only the 'ssh' target
+  parts diff --remove      # Show what 'parts remove' would change
+  parts diff --sync        # Show edits 'parts sync' would write to partials
+  parts diff --color=always | less -R`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if diffRemove && diffSync {
+				return fmt.Errorf("--remove and --sync cannot be used together")
+			}
+			color, err := useColor(colorMode, cmd.OutOrStdout())
+			if err != nil {
+				return err
+			}
+
+			manifestPath := diffManifestPath
+			if manifestPath == "" {
+				manifestPath = resolveManifestPath()
+			}
+
+			absManifest, err := filepath.Abs(manifestPath)
+			if err != nil {
+				return fmt.Errorf("failed to resolve manifest path: %w", err)
+			}
+
+			manifest, err := src.LoadManifest(absManifest)
+			if err != nil {
+				return err
+			}
+
+			names, err := manifest.FilterTargets(args)
+			if err != nil {
+				return err
+			}
+
+			// sync merges against the last apply, so preview it the same way
+			var state *src.State
+			if diffSync {
+				state, _ = manifest.LoadState()
+			}
+
+			report := newReporter(cmd, "diff", false)
+			defer report.flush()
+
+			var errors []error
+			changed := 0
+
+			// Targets sharing a file are diffed against the content planned before them
+			plannedFS := src.NewPlannedFS(nil)
+
+			facts := src.CurrentFacts()
+			for _, name := range names {
+				target := manifest.ResolvedTarget(name)
+				// Remove ignores conditions, as 'parts remove' does
+				if !diffRemove && skipTarget(report, name, target, facts, false) {
+					continue
+				}
+
+				var diffs, partials []string
+				var diffErr error
+				switch {
+				case diffRemove:
+					diffs, diffErr = removeDiffs(target, contextLines)
+				case diffSync:
+					recorded, _ := recordedState(state, name, target)
+					diffs, diffErr = syncDiffs(target, recorded, contextLines)
+				default:
+					diffs, partials, diffErr = applyDiffs(plannedFS, target, contextLines)
+				}
+				if diffErr != nil {
+					errors = append(errors, report.failed(name, target, diffErr))
+					continue
+				}
+
+				diffReport := report.newReport(name, target)
+				diffReport.Changed = len(diffs) > 0
+				diffReport.Diff = strings.Join(diffs, "")
+				for _, path := range partials {
+					diffReport.Partials = append(diffReport.Partials, reportPath("", path))
+				}
+				report.add(diffReport)
+				for _, diff := range diffs {
+					if !structuredOutput() {
+						printDiff(cmd.OutOrStdout(), diff, color)
+					}
+					changed++
+				}
+			}
+
+			if changed == 0 && len(errors) == 0 {
+				fmt.Fprintln(cmd.ErrOrStderr(), "No changes")
+			}
+
+			if len(errors) > 0 {
+				for _, e := range errors {
+					fmt.Fprintf(cmd.ErrOrStderr(), "Error: %v\n", e)
+				}
+				return fmt.Errorf("%d target(s) failed", len(errors))
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&diffRemove, "remove", false, "diff what 'parts remove' would change")
+	cmd.Flags().BoolVar(&diffSync, "sync", false, "diff what 'parts sync' would write back to partials")
+	cmd.Flags().StringVar(&colorMode, "color", "auto", "colorize the diff: auto, always or never")
+	cmd.Flags().IntVarP(&contextLines, "unified", "U", src.DefaultDiffContext, "number of context lines")
+	return cmd
+}
+
+// applyDiffs returns the diff of rendering the target and the partials it
+// merges, and plans the rendered content in fsys for the targets after it
+func applyDiffs(fsys *src.PlannedFS, target src.TargetConfig, context int) (diffs, partials []string, err error) {
+	targetCmd, err := newTargetCommand(target)
+	if err != nil {
+		return nil, nil, err
+	}
+	targetCmd.SetFS(fsys)
+	change, err := targetCmd.Plan()
+	if err != nil {
+		return nil, nil, err
+	}
+	fsys.Plan(change)
+	return nonEmpty(change.Diff(context)), change.Partials, nil
+}
+
+// removeDiffs returns the diff of removing the target's managed content
+func removeDiffs(target src.TargetConfig, context int) ([]string, error) {
+	if target.Mode == "own" {
+		expandedTarget, err := src.ExpandTildePrefix(target.Target)
+		if err != nil {
+			return nil, err
+		}
+		content, err := os.ReadFile(expandedTarget)
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read target file '%s': %w", expandedTarget, err)
+		}
+		oldName, newName := src.DiffPaths(expandedTarget, true, true)
+		return nonEmpty(src.UnifiedDiff(oldName, newName, string(content), "", context)), nil
+	}
+
+	rmCmd, err := newRemoveCommand(target)
+	if err != nil {
+		return nil, err
+	}
+	change, err := rmCmd.Plan()
+	if errors.Is(err, src.ErrNoPartialsSection) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nonEmpty(change.Diff(context)), nil
+}
+
+// syncDiffs returns one diff per partial file sync would update. Conflicts
+// are left out, as sync leaves those partials alone by default.
+func syncDiffs(target src.TargetConfig, state *src.TargetState, context int) ([]string, error) {
+	// Templated targets are never synced back
+	if target.Template {
+		return nil, nil
+	}
+	syncCmd, err := newSyncCommand(target)
+	if err != nil {
+		return nil, err
+	}
+	syncCmd.SetState(state)
+	changes, _, err := syncCmd.Plan()
+	if err != nil {
+		return nil, err
+	}
+	var diffs []string
+	for _, change := range changes {
+		diffs = append(diffs, nonEmpty(change.Diff(context))...)
+	}
+	return diffs, nil
+}
+
+// nonEmpty wraps a diff in a slice, dropping it if there is no change
+func nonEmpty(diff string) []string {
+	if diff == "" {
+		return nil
+	}
+	return []string{diff}
+}
+
+// useColor resolves --color against the output: auto colors only terminals
+// and honours NO_COLOR
+func useColor(mode string, w io.Writer) (bool, error) {
+	switch mode {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "auto", "":
+		if _, noColor := os.LookupEnv("NO_COLOR"); noColor {
+			return false, nil
+		}
+		file, ok := w.(*os.File)
+		if !ok {
+			return false, nil
+		}
+		info, err := file.Stat()
+		return err == nil && info.Mode()&os.ModeCharDevice != 0, nil
+	}
+	return false, fmt.Errorf("invalid color mode '%s' (must be 'auto', 'always' or 'never')", mode)
+}
+
+// printDiff writes a diff, colored if requested
+func printDiff(w io.Writer, diff string, color bool) {
+	if color {
+		diff = src.ColorizeDiff(diff)
+	}
+	fmt.Fprint(w, diff)
+}
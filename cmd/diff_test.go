@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiffCommand_Apply(t *testing.T) {
+	dir := t.TempDir()
+	manifestPath, targetFile, _ := writeBackupManifest(t, dir)
+
+	var out bytes.Buffer
+	diffCmd := newDiffCmd()
+	diffCmd.SetOut(&out)
+	diffCmd.SetErr(&bytes.Buffer{})
+	diffCmd.SetArgs([]string{"--color", "never"})
+	diffManifestPath = manifestPath
+	defer func() { diffManifestPath = "" }()
+
+	if err := diffCmd.Execute(); err != nil {
+		t.Fatalf("diff failed: %v", err)
+	}
+
+	diff := out.String()
+	rel := strings.TrimPrefix(filepath.ToSlash(targetFile), "/")
+	for _, want := range []string{"--- a/" + rel + "\n", "+++ b/" + rel + "\n", " # Original\n", "+Host work\n"} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("Expected %q in diff:\n%s", want, diff)
+		}
+	}
+	if content, _ := os.ReadFile(targetFile); string(content) != "# Original\n" {
+		t.Errorf("diff must not modify the target, got %q", content)
+	}
+}
+
+func TestDiffCommand_RemoveAndSync(t *testing.T) {
+	dir := t.TempDir()
+	manifestPath, targetFile, partialsDir := writeBackupManifest(t, dir)
+
+	applyCmd := newApplyCmd()
+	applyCmd.SetArgs([]string{})
+	applyManifestPath = manifestPath
+	diffManifestPath = manifestPath
+	defer func() { applyManifestPath = ""; diffManifestPath = "" }()
+	if err := applyCmd.Execute(); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	// Nothing pending right after apply
+	var out, errOut bytes.Buffer
+	diffCmd := newDiffCmd()
+	diffCmd.SetOut(&out)
+	diffCmd.SetErr(&errOut)
+	diffCmd.SetArgs([]string{})
+	if err := diffCmd.Execute(); err != nil {
+		t.Fatalf("diff failed: %v", err)
+	}
+	if out.Len() != 0 || !strings.Contains(errOut.String(), "No changes") {
+		t.Errorf("Expected no changes, got stdout %q stderr %q", out.String(), errOut.String())
+	}
+
+	out.Reset()
+	removeCmd := newDiffCmd()
+	removeCmd.SetOut(&out)
+	removeCmd.SetArgs([]string{"--remove"})
+	if err := removeCmd.Execute(); err != nil {
+		t.Fatalf("diff --remove failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "-Host work\n") || !strings.Contains(out.String(), "-# PARTIALS>>>>>\n") {
+		t.Errorf("Expected removal of the section in diff:\n%s", out.String())
+	}
+
+	// Edit the target, then diff what sync would write back
+	content, _ := os.ReadFile(targetFile)
+	edited := strings.Replace(string(content), "Host work", "Host work-edited", 1)
+	if err := os.WriteFile(targetFile, []byte(edited), 0600); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	out.Reset()
+	syncCmd := newDiffCmd()
+	syncCmd.SetOut(&out)
+	syncCmd.SetArgs([]string{"--sync"})
+	if err := syncCmd.Execute(); err != nil {
+		t.Fatalf("diff --sync failed: %v", err)
+	}
+	partial := strings.TrimPrefix(filepath.ToSlash(filepath.Join(partialsDir, "work")), "/")
+	if !strings.Contains(out.String(), "+++ b/"+partial+"\n") || !strings.Contains(out.String(), "+Host work-edited\n") {
+		t.Errorf("Expected sync diff of the partial:\n%s", out.String())
+	}
+}
+
+func TestDiffCommand_InvalidFlags(t *testing.T) {
+	diffCmd := newDiffCmd()
+	diffCmd.SetArgs([]string{"--remove", "--sync"})
+	diffCmd.SilenceUsage = true
+	diffCmd.SilenceErrors = true
+	if err := diffCmd.Execute(); err == nil {
+		t.Error("Expected --remove with --sync to be rejected")
+	}
+
+	diffCmd = newDiffCmd()
+	diffCmd.SetArgs([]string{"--color", "sometimes"})
+	diffCmd.SilenceUsage = true
+	diffCmd.SilenceErrors = true
+	if err := diffCmd.Execute(); err == nil || !strings.Contains(err.Error(), "invalid color mode") {
+		t.Errorf("Expected invalid color mode error, got %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/cageis/parts/src"
+	"github.com/spf13/cobra"
+)
+
+// runHook runs the target's hook for event if it should fire for the change.
+// Hooks run in the manifest's directory and write to the command's messages.
+func runHook(cmd *cobra.Command, manifest *src.Manifest, name string, target src.TargetConfig, event, path string, changed bool) error {
+	hook := target.Hooks.Get(event)
+	if !hook.ShouldRun(changed) {
+		return nil
+	}
+	if expanded, err := src.ExpandTildePrefix(path); err == nil {
+		path = expanded
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	fmt.Fprintf(messageWriter(cmd), "Running %s hook for '%s': %s\n", event, name, hook.Run)
+	env := src.HookEnv{Target: name, Path: path, Event: event, Changed: changed}
+	return hook.Execute(env, filepath.Dir(manifest.Path()), messageWriter(cmd), cmd.ErrOrStderr())
+}
+
+// previewHooks reports the hooks a dry run would have run
+func previewHooks(cmd *cobra.Command, name string, target src.TargetConfig, events ...string) {
+	for _, event := range events {
+		hook := target.Hooks.Get(event)
+		if hook == nil {
+			continue
+		}
+		when := ""
+		if hook.ChangedOnly {
+			when = " (if changed)"
+		}
+		fmt.Fprintf(messageWriter(cmd), "DRY RUN: Would run %s hook for '%s'%s: %s\n", event, name, when, hook.Run)
+	}
+}
+
+// hooksFailed reports hooks that failed after their targets were written
+func hooksFailed(cmd *cobra.Command, hookErrors []error) error {
+	if len(hookErrors) == 0 {
+		return nil
+	}
+	for _, e := range hookErrors {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Error: %v\n", e)
+	}
+	return fmt.Errorf("%d hook(s) failed", len(hookErrors))
+}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func readHookLog(t *testing.T, dir string) string {
+	content, err := os.ReadFile(filepath.Join(dir, "hooks.log"))
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatalf("Failed: %v", err)
+	}
+	return string(content)
+}
+
+func TestApplyCommand_Hooks(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("requires /bin/sh")
+	}
+	dir := t.TempDir()
+	// Hooks run in the manifest's directory, so the relative log lands in dir
+	manifestPath, targetFile, _ := writeManifest(t, dir, manifestFixture{target: `    hooks:
+      pre_apply: echo "pre $PARTS_TARGET $PARTS_CHANGED" >> hooks.log
+      post_apply:
+        run: echo "post $PARTS_TARGET_PATH" >> hooks.log
+        changed_only: true
+`})
+	applyManifestPath = manifestPath
+	defer func() { applyManifestPath = "" }()
+
+	for i := 0; i < 2; i++ {
+		var out bytes.Buffer
+		cmd := newApplyCmd()
+		cmd.SetOut(&out)
+		cmd.SetArgs([]string{})
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("Apply failed: %v", err)
+		}
+		if !strings.Contains(out.String(), "Running pre_apply hook for 'ssh'") {
+			t.Errorf("Expected the hook to be logged, got:\n%s", out.String())
+		}
+	}
+
+	// The second apply changed nothing, so the changed_only hook didn't fire
+	want := "pre ssh 1\npost " + targetFile + "\npre ssh 0\n"
+	if log := readHookLog(t, dir); log != want {
+		t.Errorf("Expected hook log %q, got %q", want, log)
+	}
+}
+
+func TestApplyCommand_FailingPreHookSkipsTarget(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("requires /bin/sh")
+	}
+	dir := t.TempDir()
+	otherFile := filepath.Join(dir, "other")
+	manifestPath, targetFile, _ := writeManifest(t, dir, manifestFixture{
+		target: `    hooks:
+      pre_apply: echo "config test failed"; exit 1
+      post_apply: echo post >> hooks.log
+`,
+		targets: `  other:
+    target: ` + otherFile + `
+    partials: ` + filepath.Join(dir, "ssh") + `
+    mode: own
+  team:
+    target: ` + filepath.Join(dir, "ssh-config") + `
+    partials: ` + filepath.Join(dir, "ssh") + `
+    section: team
+`})
+	applyManifestPath = manifestPath
+	defer func() { applyManifestPath = "" }()
+
+	var out, stderr bytes.Buffer
+	cmd := newApplyCmd()
+	cmd.SetOut(&out)
+	cmd.SetErr(&stderr)
+	cmd.SetArgs([]string{})
+	if err := cmd.Execute(); err == nil {
+		t.Fatal("Expected apply to fail")
+	}
+	if !strings.Contains(out.String(), "config test failed") || !strings.Contains(stderr.String(), "target 'ssh'") {
+		t.Errorf("Expected the hook output and error, got:\n%s\n%s", out.String(), stderr.String())
+	}
+	// 'team' was rendered on top of 'ssh' in the same file, so it is skipped too
+	if content, _ := os.ReadFile(targetFile); string(content) != "# Original\n" {
+		t.Errorf("Expected the target to be left alone, got %q", content)
+	}
+	if !strings.Contains(stderr.String(), "target 'team': not modified") {
+		t.Errorf("Expected the shared target to be reported, got:\n%s", stderr.String())
+	}
+	if log := readHookLog(t, dir); log != "" {
+		t.Errorf("Expected no post hook, got %q", log)
+	}
+
+	// The other target is still written
+	if content, err := os.ReadFile(otherFile); err != nil || !strings.Contains(string(content), "Host work") {
+		t.Errorf("Expected the other target to be applied, got %q (%v)", content, err)
+	}
+}
+
+func TestRemoveAndSyncCommands_Hooks(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("requires /bin/sh")
+	}
+	dir := t.TempDir()
+	manifestPath, _, _ := writeManifest(t, dir, manifestFixture{target: `    hooks:
+      pre_remove: echo "pre_remove $PARTS_CHANGED" >> hooks.log
+      post_remove: echo "post_remove $PARTS_CHANGED" >> hooks.log
+      post_sync: echo "post_sync $PARTS_CHANGED" >> hooks.log
+`})
+	applyManifestPath = manifestPath
+	syncManifestPath = manifestPath
+	manifestRemovePath = manifestPath
+	defer func() { applyManifestPath = ""; syncManifestPath = ""; manifestRemovePath = "" }()
+
+	for _, cmd := range []func() *cobra.Command{newApplyCmd, newSyncCmd, newManifestRemoveCmd} {
+		c := cmd()
+		c.SetOut(&bytes.Buffer{})
+		c.SetArgs([]string{})
+		if err := c.Execute(); err != nil {
+			t.Fatalf("%s failed: %v", c.Name(), err)
+		}
+	}
+
+	want := "post_sync 0\npre_remove 1\npost_remove 1\n"
+	if log := readHookLog(t, dir); log != want {
+		t.Errorf("Expected hook log %q, got %q", want, log)
+	}
+}
@@ -17,7 +17,14 @@ const manifestTemplate = `# Parts manifest — manages dotfiles from this direct
 # Default settings applied to all targets (can be overridden per-target)
 defaults:
   comment: "auto"    # auto-detect comment style from file extension
-  backup: false      # create .bak files before modifying targets
+  backup: false      # keep timestamped backups before modifying targets
+  # backup_keep: 10  # backups retained per file (see 'parts backups')
+  # state_file: .parts.state.json  # where apply records what it wrote (see 'parts state')
+  # adopt_name: "adopted-{n}"  # new partials 'parts sync --adopt' creates from stray content
+  # checksum: true   # record a hash of managed content; apply refuses to overwrite hand edits
+  # escape_markers: true  # escape partial lines that look like PARTIALS markers instead of refusing them
+  # delimit_partials: true  # wrap each partial in begin/end lines so sync restores it byte for byte
+  # source_path: partials  # write Source paths relative to the partials dir ('manifest', 'absolute' or 'hidden')
   # mode: merge      # 'merge' (default) or 'own'
 
 # Each target defines a file to manage
@@ -28,12 +35,26 @@ targets:
   #   partials: ./ssh/
   #   comment: "#"
   #   mode: merge      # preserves content outside PARTIALS markers
+  #   validate: ssh    # check the result before writing (yaml, json, ssh, hosts or a command with {})
+  #   hooks:
+  #     post_apply:
+  #       run: echo "updated $PARTS_TARGET_PATH"
+  #       changed_only: true
 
   # Example: fully manage ~/.vimrc from partials
   # vimrc:
   #   target: ~/.vimrc
   #   partials: ./vim/
   #   mode: own         # entire file is written from partials
+
+  # Example: render partials as templates with per-machine values
+  # git:
+  #   target: ~/.gitconfig
+  #   partials: ./git/
+  #   mode: own
+  #   template: true    # {{ .Vars.email }}, {{ .Env.HOME }}, {{ .Facts.Hostname }}
+  #   vars:
+  #     email: me@example.com
 `
 
 // initManifestPath allows tests to override the manifest location
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/cageis/parts/src"
+	"github.com/spf13/cobra"
+)
+
+// Values of the global --output flag
+const (
+	outputText   = "text"
+	outputJSON   = "json"   // one document once the command is done
+	outputNDJSON = "ndjson" // one line per target as it completes
+)
+
+// outputFormat is set by the global --output flag
+var outputFormat = outputText
+
+// validateOutputFormat checks the --output flag
+func validateOutputFormat() error {
+	switch outputFormat {
+	case outputText, outputJSON, outputNDJSON:
+		return nil
+	}
+	return fmt.Errorf("invalid --output '%s' (must be 'text', 'json' or 'ndjson')", outputFormat)
+}
+
+// structuredOutput reports whether stdout carries JSON instead of messages
+func structuredOutput() bool {
+	return outputFormat == outputJSON || outputFormat == outputNDJSON
+}
+
+// messageWriter is where human-readable messages go: stdout, or stderr when
+// stdout carries structured output
+func messageWriter(cmd *cobra.Command) io.Writer {
+	if structuredOutput() {
+		return cmd.ErrOrStderr()
+	}
+	return cmd.OutOrStdout()
+}
+
+// targetReport is the structured result for one target
+type targetReport struct {
+	Name      string            `json:"name"`
+	Path      string            `json:"path"`
+	Mode      string            `json:"mode"`
+	Action    string            `json:"action"` // apply, remove, delete, sync, status, diff or skip
+	Changed   bool              `json:"changed"`
+	DryRun    bool              `json:"dry_run,omitempty"`
+	Partials  []string          `json:"partials"`
+	Bytes     int               `json:"bytes"`
+	Reason    string            `json:"reason,omitempty"`    // why a target was skipped
+	State     string            `json:"state,omitempty"`     // status only
+	Details   map[string]string `json:"details,omitempty"`   // status only: partial path -> state
+	Diff      string            `json:"diff,omitempty"`      // diff only
+	Conflicts int               `json:"conflicts,omitempty"` // sync only: unresolved conflicts
+	Error     string            `json:"error,omitempty"`
+}
+
+// reporter collects the target reports of one command and writes them in the
+// selected format. Text output is left to the command itself.
+type reporter struct {
+	cmd     *cobra.Command
+	command string
+	dryRun  bool
+	quiet   bool // write nothing
+	targets []targetReport
+}
+
+func newReporter(cmd *cobra.Command, command string, dryRun bool) *reporter {
+	return &reporter{cmd: cmd, command: command, dryRun: dryRun}
+}
+
+// newReport starts the report of a target with its resolved path
+func (r *reporter) newReport(name string, target src.TargetConfig) targetReport {
+	path := reportPath("", target.Target)
+	return targetReport{Name: name, Path: path, Mode: target.Mode, Action: r.command, DryRun: r.dryRun, Partials: []string{}}
+}
+
+// reportPath returns path in the form every report uses: absolute, with ~
+// expanded and a relative path taken from dir
+func reportPath(dir, path string) string {
+	if expanded, err := src.ExpandTildePrefix(path); err == nil {
+		path = expanded
+	}
+	path = filepath.FromSlash(path)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return path
+}
+
+// add records a target report; ndjson writes it right away
+func (r *reporter) add(report targetReport) {
+	if r.quiet {
+		return
+	}
+	if outputFormat == outputNDJSON {
+		r.write(report)
+		return
+	}
+	r.targets = append(r.targets, report)
+}
+
+// failed records a target that failed and returns the error for the summary
+func (r *reporter) failed(name string, target src.TargetConfig, err error) error {
+	report := r.newReport(name, target)
+	report.Error = err.Error()
+	r.add(report)
+	return fmt.Errorf("target '%s': %w", name, err)
+}
+
+// skipped records a target whose condition doesn't hold on this machine
+func (r *reporter) skipped(name string, target src.TargetConfig, reason string) {
+	report := r.newReport(name, target)
+	report.Action = "skip"
+	report.Reason = reason
+	r.add(report)
+}
+
+// flush writes the JSON document; call it once the command is done
+func (r *reporter) flush() {
+	if outputFormat != outputJSON || r.quiet {
+		return
+	}
+	targets := r.targets
+	if targets == nil {
+		targets = []targetReport{}
+	}
+	r.write(struct {
+		Command string         `json:"command"`
+		DryRun  bool           `json:"dry_run,omitempty"`
+		Targets []targetReport `json:"targets"`
+	}{r.command, r.dryRun, targets})
+}
+
+func (r *reporter) write(v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		fmt.Fprintf(r.cmd.ErrOrStderr(), "Error: failed to encode output: %v\n", err)
+		return
+	}
+	fmt.Fprintln(r.cmd.OutOrStdout(), string(data))
+}
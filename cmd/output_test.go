@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cageis/parts/src"
+	"github.com/spf13/cobra"
+)
+
+// writeOutputManifest writes the test manifest with a skipped own target added
+func writeOutputManifest(t *testing.T) (manifestPath, targetFile string) {
+	t.Helper()
+	dir := t.TempDir()
+	manifestPath, targetFile, _ = writeManifest(t, dir, manifestFixture{targets: `  elsewhere:
+    target: ` + filepath.Join(dir, "other") + `
+    partials: ` + filepath.Join(dir, "ssh") + `
+    mode: own
+    when:
+      host: no-such-host
+`})
+	return manifestPath, targetFile
+}
+
+func TestOutput_ApplyJSON(t *testing.T) {
+	manifestPath, targetFile := writeOutputManifest(t)
+	applyManifestPath = manifestPath
+	outputFormat = outputJSON
+	defer func() { applyManifestPath = ""; outputFormat = outputText }()
+
+	var stdout, stderr bytes.Buffer
+	applyCmd := newApplyCmd()
+	applyCmd.SetArgs([]string{})
+	applyCmd.SetOut(&stdout)
+	applyCmd.SetErr(&stderr)
+	if err := applyCmd.Execute(); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	var doc struct {
+		Command string         `json:"command"`
+		Targets []targetReport `json:"targets"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &doc); err != nil {
+		t.Fatalf("stdout is not JSON: %v\n%s", err, stdout.String())
+	}
+	if doc.Command != "apply" || len(doc.Targets) != 2 {
+		t.Fatalf("Unexpected document: %+v", doc)
+	}
+
+	byName := map[string]targetReport{}
+	for _, target := range doc.Targets {
+		byName[target.Name] = target
+	}
+	ssh := byName["ssh"]
+	content, _ := os.ReadFile(targetFile)
+	if ssh.Action != "apply" || !ssh.Changed || ssh.Path != targetFile || ssh.Mode != "merge" ||
+		len(ssh.Partials) != 1 || ssh.Bytes != len(content) {
+		t.Errorf("Unexpected ssh report: %+v", ssh)
+	}
+	if skipped := byName["elsewhere"]; skipped.Action != "skip" || skipped.Reason == "" {
+		t.Errorf("Unexpected skipped report: %+v", skipped)
+	}
+	if !strings.Contains(stderr.String(), "Merged 1 partial(s)") {
+		t.Errorf("Human messages should go to stderr, got %q", stderr.String())
+	}
+}
+
+func TestOutput_NDJSON(t *testing.T) {
+	manifestPath, _ := writeOutputManifest(t)
+	applyManifestPath = manifestPath
+	statusManifestPath = manifestPath
+	outputFormat = outputNDJSON
+	defer func() { applyManifestPath = ""; statusManifestPath = ""; outputFormat = outputText }()
+
+	applyCmd := newApplyCmd()
+	applyCmd.SetArgs([]string{"--dry-run"})
+	var stdout bytes.Buffer
+	applyCmd.SetOut(&stdout)
+	applyCmd.SetErr(&bytes.Buffer{})
+	if err := applyCmd.Execute(); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected one line per target, got %q", stdout.String())
+	}
+	for _, line := range lines {
+		var report targetReport
+		if err := json.Unmarshal([]byte(line), &report); err != nil {
+			t.Fatalf("Line is not JSON: %v\n%s", err, line)
+		}
+		if report.Name == "ssh" && (!report.DryRun || !report.Changed) {
+			t.Errorf("Expected a changed dry-run report, got %+v", report)
+		}
+	}
+
+	// The dry run wrote nothing, so status reports the target out of date
+	statusCmd := newStatusCmd()
+	statusCmd.SetArgs([]string{"ssh"})
+	stdout.Reset()
+	statusCmd.SetOut(&stdout)
+	statusCmd.SetErr(&bytes.Buffer{})
+	if err := statusCmd.Execute(); err == nil {
+		t.Fatal("Expected status to fail for an out-of-date target")
+	}
+	var report targetReport
+	if err := json.Unmarshal(stdout.Bytes(), &report); err != nil {
+		t.Fatalf("status output is not JSON: %v\n%s", err, stdout.String())
+	}
+	if report.Action != "status" || report.State == "" || !report.Changed {
+		t.Errorf("Unexpected status report: %+v", report)
+	}
+}
+
+func TestOutput_ApplyAndStatusRecordsMatch(t *testing.T) {
+	manifestPath, _, partialsDir := writeManifest(t, t.TempDir(), manifestFixture{})
+	applyManifestPath = manifestPath
+	statusManifestPath = manifestPath
+	outputFormat = outputNDJSON
+	defer func() { applyManifestPath = ""; statusManifestPath = ""; outputFormat = outputText }()
+
+	record := func(cmd *cobra.Command) targetReport {
+		t.Helper()
+		var stdout bytes.Buffer
+		cmd.SetArgs([]string{"ssh"})
+		cmd.SetOut(&stdout)
+		cmd.SetErr(&bytes.Buffer{})
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("%s failed: %v", cmd.Name(), err)
+		}
+		var report targetReport
+		if err := json.Unmarshal(stdout.Bytes(), &report); err != nil {
+			t.Fatalf("%s output is not JSON: %v\n%s", cmd.Name(), err, stdout.String())
+		}
+		return report
+	}
+	applied := record(newApplyCmd())
+	status := record(newStatusCmd())
+
+	want := []string{filepath.Join(partialsDir, "work")}
+	if !reflect.DeepEqual(applied.Partials, want) {
+		t.Errorf("Expected apply to report %v, got %v", want, applied.Partials)
+	}
+	if status.Name != applied.Name || status.Path != applied.Path || status.Mode != applied.Mode ||
+		!reflect.DeepEqual(status.Partials, applied.Partials) {
+		t.Errorf("Status record %+v doesn't match apply record %+v", status, applied)
+	}
+	if status.Details[want[0]] != src.PartialOK {
+		t.Errorf("Expected details keyed by the same paths, got %v", status.Details)
+	}
+}
+
+func TestOutput_DiffJSON(t *testing.T) {
+	manifestPath, _, partialsDir := writeManifest(t, t.TempDir(), manifestFixture{})
+	diffManifestPath = manifestPath
+	outputFormat = outputJSON
+	defer func() { diffManifestPath = ""; outputFormat = outputText }()
+
+	var stdout bytes.Buffer
+	diffCmd := newDiffCmd()
+	diffCmd.SetArgs([]string{})
+	diffCmd.SetOut(&stdout)
+	diffCmd.SetErr(&bytes.Buffer{})
+	if err := diffCmd.Execute(); err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+
+	var doc struct {
+		Targets []targetReport `json:"targets"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &doc); err != nil {
+		t.Fatalf("stdout is not JSON: %v\n%s", err, stdout.String())
+	}
+	if len(doc.Targets) != 1 {
+		t.Fatalf("Unexpected document: %+v", doc)
+	}
+	want := []string{filepath.Join(partialsDir, "work")}
+	if report := doc.Targets[0]; !report.Changed || report.Diff == "" || !reflect.DeepEqual(report.Partials, want) {
+		t.Errorf("Expected a diff of %v, got %+v", want, report)
+	}
+}
+
+func TestValidateOutputFormat(t *testing.T) {
+	defer func() { outputFormat = outputText }()
+	outputFormat = "yaml"
+	if err := validateOutputFormat(); err == nil {
+		t.Error("Expected an unknown output format to be rejected")
+	}
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -22,7 +23,10 @@ func newManifestRemoveCmd() *cobra.Command {
 For 'merge' mode targets, the PARTIALS markers and their content are removed,
 preserving any user content outside the markers.
 
-For 'own' mode targets, the target file is deleted entirely.`,
+For 'own' mode targets, the target file is deleted entirely.
+
+A target's 'pre_remove' hook runs first and a failure skips that target;
+its 'post_remove' hook runs once the target was removed.`,
 		Example: `  parts remove           # Remove all targets
   parts remove ssh       # Remove only the 'ssh' target
   parts remove --dry-run # Preview what would be removed`,
@@ -47,44 +51,96 @@ For 'own' mode targets, the target file is deleted entirely.`,
 				return err
 			}
 
+			report := newReporter(cmd, "remove", removeDryRun)
+			defer report.flush()
+			messages := messageWriter(cmd)
+
 			var errors []error
+			var removed []string
+			var hookErrors []error
 			for _, name := range names {
 				target := manifest.ResolvedTarget(name)
 
+				backups, backupErr := targetBackupStore(manifest, target)
+				if backupErr != nil {
+					errors = append(errors, report.failed(name, target, backupErr))
+					continue
+				}
+
+				path, changed, changeErr := removeTargetChange(target)
+				if changeErr != nil {
+					errors = append(errors, report.failed(name, target, changeErr))
+					continue
+				}
+				if removeDryRun {
+					previewHooks(cmd, name, target, src.HookPreRemove, src.HookPostRemove)
+				} else if hookErr := runHook(cmd, manifest, name, target, src.HookPreRemove, path, changed); hookErr != nil {
+					errors = append(errors, report.failed(name, target, hookErr))
+					continue
+				}
+
 				switch target.Mode {
 				case "merge":
-					// NewPartialsRemoveCommand handles tilde expansion internally
-					rmCmd, rmErr := src.NewPartialsRemoveCommand(target.Target, target.Comment)
+					rmCmd, rmErr := newRemoveCommand(target)
 					if rmErr != nil {
-						errors = append(errors, fmt.Errorf("target '%s': %w", name, rmErr))
+						errors = append(errors, report.failed(name, target, rmErr))
 						continue
 					}
 					rmCmd.SetDryRun(removeDryRun)
+					rmCmd.SetBackupStore(backups)
+					rmCmd.SetOutput(messages)
 					if runErr := rmCmd.Run(); runErr != nil {
-						errors = append(errors, fmt.Errorf("target '%s': %w", name, runErr))
+						errors = append(errors, report.failed(name, target, runErr))
+						continue
 					}
+					removed = append(removed, name)
 
 				case "own":
 					expandedTarget, expandErr := src.ExpandTildePrefix(target.Target)
 					if expandErr != nil {
-						errors = append(errors, fmt.Errorf("target '%s': %w", name, expandErr))
+						errors = append(errors, report.failed(name, target, expandErr))
 						continue
 					}
 
 					if removeDryRun {
-						fmt.Printf("DRY RUN: Would delete '%s' (own mode)\n", expandedTarget)
+						fmt.Fprintf(messages, "DRY RUN: Would delete '%s' (own mode)\n", expandedTarget)
 					} else {
+						if backups != nil {
+							if _, err := backups.Save(expandedTarget); err != nil {
+								errors = append(errors, report.failed(name, target, fmt.Errorf("failed to back up '%s': %w", expandedTarget, err)))
+								continue
+							}
+						}
 						if err := os.Remove(expandedTarget); err != nil {
 							if !os.IsNotExist(err) {
-								errors = append(errors, fmt.Errorf("target '%s': failed to delete '%s': %w", name, expandedTarget, err))
+								errors = append(errors, report.failed(name, target, fmt.Errorf("failed to delete '%s': %w", expandedTarget, err)))
+								continue
 							}
 						} else {
-							fmt.Printf("Deleted '%s' (own mode)\n", expandedTarget)
+							fmt.Fprintf(messages, "Deleted '%s' (own mode)\n", expandedTarget)
 						}
+						removed = append(removed, name)
+					}
+				}
+
+				removeReport := report.newReport(name, target)
+				removeReport.Changed = changed
+				if target.Mode == "own" {
+					removeReport.Action = "delete"
+				}
+				report.add(removeReport)
+
+				if !removeDryRun && len(removed) > 0 && removed[len(removed)-1] == name {
+					if hookErr := runHook(cmd, manifest, name, target, src.HookPostRemove, path, changed); hookErr != nil {
+						hookErrors = append(hookErrors, fmt.Errorf("target '%s': %w", name, hookErr))
 					}
 				}
 			}
 
+			if !removeDryRun {
+				forgetTargets(cmd, manifest, removed)
+			}
+
 			if len(errors) > 0 {
 				for _, e := range errors {
 					fmt.Fprintf(cmd.ErrOrStderr(), "Error: %v\n", e)
@@ -92,10 +148,35 @@ For 'own' mode targets, the target file is deleted entirely.`,
 				return fmt.Errorf("%d target(s) failed", len(errors))
 			}
 
-			return nil
+			return hooksFailed(cmd, hookErrors)
 		},
 	}
 
 	cmd.Flags().BoolVarP(&removeDryRun, "dry-run", "n", false, "preview changes without modifying files")
 	return cmd
 }
+
+// removeTargetChange returns the target's path and whether removing it would
+// modify anything: a merge target needs a PARTIALS section, an own target must exist
+func removeTargetChange(target src.TargetConfig) (string, bool, error) {
+	expandedTarget, err := src.ExpandTildePrefix(target.Target)
+	if err != nil {
+		return "", false, err
+	}
+	if target.Mode == "own" {
+		_, statErr := os.Stat(expandedTarget)
+		return expandedTarget, statErr == nil, nil
+	}
+
+	rmCmd, err := newRemoveCommand(target)
+	if err != nil {
+		return "", false, err
+	}
+	if _, err := rmCmd.Plan(); err != nil {
+		if errors.Is(err, src.ErrNoPartialsSection) || errors.Is(err, os.ErrNotExist) {
+			return expandedTarget, false, nil
+		}
+		return "", false, err
+	}
+	return expandedTarget, true, nil
+}
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cageis/parts/src"
+	"github.com/spf13/cobra"
+)
+
+// repairManifestPath allows tests to override the manifest location
+var repairManifestPath string
+
+func newRepairCmd() *cobra.Command {
+	var (
+		comment      string
+		section      string
+		yes          bool
+		repairDryRun bool
+		repairBackup bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "repair <target-or-file>",
+		Short: "Restore a well-formed PARTIALS section after broken hand edits",
+		Long: `Apply, remove and sync refuse to touch a file whose PARTIALS markers are
+missing, reversed, duplicated or nested, and name the offending line.
+'parts repair' fixes them one problem at a time:
+
+  - a start marker without an end gets an end marker at the end of the file
+  - an end marker without a start, or before it, is removed
+  - a second start marker inside the section is removed
+  - a second copy of the section is removed, through its own end marker
+
+Each fix is confirmed interactively unless --yes is given. Another
+section starting inside this one must be fixed by hand.
+
+The argument may be a manifest target name, whose comment style, section
+and backup settings are used, or a file path.`,
+		Example: `  parts repair ssh                   # Repair the 'ssh' target, confirming each fix
+  parts repair --yes ~/.ssh/config   # Apply every fix without asking
+  parts repair -n /etc/hosts         # Preview the repaired file
+  parts repair --section team /etc/hosts`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			manifestPath := repairManifestPath
+			if manifestPath == "" {
+				manifestPath = resolveManifestPath()
+			}
+			var manifest *src.Manifest
+			if _, err := os.Stat(manifestPath); err == nil {
+				absManifest, absErr := filepath.Abs(manifestPath)
+				if absErr != nil {
+					return fmt.Errorf("failed to resolve manifest path: %w", absErr)
+				}
+				if manifest, err = src.LoadManifest(absManifest); err != nil {
+					return err
+				}
+			}
+
+			path, commentStyle, sectionName := args[0], comment, section
+			var backups *src.BackupStore
+			if manifest != nil {
+				if _, isTarget := manifest.Targets[args[0]]; isTarget {
+					target := manifest.ResolvedTarget(args[0])
+					path = target.Target
+					if !cmd.Flags().Changed("comment") {
+						commentStyle = target.Comment
+					}
+					if !cmd.Flags().Changed("section") {
+						sectionName = target.Section
+					}
+					store, err := targetBackupStore(manifest, target)
+					if err != nil {
+						return err
+					}
+					backups = store
+				}
+			}
+			if repairBackup && backups == nil {
+				store, err := src.NewBackupStore("", src.DefaultBackupKeep)
+				if err != nil {
+					return err
+				}
+				backups = store
+			}
+
+			repairCmd, err := src.NewPartialsRepairCommand(path, commentStyle)
+			if err != nil {
+				return err
+			}
+			if err := repairCmd.SetSectionID(sectionName); err != nil {
+				return err
+			}
+			repairCmd.SetDryRun(repairDryRun)
+			repairCmd.SetBackupStore(backups)
+			repairCmd.SetOutput(cmd.OutOrStdout())
+			if !yes && !repairDryRun {
+				repairCmd.SetConfirm(confirmFix(cmd))
+			}
+			return repairCmd.Run()
+		},
+	}
+
+	cmd.Flags().StringVarP(&comment, "comment", "c", "auto", "comment style of the markers")
+	cmd.Flags().StringVar(&section, "section", "", "name of the PARTIALS section to repair")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "apply every fix without asking")
+	cmd.Flags().BoolVarP(&repairDryRun, "dry-run", "n", false, "preview the repaired file without modifying it")
+	cmd.Flags().BoolVar(&repairBackup, "backup", false, "keep a timestamped backup of the file before repairing it")
+	return cmd
+}
+
+// confirmFix asks on the command's input whether to apply each fix
+func confirmFix(cmd *cobra.Command) func(src.SectionFix) bool {
+	in := bufio.NewReader(cmd.InOrStdin())
+	return func(fix src.SectionFix) bool {
+		fmt.Fprintf(cmd.OutOrStdout(), "%v\n  Fix: %s? [y/N] ", fix.Problem, fix.Action)
+		answer, _ := in.ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		return answer == "y" || answer == "yes"
+	}
+}
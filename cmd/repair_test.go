@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cageis/parts/src"
+)
+
+func TestRepairCommand_Interactive(t *testing.T) {
+	dir := t.TempDir()
+	manifestPath, targetFile, _ := writeBackupManifest(t, dir)
+	repairManifestPath = manifestPath
+	defer func() { repairManifestPath = "" }()
+
+	separator := "# " + src.MarkerSeparator + "\n"
+	broken := "# Original\n" + separator + "# " + src.PartialStartMarker + "\n" + separator + "Host work\n"
+	if err := os.WriteFile(targetFile, []byte(broken), 0600); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	// Declining the fix leaves the file alone
+	var out bytes.Buffer
+	repairCmd := newRepairCmd()
+	repairCmd.SetOut(&out)
+	repairCmd.SetIn(strings.NewReader("n\n"))
+	repairCmd.SetArgs([]string{"ssh"})
+	if err := repairCmd.Execute(); err == nil {
+		t.Fatal("Expected a declined repair to fail")
+	}
+	if !strings.Contains(out.String(), "line 3: start marker has no end marker") {
+		t.Errorf("Expected the problem to be described, got:\n%s", out.String())
+	}
+	if content, _ := os.ReadFile(targetFile); string(content) != broken {
+		t.Errorf("Declined repair modified the file:\n%s", content)
+	}
+
+	out.Reset()
+	repairCmd = newRepairCmd()
+	repairCmd.SetOut(&out)
+	repairCmd.SetIn(strings.NewReader("y\n"))
+	repairCmd.SetArgs([]string{"ssh"})
+	if err := repairCmd.Execute(); err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+	content, _ := os.ReadFile(targetFile)
+	if !strings.HasSuffix(string(content), "Host work\n"+separator+"# "+src.PartialEndMarker+"\n"+separator) {
+		t.Errorf("Expected an end marker at the end of the file, got:\n%s", content)
+	}
+
+	// The target's backup setting applies
+	entries, _ := os.ReadDir(filepath.Join(dir, "backups"))
+	if len(entries) == 0 {
+		t.Error("Expected a backup before the repair")
+	}
+
+	// Apply works again on the repaired file
+	applyManifestPath = manifestPath
+	defer func() { applyManifestPath = "" }()
+	applyCmd := newApplyCmd()
+	applyCmd.SetOut(&out)
+	applyCmd.SetArgs([]string{})
+	if err := applyCmd.Execute(); err != nil {
+		t.Fatalf("Apply after repair failed: %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -10,8 +11,22 @@ import (
 )
 
 var (
-	dryRun bool
-	remove bool
+	dryRun   bool
+	remove   bool
+	backup   bool
+	showDiff bool
+	force    bool
+
+	sectionID string
+	placement string
+
+	recursive bool
+	include   []string
+	exclude   []string
+	order     []string
+	sortOrder string
+
+	validate string
 
 	rootCmd = &cobra.Command{
 		Use:   "parts [flags] <aggregate-file> [partials-directory] <comment-style>",
@@ -40,11 +55,20 @@ Comment Style Support:
   parts app.js ./partials "//"
   parts schema.sql ./sql-partials "auto"
   parts --dry-run ~/.ssh/config ~/.ssh/config.d "#"
+  parts --diff ~/.ssh/config ~/.ssh/config.d "#"
+  parts --backup ~/.ssh/config ~/.ssh/config.d "#"
+  parts --force ~/.ssh/config ~/.ssh/config.d "#"
+  parts --section team /etc/hosts ./team-hosts "#"
+  parts -R --include '*.conf' --exclude 'archive' ~/.ssh/config ./ssh "#"
+  parts --placement 'before:^Host \*' ~/.ssh/config ~/.ssh/config.d "#"
+  parts --sort natural --order base ~/.ssh/config ~/.ssh/config.d "#"
+  parts --validate 'ssh -G -F {} localhost' ~/.ssh/config ~/.ssh/config.d "#"
   
   # Remove mode: Remove partials section from file
   parts --remove ~/.ssh/config "#"
   parts --remove styles.css "/*"
   parts --remove config.py "auto"
+  parts --remove --section team /etc/hosts "#"
   
   # Auto-detection works great for most file types
   parts config.py ./python-configs "auto"`,
@@ -76,7 +100,24 @@ func runParts(cmd *cobra.Command, args []string) error {
 		if err != nil {
 			return err
 		}
+		if err := command.SetSectionID(sectionID); err != nil {
+			return err
+		}
+		if showDiff {
+			change, err := command.Plan()
+			if errors.Is(err, src.ErrNoPartialsSection) {
+				fmt.Fprintf(cmd.ErrOrStderr(), "No partials section found in '%s' to remove\n", aggregateFile)
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			return printLegacyDiff(cmd, change)
+		}
 		command.SetDryRun(dryRun)
+		if err := setLegacyBackupStore(&command); err != nil {
+			return err
+		}
 
 		return command.Run()
 	}
@@ -90,24 +131,129 @@ func runParts(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	if err := command.SetSectionID(sectionID); err != nil {
+		return err
+	}
+	parsedPlacement, err := src.ParsePlacement(placement)
+	if err != nil {
+		return err
+	}
+	command.SetPlacement(parsedPlacement)
+	partialOptions := src.PartialOptions{
+		Recursive: recursive,
+		Include:   include,
+		Exclude:   exclude,
+		Order:     order,
+		Sort:      sortOrder,
+	}
+	if err := partialOptions.Validate(); err != nil {
+		return err
+	}
+	command.SetPartialOptions(partialOptions)
+	validator, err := src.ParseValidator(validate)
+	if err != nil {
+		return err
+	}
+	command.SetValidator(validator)
+	command.SetForce(force)
+	if showDiff {
+		change, err := command.Plan()
+		if err != nil {
+			return err
+		}
+		return printLegacyDiff(cmd, change)
+	}
 	command.SetDryRun(dryRun)
+	if err := setLegacyBackupStore(&command); err != nil {
+		return err
+	}
 
 	return command.Run()
 }
 
+// printLegacyDiff prints the diff of a planned change, colored on terminals
+func printLegacyDiff(cmd *cobra.Command, change *src.FileChange) error {
+	color, err := useColor("auto", cmd.OutOrStdout())
+	if err != nil {
+		return err
+	}
+	diff := change.Diff(src.DefaultDiffContext)
+	if diff == "" {
+		fmt.Fprintln(cmd.ErrOrStderr(), "No changes")
+		return nil
+	}
+	printDiff(cmd.OutOrStdout(), diff, color)
+	return nil
+}
+
+// backupSetter is implemented by every command that can back up files before writing
+type backupSetter interface {
+	SetBackupStore(store *src.BackupStore)
+}
+
+// setLegacyBackupStore enables the default backup store when --backup is given
+func setLegacyBackupStore(command backupSetter) error {
+	if !backup {
+		return nil
+	}
+	store, err := src.NewBackupStore("", src.DefaultBackupKeep)
+	if err != nil {
+		return err
+	}
+	command.SetBackupStore(store)
+	return nil
+}
+
 // Execute runs the root command
 func Execute() {
 	rootCmd.Flags().BoolVarP(&dryRun, "dry-run", "n", false, "preview changes without modifying files")
 	rootCmd.Flags().BoolVarP(&remove, "remove", "r", false, "remove partials section from aggregate file")
+	rootCmd.Flags().BoolVar(&showDiff, "diff", false, "print a unified diff of the pending change instead of applying it")
+	rootCmd.Flags().BoolVar(&force, "force", false, "overwrite a section that was edited by hand since it was written")
+	rootCmd.Flags().BoolVar(&backup, "backup", false, "keep a timestamped backup of the file before modifying it")
+	rootCmd.Flags().BoolVarP(&recursive, "recursive", "R", false, "include partials from subdirectories of the partials directory")
+	rootCmd.Flags().StringArrayVar(&include, "include", nil, "only merge partials matching this glob (repeatable, supports **)")
+	rootCmd.Flags().StringArrayVar(&exclude, "exclude", nil, "skip partials matching this glob (repeatable, supports **)")
+	rootCmd.Flags().StringArrayVar(&order, "order", nil, "merge this partial (path relative to the partials directory) first; repeatable, in order")
+	rootCmd.Flags().StringVar(&sortOrder, "sort", "", "order of the remaining partials: lexical (default) or natural (2-base before 10-hosts)")
+	rootCmd.Flags().StringVar(&placement, "placement", "", "where a new section is inserted: top, bottom, before:<regex> or after:<regex>")
+	rootCmd.Flags().StringVar(&validate, "validate", "", "check the rendered file before writing: yaml, json, ssh, hosts or a command with {} for the file")
+	rootCmd.Flags().StringVar(&sectionID, "section", "", "name of the PARTIALS section to manage (lets several sections share one file)")
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", outputText, "output of apply, remove, sync, status and diff: text, json or ndjson")
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		return validateOutputFormat()
+	}
 
 	// Register manifest-driven subcommands
 	rootCmd.AddCommand(newApplyCmd())
 	rootCmd.AddCommand(newManifestRemoveCmd())
 	rootCmd.AddCommand(newInitCmd())
 	rootCmd.AddCommand(newSyncCmd())
+	rootCmd.AddCommand(newBackupsCmd())
+	rootCmd.AddCommand(newDiffCmd())
+	rootCmd.AddCommand(newStatusCmd())
+	rootCmd.AddCommand(newStateCmd())
+	rootCmd.AddCommand(newWatchCmd())
+	rootCmd.AddCommand(newRepairCmd())
 
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		var silent silentError
+		if !errors.As(err, &silent) {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
 		os.Exit(1)
 	}
 }
+
+// silentError makes the process exit non-zero without printing anything
+type silentError struct {
+	err error
+}
+
+func (e silentError) Error() string {
+	return e.err.Error()
+}
+
+func (e silentError) Unwrap() error {
+	return e.err
+}
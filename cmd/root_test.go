@@ -2,11 +2,14 @@ package cmd
 
 import (
 	"bytes"
+	"errors"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/cageis/parts/src"
 	"github.com/spf13/cobra"
 )
 
@@ -158,3 +161,111 @@ func TestRunParts_Normal(t *testing.T) {
 		t.Error("File should contain partials markers")
 	}
 }
+
+func TestRunParts_Diff(t *testing.T) {
+	dir := t.TempDir()
+	partialsDir := filepath.Join(dir, "partials")
+	if err := os.MkdirAll(partialsDir, 0755); err != nil {
+		t.Fatalf("Failed to create partials directory: %v", err)
+	}
+	aggregateFile := filepath.Join(dir, "agg")
+	originalContent := "# Original config\n"
+	if err := os.WriteFile(aggregateFile, []byte(originalContent), 0600); err != nil {
+		t.Fatalf("Failed to create aggregate file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(partialsDir, "partial1"), []byte("Host test\n"), 0600); err != nil {
+		t.Fatalf("Failed to create partial file: %v", err)
+	}
+
+	originalShowDiff := showDiff
+	showDiff = false
+	defer func() { showDiff = originalShowDiff }()
+
+	cmd := &cobra.Command{
+		Use:  rootCmd.Use,
+		Args: rootCmd.Args,
+		RunE: rootCmd.RunE,
+	}
+	cmd.Flags().BoolVar(&showDiff, "diff", false, "print a diff")
+	cmd.SetArgs([]string{"--diff", aggregateFile, partialsDir, "#"})
+	var output bytes.Buffer
+	cmd.SetOut(&output)
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Command should not fail: %v", err)
+	}
+
+	if !strings.Contains(output.String(), "+Host test\n") || !strings.HasPrefix(output.String(), "--- a/") {
+		t.Errorf("Expected a unified diff, got:\n%s", output.String())
+	}
+	if actual, _ := os.ReadFile(aggregateFile); string(actual) != originalContent {
+		t.Errorf("File was modified in diff mode!")
+	}
+}
+
+func TestRunParts_ForceOverwritesHandEdits(t *testing.T) {
+	dir := t.TempDir()
+	partialsDir := filepath.Join(dir, "partials")
+	if err := os.MkdirAll(partialsDir, 0755); err != nil {
+		t.Fatalf("Failed to create partials directory: %v", err)
+	}
+	aggregateFile := filepath.Join(dir, "agg")
+	if err := os.WriteFile(aggregateFile, []byte("# Original config\n"), 0600); err != nil {
+		t.Fatalf("Failed to create aggregate file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(partialsDir, "partial1"), []byte("Host test\n"), 0600); err != nil {
+		t.Fatalf("Failed to create partial file: %v", err)
+	}
+
+	// A checksummed section, as apply writes it, then edited by hand
+	build, err := src.NewPartialsBuildCommand(aggregateFile, partialsDir, "#")
+	if err != nil {
+		t.Fatalf("Failed to create build command: %v", err)
+	}
+	build.SetChecksum(true)
+	build.SetOutput(io.Discard)
+	if err := build.Run(); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	built, err := os.ReadFile(aggregateFile)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	edited := strings.Replace(string(built), "Host test\n", "Host edited\n", 1)
+	if err := os.WriteFile(aggregateFile, []byte(edited), 0600); err != nil {
+		t.Fatalf("Failed to edit file: %v", err)
+	}
+
+	originalForce := force
+	defer func() { force = originalForce }()
+	run := func(args ...string) error {
+		force = false
+		cmd := &cobra.Command{
+			Use:  rootCmd.Use,
+			Args: rootCmd.Args,
+			RunE: rootCmd.RunE,
+		}
+		cmd.Flags().BoolVar(&force, "force", false, "overwrite hand edits")
+		cmd.SetArgs(append(args, aggregateFile, partialsDir, "#"))
+		cmd.SetOut(io.Discard)
+		cmd.SilenceUsage = true
+		cmd.SilenceErrors = true
+		return cmd.Execute()
+	}
+
+	err = run()
+	if !errors.Is(err, src.ErrHandEdited) || !strings.Contains(err.Error(), "--force") {
+		t.Fatalf("Expected a hand edit refusal suggesting --force, got %v", err)
+	}
+	if actual, _ := os.ReadFile(aggregateFile); string(actual) != edited {
+		t.Fatalf("Refused build modified the file")
+	}
+
+	if err := run("--force"); err != nil {
+		t.Fatalf("Forced build failed: %v", err)
+	}
+	actual, _ := os.ReadFile(aggregateFile)
+	if strings.Contains(string(actual), "Host edited") || !strings.Contains(string(actual), "Host test\n") {
+		t.Errorf("Expected --force to overwrite the hand edit, got:\n%s", actual)
+	}
+}
@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/cageis/parts/src"
+	"github.com/spf13/cobra"
+)
+
+// stateManifestPath allows tests to override the manifest location
+var stateManifestPath string
+
+func newStateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "state",
+		Short: "Inspect what apply and sync last wrote to each target",
+		Long: `Parts records what 'parts apply' and 'parts sync' last wrote to each
+manifest target: the resolved path, mode, comment style, a hash of every
+partial and a hash of the managed section. 'parts remove' drops the record.
+
+'parts status' uses the state to tell a hand-edited target from a stale one
+and to report targets that were dropped from the manifest.
+
+The state is stored under $XDG_STATE_HOME/parts/state (default
+~/.local/state/parts/state), one file per manifest, unless 'state_file' is
+set in the manifest defaults.`,
+		Example: `  parts state show          # Show the state of every target
+  parts state show ssh      # Show the state of the 'ssh' target
+  parts state path          # Print the state file location
+  parts state forget old    # Drop a target that left the manifest`,
+	}
+
+	cmd.AddCommand(newStateShowCmd())
+	cmd.AddCommand(newStatePathCmd())
+	cmd.AddCommand(newStateForgetCmd())
+	return cmd
+}
+
+func newStateShowCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "show [target-name...]",
+		Short: "Show the recorded state of targets",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			manifest, state, err := loadManifestState()
+			if err != nil {
+				return err
+			}
+
+			names := args
+			if len(names) == 0 {
+				names = state.Names()
+			}
+
+			out := cmd.OutOrStdout()
+			if len(names) == 0 {
+				fmt.Fprintf(out, "No state recorded in '%s'\n", state.Path())
+				return nil
+			}
+			for i, name := range names {
+				target, ok := state.Target(name)
+				if !ok {
+					return fmt.Errorf("no state recorded for target '%s'", name)
+				}
+				if i > 0 {
+					fmt.Fprintln(out)
+				}
+				header := name
+				if _, inManifest := manifest.Targets[name]; !inManifest {
+					header += " (not in manifest)"
+				}
+				fmt.Fprintln(out, header)
+				fmt.Fprintf(out, "  path:     %s\n", target.Path)
+				fmt.Fprintf(out, "  mode:     %s\n", target.Mode)
+				fmt.Fprintf(out, "  comment:  %s\n", target.Comment)
+				if target.Section != "" {
+					fmt.Fprintf(out, "  section:  %s\n", target.Section)
+				}
+				fmt.Fprintf(out, "  hash:     %s\n", shortHash(target.SectionHash))
+				fmt.Fprintf(out, "  updated:  %s by %s\n", target.Updated.Local().Format("2006-01-02 15:04:05"), target.Action)
+				fmt.Fprintf(out, "  partials: %d\n", len(target.Partials))
+				for _, partial := range target.Partials {
+					fmt.Fprintf(out, "    %s  %s\n", shortHash(partial.Hash), partial.Path)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func newStatePathCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "path",
+		Short: "Print the location of the state file",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			manifest, err := loadStateManifest()
+			if err != nil {
+				return err
+			}
+			path, err := manifest.StatePath()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), path)
+			return nil
+		},
+	}
+}
+
+func newStateForgetCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "forget <target-name>...",
+		Short: "Drop targets from the state",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, state, err := loadManifestState()
+			if err != nil {
+				return err
+			}
+			for _, name := range args {
+				if _, ok := state.Target(name); !ok {
+					return fmt.Errorf("no state recorded for target '%s'", name)
+				}
+			}
+			for _, name := range args {
+				state.Forget(name)
+			}
+			if err := state.Save(); err != nil {
+				return err
+			}
+			for _, name := range args {
+				fmt.Fprintf(cmd.OutOrStdout(), "Forgot target '%s'\n", name)
+			}
+			return nil
+		},
+	}
+}
+
+// loadStateManifest loads the manifest for the state subcommands
+func loadStateManifest() (*src.Manifest, error) {
+	manifestPath := stateManifestPath
+	if manifestPath == "" {
+		manifestPath = resolveManifestPath()
+	}
+	absManifest, err := filepath.Abs(manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve manifest path: %w", err)
+	}
+	return src.LoadManifest(absManifest)
+}
+
+// loadManifestState loads the manifest and its state file
+func loadManifestState() (*src.Manifest, *src.State, error) {
+	manifest, err := loadStateManifest()
+	if err != nil {
+		return nil, nil, err
+	}
+	state, err := manifest.LoadState()
+	if err != nil {
+		return nil, nil, err
+	}
+	return manifest, state, nil
+}
+
+// recordTargets stores the current state of targets after action wrote them.
+// State is bookkeeping, so failures are warnings rather than errors.
+func recordTargets(cmd *cobra.Command, manifest *src.Manifest, names []string, action string) {
+	if len(names) == 0 {
+		return
+	}
+	updateState(cmd, manifest, func(state *src.State) {
+		for _, name := range names {
+			target, err := src.CaptureTargetState(manifest.ResolvedTarget(name), action)
+			if err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Warning: failed to record state of target '%s': %v\n", name, err)
+				continue
+			}
+			state.Record(name, target)
+		}
+	})
+}
+
+// forgetTargets drops removed targets from the state
+func forgetTargets(cmd *cobra.Command, manifest *src.Manifest, names []string) {
+	if len(names) == 0 {
+		return
+	}
+	updateState(cmd, manifest, func(state *src.State) {
+		for _, name := range names {
+			state.Forget(name)
+		}
+	})
+}
+
+// updateState loads the manifest's state, applies update and saves it
+func updateState(cmd *cobra.Command, manifest *src.Manifest, update func(state *src.State)) {
+	state, err := manifest.LoadState()
+	if err != nil {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %v\n", err)
+		return
+	}
+	update(state)
+	if err := state.Save(); err != nil {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %v\n", err)
+	}
+}
+
+// shortHash abbreviates a "sha256:<hex>" digest for display
+func shortHash(hash string) string {
+	hash = strings.TrimPrefix(hash, "sha256:")
+	if len(hash) > 12 {
+		hash = hash[:12]
+	}
+	return hash
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestMain keeps the state written by apply, remove and sync out of the real home
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "parts-state")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Setenv("XDG_STATE_HOME", dir)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestStateCommand(t *testing.T) {
+	dir := t.TempDir()
+	manifestPath, targetFile, partialsDir := writeBackupManifest(t, dir)
+	applyManifestPath = manifestPath
+	manifestRemovePath = manifestPath
+	stateManifestPath = manifestPath
+	statusManifestPath = manifestPath
+	defer func() {
+		applyManifestPath = ""
+		manifestRemovePath = ""
+		stateManifestPath = ""
+		statusManifestPath = ""
+	}()
+
+	runState := func(args ...string) (string, error) {
+		var out bytes.Buffer
+		stateCmd := newStateCmd()
+		stateCmd.SetOut(&out)
+		stateCmd.SetErr(&out)
+		stateCmd.SetArgs(args)
+		err := stateCmd.Execute()
+		return out.String(), err
+	}
+
+	out, err := runState("show")
+	if err != nil || !strings.Contains(out, "No state recorded") {
+		t.Fatalf("Expected an empty state, got %v\n%s", err, out)
+	}
+
+	applyCmd := newApplyCmd()
+	applyCmd.SetArgs([]string{})
+	if err := applyCmd.Execute(); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	out, err = runState("show", "ssh")
+	if err != nil {
+		t.Fatalf("State show failed: %v", err)
+	}
+	for _, want := range []string{"ssh\n", "path:     " + targetFile, "mode:     merge", "comment:  #", "by apply", partialsDir} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in:\n%s", want, out)
+		}
+	}
+
+	out, err = runState("path")
+	if err != nil || !strings.HasPrefix(out, os.Getenv("XDG_STATE_HOME")) {
+		t.Errorf("Expected the state under XDG_STATE_HOME, got %v %s", err, out)
+	}
+
+	// Dropping the target from the manifest leaves an orphan
+	if err := os.WriteFile(manifestPath, []byte("targets:\n  other:\n    target: "+dir+"/other\n    partials: "+partialsDir+"\n"), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	out, _ = runState("show")
+	if !strings.Contains(out, "ssh (not in manifest)") {
+		t.Errorf("Expected ssh to be marked as not in the manifest:\n%s", out)
+	}
+	var statusOut bytes.Buffer
+	statusCmd := newStatusCmd()
+	statusCmd.SetOut(&statusOut)
+	statusCmd.SetErr(&bytes.Buffer{})
+	statusCmd.SetArgs([]string{})
+	if err := statusCmd.Execute(); err == nil {
+		t.Error("Expected status to fail with an orphaned target")
+	}
+	if !strings.Contains(statusOut.String(), "ssh: orphaned ("+targetFile+")") {
+		t.Errorf("Expected orphan report:\n%s", statusOut.String())
+	}
+
+	out, err = runState("forget", "ssh")
+	if err != nil || !strings.Contains(out, "Forgot target 'ssh'") {
+		t.Errorf("Forget failed: %v\n%s", err, out)
+	}
+	if _, err := runState("forget", "ssh"); err == nil {
+		t.Error("Expected an error forgetting an unknown target")
+	}
+}
+
+func TestRemoveCommand_ForgetsState(t *testing.T) {
+	dir := t.TempDir()
+	manifestPath, _, _ := writeBackupManifest(t, dir)
+	applyManifestPath = manifestPath
+	manifestRemovePath = manifestPath
+	stateManifestPath = manifestPath
+	defer func() { applyManifestPath = ""; manifestRemovePath = ""; stateManifestPath = "" }()
+
+	applyCmd := newApplyCmd()
+	applyCmd.SetArgs([]string{})
+	if err := applyCmd.Execute(); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	_, state, err := loadManifestState()
+	if err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if _, ok := state.Target("ssh"); !ok {
+		t.Fatal("Expected apply to record the target")
+	}
+
+	removeCmd := newManifestRemoveCmd()
+	removeCmd.SetArgs([]string{})
+	if err := removeCmd.Execute(); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	_, state, err = loadManifestState()
+	if err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if _, ok := state.Target("ssh"); ok {
+		t.Error("Expected remove to forget the target")
+	}
+}
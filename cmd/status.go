@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/cageis/parts/src"
+	"github.com/spf13/cobra"
+)
+
+// statusManifestPath allows tests to override the manifest location
+var statusManifestPath string
+
+func newStatusCmd() *cobra.Command {
+	var quiet bool
+
+	cmd := &cobra.Command{
+		Use:   "status [target-name...]",
+		Short: "Report which manifest targets are out of date",
+		Long: `Reads .parts.yaml and compares each target with its partials:
+
+  in sync               nothing to do
+  partials changed      partials were edited or added; run 'parts apply'
+  edited                the section was edited in the target; run 'parts sync'
+  diverged              both sides changed; review with 'parts diff'
+  markers missing       the target has no PARTIALS section yet
+  target missing        the target file does not exist
+  partials dir missing  the partials directory does not exist
+
+When 'parts apply' or 'parts sync' recorded the target's state, content
+hashes decide which side changed; otherwise modification times do. Targets
+recorded in the state but no longer in the manifest are reported as orphaned.
+
+Each target is followed by a per-partial breakdown. The command exits
+non-zero when any target is out of date, so it can be used in shell prompts
+and CI; --quiet suppresses the report.`,
+		Example: `  parts status            # Report every target
+  parts status ssh        # Report only the 'ssh' target
+  parts status -q || echo "dotfiles out of date"`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			manifestPath := statusManifestPath
+			if manifestPath == "" {
+				manifestPath = resolveManifestPath()
+			}
+
+			absManifest, err := filepath.Abs(manifestPath)
+			if err != nil {
+				return fmt.Errorf("failed to resolve manifest path: %w", err)
+			}
+
+			manifest, err := src.LoadManifest(absManifest)
+			if err != nil {
+				return err
+			}
+
+			names, err := manifest.FilterTargets(args)
+			if err != nil {
+				return err
+			}
+
+			state, err := manifest.LoadState()
+			if err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %v\n", err)
+			}
+
+			// --quiet prints nothing, in any format
+			report := newReporter(cmd, "status", false)
+			report.quiet = quiet
+			defer report.flush()
+			text := !quiet && !structuredOutput()
+			out := cmd.OutOrStdout()
+			var errors []error
+			outOfDate := 0
+
+			facts := src.CurrentFacts()
+			for _, name := range names {
+				target := manifest.ResolvedTarget(name)
+				if ok, reason := target.When.Match(facts); !ok {
+					if text {
+						fmt.Fprintf(out, "%s: skipped (%s)\n", name, reason)
+					} else {
+						report.skipped(name, target, reason)
+					}
+					continue
+				}
+
+				statusCmd, cmdErr := newStatusCommand(target)
+				if cmdErr != nil {
+					errors = append(errors, report.failed(name, target, cmdErr))
+					continue
+				}
+				if recorded, ok := recordedState(state, name, target); ok {
+					statusCmd.SetState(recorded)
+				}
+				status, checkErr := statusCmd.Check()
+				if checkErr != nil {
+					errors = append(errors, report.failed(name, target, checkErr))
+					continue
+				}
+				if !status.UpToDate() {
+					outOfDate++
+				}
+				if text {
+					printTargetStatus(cmd, name, status)
+				} else {
+					report.add(statusReport(report, name, target, status))
+				}
+			}
+
+			// Targets dropped from the manifest still have content on disk
+			if state != nil && len(args) == 0 {
+				for _, name := range state.Orphans(manifest) {
+					outOfDate++
+					recorded, _ := state.Target(name)
+					if text {
+						fmt.Fprintf(out, "%s: orphaned (%s) - not in the manifest; run 'parts state forget %s'\n", name, recorded.Path, name)
+					} else {
+						report.add(targetReport{Name: name, Path: recorded.Path, Mode: recorded.Mode, Action: "status",
+							Changed: true, State: "orphaned", Partials: []string{}})
+					}
+				}
+			}
+
+			if len(errors) > 0 {
+				for _, e := range errors {
+					fmt.Fprintf(cmd.ErrOrStderr(), "Error: %v\n", e)
+				}
+				return fmt.Errorf("%d target(s) failed", len(errors))
+			}
+			if outOfDate > 0 {
+				// The report already says what is wrong; only the exit status matters
+				cmd.SilenceUsage = true
+				err := fmt.Errorf("%d target(s) out of date", outOfDate)
+				if quiet {
+					cmd.SilenceErrors = true
+					return silentError{err}
+				}
+				return err
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "print nothing; only set the exit status")
+	return cmd
+}
+
+// newStatusCommand configures the status command for a resolved target
+func newStatusCommand(target src.TargetConfig) (*src.PartialsStatusCommand, error) {
+	expandedTarget, err := src.ExpandTildePrefix(target.Target)
+	if err != nil {
+		return nil, err
+	}
+	expandedPartials, err := src.ExpandTildePrefix(target.Partials)
+	if err != nil {
+		return nil, err
+	}
+	statusCmd := src.NewPartialsStatusCommand(expandedTarget, expandedPartials, target.Comment, target.Mode)
+	if err := statusCmd.SetSectionID(target.Section); err != nil {
+		return nil, err
+	}
+	statusCmd.SetPartialOptions(target.PartialOptions())
+	statusCmd.SetTemplateData(targetTemplateData(target))
+	statusCmd.SetChecksum(checksumEnabled(target))
+	statusCmd.SetEscapeMarkers(escapeEnabled(target))
+	statusCmd.SetDelimitPartials(delimitEnabled(target))
+	statusCmd.SetSourcePaths(target.SourcePaths())
+	return &statusCmd, nil
+}
+
+// recordedState returns the target's recorded state if it was written with
+// the same path, mode, comment style and section as the target has now
+func recordedState(state *src.State, name string, target src.TargetConfig) (*src.TargetState, bool) {
+	if state == nil {
+		return nil, false
+	}
+	recorded, ok := state.Target(name)
+	if !ok {
+		return nil, false
+	}
+	expandedTarget, err := src.ExpandTildePrefix(target.Target)
+	if err != nil {
+		return nil, false
+	}
+	absTarget, err := filepath.Abs(expandedTarget)
+	if err != nil {
+		return nil, false
+	}
+	style := src.ResolveCommentStyle(target.Comment, absTarget)
+	if recorded.Path != absTarget || recorded.Mode != target.Mode ||
+		recorded.Comment != style.Start || recorded.Section != target.Section {
+		return nil, false
+	}
+	return &recorded, true
+}
+
+// statusReport describes a target's status and its per-partial breakdown.
+// Partial paths are absolute, as apply and sync report them.
+func statusReport(report *reporter, name string, target src.TargetConfig, status *src.TargetStatus) targetReport {
+	result := report.newReport(name, target)
+	result.Changed = !status.UpToDate()
+	result.State = status.State
+	result.Details = make(map[string]string, len(status.Partials))
+	partialsDir := reportPath("", target.Partials)
+	for _, partial := range status.Partials {
+		path := reportPath(partialsDir, partial.Path)
+		result.Partials = append(result.Partials, path)
+		result.Details[path] = partial.State
+	}
+	return result
+}
+
+// printTargetStatus writes a target's state and its per-partial breakdown
+func printTargetStatus(cmd *cobra.Command, name string, status *src.TargetStatus) {
+	out := cmd.OutOrStdout()
+	line := fmt.Sprintf("%s: %s (%s)", name, status.State, status.Path)
+	if hint := status.Hint(); hint != "" {
+		line += " - " + hint
+	}
+	fmt.Fprintln(out, line)
+	for _, partial := range status.Partials {
+		if partial.Reason != "" {
+			fmt.Fprintf(out, "    %-8s %s (%s)\n", partial.State, partial.Path, partial.Reason)
+		} else {
+			fmt.Fprintf(out, "    %-8s %s\n", partial.State, partial.Path)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStatusCommand(t *testing.T) {
+	dir := t.TempDir()
+	manifestPath, _, partialsDir := writeBackupManifest(t, dir)
+
+	applyCmd := newApplyCmd()
+	applyCmd.SetArgs([]string{})
+	applyManifestPath = manifestPath
+	statusManifestPath = manifestPath
+	defer func() { applyManifestPath = ""; statusManifestPath = "" }()
+	if err := applyCmd.Execute(); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	var out bytes.Buffer
+	statusCmd := newStatusCmd()
+	statusCmd.SetOut(&out)
+	statusCmd.SetArgs([]string{})
+	if err := statusCmd.Execute(); err != nil {
+		t.Fatalf("Expected success when in sync, got %v\n%s", err, out.String())
+	}
+	if !strings.Contains(out.String(), "ssh: in sync") || !strings.Contains(out.String(), "ok       work") {
+		t.Errorf("Unexpected report:\n%s", out.String())
+	}
+
+	// A new partial makes the target out of date
+	if err := os.WriteFile(filepath.Join(partialsDir, "extra"), []byte("Host extra\n"), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	out.Reset()
+	statusCmd = newStatusCmd()
+	statusCmd.SetOut(&out)
+	statusCmd.SetErr(&bytes.Buffer{})
+	statusCmd.SetArgs([]string{})
+	if err := statusCmd.Execute(); err == nil || !strings.Contains(err.Error(), "1 target(s) out of date") {
+		t.Errorf("Expected out of date error, got %v", err)
+	}
+	if !strings.Contains(out.String(), "ssh: partials changed") || !strings.Contains(out.String(), "new      extra") {
+		t.Errorf("Unexpected report:\n%s", out.String())
+	}
+
+	// --quiet prints nothing but still fails
+	out.Reset()
+	quietCmd := newStatusCmd()
+	quietCmd.SetOut(&out)
+	quietCmd.SetArgs([]string{"--quiet"})
+	err := quietCmd.Execute()
+	var silent silentError
+	if !errors.As(err, &silent) {
+		t.Errorf("Expected silent error, got %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expected no output with --quiet, got:\n%s", out.String())
+	}
+}
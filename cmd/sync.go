@@ -11,7 +11,8 @@ import (
 var syncManifestPath string
 
 func newSyncCmd() *cobra.Command {
-	var syncDryRun bool
+	var syncDryRun, syncOurs, syncTheirs, syncMarkers, syncAdopt bool
+	var syncPrune string
 
 	cmd := &cobra.Command{
 		Use:   "sync [target-name...]",
@@ -20,10 +21,41 @@ func newSyncCmd() *cobra.Command {
 content back into the partial source files.
 
 Uses the '# Source: <path>' comments to map content back to individual
-partial files.`,
+partial files. Targets with 'template: true' are skipped, since rendered
+output can't be mapped back onto the template sources.
+
+When the state file records what the last apply wrote, it is used as the
+common base of a three-way merge: partials edited since the last apply keep
+those edits, and target edits to other lines are merged in. A partial
+changed only on its side is skipped until the next apply. If both sides
+changed the same lines, sync reports the conflict and leaves the partial
+alone, unless --ours (keep the partial), --theirs (take the target) or
+--markers (write both between conflict markers) says how to settle it.
+Without recorded state the target's content wins.
+
+Content in the managed block that belongs to no partial (lines above the
+first source comment, or a hand-written section whose source comment names
+no partial) would be dropped by the next apply. Sync reports it; with
+--adopt it is written to new partial files named by the target's
+'adopt_name' pattern (default 'adopted-{n}').
+
+A partial whose whole section was deleted from the target would come back
+with the next apply. Sync reports it; --prune renames it to
+'<name>.disabled' (never read as a partial) and --prune=delete removes it.
+Only partials unchanged since the last apply are pruned; without recorded
+state, partials newer than the target are left alone.
+
+When every edit found in a target with a checksum line made it into the
+partials, sync updates the checksum so the next apply accepts them.
+
+A target's 'post_sync' hook runs after it is synced; with 'changed_only: true'
+only when partial files were updated.`,
 		Example: `  parts sync            # Sync all targets
   parts sync ssh        # Sync only the 'ssh' target
-  parts sync --dry-run  # Preview what would be synced`,
+  parts sync --dry-run  # Preview what would be synced
+  parts sync --markers  # Write conflicts into partials for manual editing
+  parts sync --adopt    # Keep hand-written content as new partials
+  parts sync --prune    # Disable partials whose section was deleted`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			manifestPath := syncManifestPath
 			if manifestPath == "" {
@@ -45,37 +77,111 @@ partial files.`,
 				return err
 			}
 
+			conflictMode, err := syncConflictMode(syncOurs, syncTheirs, syncMarkers)
+			if err != nil {
+				return err
+			}
+
+			// The last apply's partials are the merge base; without state the target wins
+			state, stateErr := manifest.LoadState()
+			if stateErr != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %v\n", stateErr)
+			}
+
+			report := newReporter(cmd, "sync", syncDryRun)
+			defer report.flush()
+			messages := messageWriter(cmd)
+
 			var errors []error
-			totalUpdated := 0
+			var synced []string
+			var hookErrors []error
+			totalUpdated, unresolved := 0, 0
 
+			facts := src.CurrentFacts()
 			for _, name := range names {
 				target := manifest.ResolvedTarget(name)
+				if skipTarget(report, name, target, facts, syncDryRun || len(args) > 0) {
+					continue
+				}
 
-				expandedTarget, expandErr := src.ExpandTildePrefix(target.Target)
-				if expandErr != nil {
-					errors = append(errors, fmt.Errorf("target '%s': %w", name, expandErr))
+				// Rendered output can't be mapped back onto template sources
+				if target.Template {
+					fmt.Fprintf(messages, "Skipping '%s': templated partials can't be synced back\n", name)
+					report.skipped(name, target, "templated partials can't be synced back")
 					continue
 				}
 
-				expandedPartials, expandErr := src.ExpandTildePrefix(target.Partials)
-				if expandErr != nil {
-					errors = append(errors, fmt.Errorf("target '%s': %w", name, expandErr))
+				backups, backupErr := targetBackupStore(manifest, target)
+				if backupErr != nil {
+					errors = append(errors, report.failed(name, target, backupErr))
 					continue
 				}
 
-				result, syncErr := src.SyncTarget(expandedTarget, expandedPartials, target.Comment, target.Mode, syncDryRun)
+				syncCommand, cmdErr := newSyncCommand(target)
+				if cmdErr != nil {
+					errors = append(errors, report.failed(name, target, cmdErr))
+					continue
+				}
+				syncCommand.SetDryRun(syncDryRun)
+				syncCommand.SetBackupStore(backups)
+				syncCommand.SetOutput(messages)
+				if recorded, ok := recordedState(state, name, target); ok {
+					syncCommand.SetState(recorded)
+				}
+				if modeErr := syncCommand.SetConflictMode(conflictMode); modeErr != nil {
+					return modeErr
+				}
+				if adoptErr := syncCommand.SetAdopt(syncAdopt, target.AdoptName); adoptErr != nil {
+					errors = append(errors, report.failed(name, target, adoptErr))
+					continue
+				}
+				if pruneErr := syncCommand.SetPrune(syncPrune); pruneErr != nil {
+					return fmt.Errorf("--prune: %w", pruneErr)
+				}
+				result, syncErr := syncCommand.Run()
 				if syncErr != nil {
-					errors = append(errors, fmt.Errorf("target '%s': %w", name, syncErr))
+					errors = append(errors, report.failed(name, target, syncErr))
 					continue
 				}
 
 				totalUpdated += result.UpdatedFiles
+				unresolved += len(result.Unresolved())
+				syncReport := report.newReport(name, target)
+				syncReport.Changed = len(result.ChangedPaths) > 0 || result.Pruned
+				for _, path := range result.ChangedPaths {
+					syncReport.Partials = append(syncReport.Partials, reportPath("", path))
+				}
+				if result.Pruned {
+					for _, path := range result.Deleted {
+						syncReport.Partials = append(syncReport.Partials, reportPath("", path))
+					}
+				}
+				syncReport.Bytes = result.Bytes
+				syncReport.Conflicts = len(result.Unresolved())
+				report.add(syncReport)
+				// Recording a target that still differs from its partials would
+				// make the unapplied edits the next merge base
+				if !result.NeedsApply() {
+					synced = append(synced, name)
+				} else if !syncDryRun && !unsettled(result) {
+					fmt.Fprintf(messages, "Run 'parts apply %s' to bring '%s' up to date with its partials\n", name, target.Target)
+				}
+
+				if syncDryRun {
+					previewHooks(cmd, name, target, src.HookPostSync)
+				} else if hookErr := runHook(cmd, manifest, name, target, src.HookPostSync, target.Target, result.UpdatedFiles > 0); hookErr != nil {
+					hookErrors = append(hookErrors, fmt.Errorf("target '%s': %w", name, hookErr))
+				}
+			}
+
+			if !syncDryRun {
+				recordTargets(cmd, manifest, synced, "sync")
 			}
 
 			if syncDryRun {
-				fmt.Printf("DRY RUN: %d partial file(s) would be updated\n", totalUpdated)
+				fmt.Fprintf(messages, "DRY RUN: %d partial file(s) would be updated\n", totalUpdated)
 			} else if totalUpdated == 0 {
-				fmt.Println("All partials are in sync")
+				fmt.Fprintln(messages, "All partials are in sync")
 			}
 
 			if len(errors) > 0 {
@@ -84,11 +190,50 @@ partial files.`,
 				}
 				return fmt.Errorf("%d target(s) failed", len(errors))
 			}
+			if unresolved > 0 {
+				if hooksErr := hooksFailed(cmd, hookErrors); hooksErr != nil {
+					return hooksErr
+				}
+				return fmt.Errorf("%d partial(s) have unresolved conflicts", unresolved)
+			}
 
-			return nil
+			return hooksFailed(cmd, hookErrors)
 		},
 	}
 
 	cmd.Flags().BoolVarP(&syncDryRun, "dry-run", "n", false, "preview changes without modifying files")
+	cmd.Flags().BoolVar(&syncOurs, "ours", false, "settle conflicts by keeping the partial's version")
+	cmd.Flags().BoolVar(&syncTheirs, "theirs", false, "settle conflicts by taking the target's version")
+	cmd.Flags().BoolVar(&syncMarkers, "markers", false, "write conflicts into partials between conflict markers")
+	cmd.Flags().BoolVar(&syncAdopt, "adopt", false, "write content that belongs to no partial into new partial files")
+	cmd.Flags().StringVar(&syncPrune, "prune", "", "disable (rename to .disabled) or delete partials whose section was deleted from the target")
+	cmd.Flags().Lookup("prune").NoOptDefVal = src.PruneDisable
 	return cmd
 }
+
+// unsettled reports whether sync left differences the user has to settle
+// before an apply, which would otherwise undo them
+func unsettled(result *src.SyncResult) bool {
+	return len(result.Unresolved()) > 0 || len(result.Unadopted()) > 0 || (len(result.Deleted) > 0 && !result.Pruned)
+}
+
+// syncConflictMode maps the --ours, --theirs and --markers flags to a conflict mode
+func syncConflictMode(ours, theirs, markers bool) (string, error) {
+	set := 0
+	for _, on := range []bool{ours, theirs, markers} {
+		if on {
+			set++
+		}
+	}
+	switch {
+	case set > 1:
+		return "", fmt.Errorf("--ours, --theirs and --markers cannot be used together")
+	case ours:
+		return src.ConflictOurs, nil
+	case theirs:
+		return src.ConflictTheirs, nil
+	case markers:
+		return src.ConflictMarkers, nil
+	}
+	return src.ConflictRefuse, nil
+}
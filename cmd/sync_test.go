@@ -166,3 +166,262 @@ func TestSyncCommand_DryRun(t *testing.T) {
 		t.Error("Partial should not be modified in dry-run mode")
 	}
 }
+
+func TestSyncCommand_RecursivePartials(t *testing.T) {
+	dir := t.TempDir()
+	partialsDir := filepath.Join(dir, "ssh")
+	nestedDir := filepath.Join(partialsDir, "work")
+	if err := os.MkdirAll(nestedDir, 0755); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(nestedDir, "app.conf"), []byte("Host app\n    User admin\n"), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(nestedDir, "notes.txt"), []byte("ignore me\n"), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	targetFile := filepath.Join(dir, "ssh-config")
+	if err := os.WriteFile(targetFile, []byte(""), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	manifest := `targets:
+  ssh:
+    target: ` + targetFile + `
+    partials: ` + partialsDir + `
+    comment: "#"
+    recursive: true
+    include: ["**/*.conf"]
+`
+	manifestPath := filepath.Join(dir, ".parts.yaml")
+	if err := os.WriteFile(manifestPath, []byte(manifest), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	applyCmd := newApplyCmd()
+	applyCmd.SetArgs([]string{})
+	applyManifestPath = manifestPath
+	syncManifestPath = manifestPath
+	defer func() { syncManifestPath = ""; applyManifestPath = "" }()
+	if err := applyCmd.Execute(); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	content, _ := os.ReadFile(targetFile)
+	if strings.Contains(string(content), "ignore me") {
+		t.Fatalf("Excluded file was merged:\n%s", content)
+	}
+	modified := strings.Replace(string(content), "User admin", "User root", 1)
+	if err := os.WriteFile(targetFile, []byte(modified), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	syncCmd := newSyncCmd()
+	syncCmd.SetArgs([]string{})
+	if err := syncCmd.Execute(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	partialContent, _ := os.ReadFile(filepath.Join(nestedDir, "app.conf"))
+	if !strings.Contains(string(partialContent), "User root") {
+		t.Errorf("Nested partial should be updated, got %q", partialContent)
+	}
+}
+
+func TestSyncCommand_Conflicts(t *testing.T) {
+	dir := t.TempDir()
+	partialsDir := filepath.Join(dir, "ssh")
+	if err := os.MkdirAll(partialsDir, 0755); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	partialFile := filepath.Join(partialsDir, "work")
+	if err := os.WriteFile(partialFile, []byte("Host work\n    User admin\n"), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	targetFile := filepath.Join(dir, "ssh-config")
+	if err := os.WriteFile(targetFile, []byte("# My config\n"), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	manifest := `targets:
+  ssh:
+    target: ` + targetFile + `
+    partials: ` + partialsDir + `
+    comment: "#"
+    mode: merge
+`
+	manifestPath := filepath.Join(dir, ".parts.yaml")
+	if err := os.WriteFile(manifestPath, []byte(manifest), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	applyCmd := newApplyCmd()
+	applyCmd.SetArgs([]string{})
+	applyManifestPath = manifestPath
+	syncManifestPath = manifestPath
+	defer func() { syncManifestPath = ""; applyManifestPath = "" }()
+	if err := applyCmd.Execute(); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	// Both sides change the same line
+	os.WriteFile(partialFile, []byte("Host work\n    User ops\n"), 0644)
+	content, _ := os.ReadFile(targetFile)
+	os.WriteFile(targetFile, []byte(strings.Replace(string(content), "User admin", "User root", 1)), 0644)
+
+	syncCmd := newSyncCmd()
+	syncCmd.SetArgs([]string{})
+	syncCmd.SilenceUsage = true
+	if err := syncCmd.Execute(); err == nil || !strings.Contains(err.Error(), "unresolved conflicts") {
+		t.Fatalf("Expected an unresolved conflict error, got %v", err)
+	}
+	partialContent, _ := os.ReadFile(partialFile)
+	if string(partialContent) != "Host work\n    User ops\n" {
+		t.Errorf("Refused conflict should leave the partial alone, got %q", partialContent)
+	}
+
+	conflicting := newSyncCmd()
+	conflicting.SetArgs([]string{"--ours", "--theirs"})
+	if err := conflicting.Execute(); err == nil {
+		t.Error("Expected --ours and --theirs to be rejected together")
+	}
+
+	syncCmd = newSyncCmd()
+	syncCmd.SetArgs([]string{"--theirs"})
+	if err := syncCmd.Execute(); err != nil {
+		t.Fatalf("Sync --theirs failed: %v", err)
+	}
+	partialContent, _ = os.ReadFile(partialFile)
+	if string(partialContent) != "Host work\n    User root\n" {
+		t.Errorf("Expected the target's version, got %q", partialContent)
+	}
+}
+
+func TestSyncCommand_Adopt(t *testing.T) {
+	dir := t.TempDir()
+	partialsDir := filepath.Join(dir, "ssh")
+	if err := os.MkdirAll(partialsDir, 0755); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(partialsDir, "work"), []byte("Host work\n"), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	targetFile := filepath.Join(dir, "ssh-config")
+	if err := os.WriteFile(targetFile, []byte("# My config\n"), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	manifest := `targets:
+  ssh:
+    target: ` + targetFile + `
+    partials: ` + partialsDir + `
+    comment: "#"
+    adopt_name: "local-{n}"
+`
+	manifestPath := filepath.Join(dir, ".parts.yaml")
+	if err := os.WriteFile(manifestPath, []byte(manifest), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	applyCmd := newApplyCmd()
+	applyCmd.SetArgs([]string{})
+	applyManifestPath = manifestPath
+	syncManifestPath = manifestPath
+	defer func() { syncManifestPath = ""; applyManifestPath = "" }()
+	if err := applyCmd.Execute(); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	// Type a host straight into the managed block, above the first partial
+	content, _ := os.ReadFile(targetFile)
+	source := "# Source: " + filepath.Join(partialsDir, "work")
+	os.WriteFile(targetFile, []byte(strings.Replace(string(content), source, "Host typed\n"+source, 1)), 0644)
+
+	syncCmd := newSyncCmd()
+	syncCmd.SetArgs([]string{})
+	if err := syncCmd.Execute(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(partialsDir, "local-1")); !os.IsNotExist(err) {
+		t.Fatal("Sync without --adopt should only report stray content")
+	}
+
+	syncCmd = newSyncCmd()
+	syncCmd.SetArgs([]string{"--adopt"})
+	if err := syncCmd.Execute(); err != nil {
+		t.Fatalf("Sync --adopt failed: %v", err)
+	}
+	adopted, err := os.ReadFile(filepath.Join(partialsDir, "local-1"))
+	if err != nil || string(adopted) != "Host typed\n" {
+		t.Errorf("Expected the typed host adopted into local-1, got %q (%v)", adopted, err)
+	}
+}
+
+func TestSyncCommand_Prune(t *testing.T) {
+	dir := t.TempDir()
+	partialsDir := filepath.Join(dir, "ssh")
+	if err := os.MkdirAll(partialsDir, 0755); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	for name, content := range map[string]string{"staging": "Host staging\n", "work": "Host work\n"} {
+		if err := os.WriteFile(filepath.Join(partialsDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed: %v", err)
+		}
+	}
+
+	targetFile := filepath.Join(dir, "ssh-config")
+	if err := os.WriteFile(targetFile, []byte("# My config\n"), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	manifest := `targets:
+  ssh:
+    target: ` + targetFile + `
+    partials: ` + partialsDir + `
+    comment: "#"
+`
+	manifestPath := filepath.Join(dir, ".parts.yaml")
+	if err := os.WriteFile(manifestPath, []byte(manifest), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	applyCmd := newApplyCmd()
+	applyCmd.SetArgs([]string{})
+	applyManifestPath = manifestPath
+	syncManifestPath = manifestPath
+	defer func() { syncManifestPath = ""; applyManifestPath = "" }()
+	if err := applyCmd.Execute(); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	content, _ := os.ReadFile(targetFile)
+	section := "# Source: " + filepath.Join(partialsDir, "staging") + "\nHost staging\n\n"
+	os.WriteFile(targetFile, []byte(strings.Replace(string(content), section, "", 1)), 0644)
+
+	syncCmd := newSyncCmd()
+	syncCmd.SetArgs([]string{"--prune", "--dry-run"})
+	if err := syncCmd.Execute(); err != nil {
+		t.Fatalf("Sync --prune --dry-run failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(partialsDir, "staging")); err != nil {
+		t.Fatalf("Dry run must not prune: %v", err)
+	}
+
+	syncCmd = newSyncCmd()
+	syncCmd.SetArgs([]string{"--prune"})
+	if err := syncCmd.Execute(); err != nil {
+		t.Fatalf("Sync --prune failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(partialsDir, "staging.disabled")); err != nil {
+		t.Errorf("Expected staging to be disabled: %v", err)
+	}
+
+	invalid := newSyncCmd()
+	invalid.SetArgs([]string{"--prune=shred"})
+	if err := invalid.Execute(); err == nil {
+		t.Error("Expected an unknown prune mode to be rejected")
+	}
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/cageis/parts/src"
+)
+
+// targetCommand is the build or own command that renders a manifest target
+type targetCommand interface {
+	SetDryRun(dryRun bool)
+	SetBackupStore(store *src.BackupStore)
+	SetOutput(w io.Writer)
+	SetFS(fsys src.FS)
+	Plan() (*src.FileChange, error)
+	Validate(change *src.FileChange) error
+	SetForce(force bool)
+	CheckEdits(change *src.FileChange) error
+	Run() error
+}
+
+// newTargetCommand configures the build (merge mode) or own command for a resolved target
+func newTargetCommand(target src.TargetConfig) (targetCommand, error) {
+	validator, err := src.ParseValidator(target.Validate)
+	if err != nil {
+		return nil, err
+	}
+
+	switch target.Mode {
+	case "merge":
+		// NewPartialsBuildCommand handles tilde expansion internally
+		buildCmd, err := src.NewPartialsBuildCommand(target.Target, target.Partials, target.Comment)
+		if err != nil {
+			return nil, err
+		}
+		if err := buildCmd.SetSectionID(target.Section); err != nil {
+			return nil, err
+		}
+		buildCmd.SetPlacement(target.Placement)
+		buildCmd.SetPartialOptions(target.PartialOptions())
+		buildCmd.SetTemplateData(targetTemplateData(target))
+		buildCmd.SetValidator(validator)
+		buildCmd.SetChecksum(checksumEnabled(target))
+		buildCmd.SetEscapeMarkers(escapeEnabled(target))
+		buildCmd.SetDelimitPartials(delimitEnabled(target))
+		buildCmd.SetSourcePaths(target.SourcePaths())
+		return &buildCmd, nil
+
+	case "own":
+		// Own mode needs manual tilde expansion
+		expandedTarget, err := src.ExpandTildePrefix(target.Target)
+		if err != nil {
+			return nil, err
+		}
+		expandedPartials, err := src.ExpandTildePrefix(target.Partials)
+		if err != nil {
+			return nil, err
+		}
+		ownCmd := src.NewPartialsOwnCommand(expandedTarget, expandedPartials, target.Comment)
+		ownCmd.SetPartialOptions(target.PartialOptions())
+		ownCmd.SetTemplateData(targetTemplateData(target))
+		ownCmd.SetValidator(validator)
+		ownCmd.SetChecksum(checksumEnabled(target))
+		ownCmd.SetEscapeMarkers(escapeEnabled(target))
+		ownCmd.SetDelimitPartials(delimitEnabled(target))
+		ownCmd.SetSourcePaths(target.SourcePaths())
+		return &ownCmd, nil
+	}
+
+	return nil, fmt.Errorf("invalid mode '%s'", target.Mode)
+}
+
+// newRemoveCommand configures the remove command for a resolved merge-mode target
+func newRemoveCommand(target src.TargetConfig) (*src.PartialsRemoveCommand, error) {
+	// NewPartialsRemoveCommand handles tilde expansion internally
+	rmCmd, err := src.NewPartialsRemoveCommand(target.Target, target.Comment)
+	if err != nil {
+		return nil, err
+	}
+	if err := rmCmd.SetSectionID(target.Section); err != nil {
+		return nil, err
+	}
+	return &rmCmd, nil
+}
+
+// newSyncCommand configures the sync command for a resolved target
+func newSyncCommand(target src.TargetConfig) (*src.PartialsSyncCommand, error) {
+	expandedTarget, err := src.ExpandTildePrefix(target.Target)
+	if err != nil {
+		return nil, err
+	}
+	expandedPartials, err := src.ExpandTildePrefix(target.Partials)
+	if err != nil {
+		return nil, err
+	}
+	syncCmd := src.NewPartialsSyncCommand(expandedTarget, expandedPartials, target.Comment, target.Mode)
+	if err := syncCmd.SetSectionID(target.Section); err != nil {
+		return nil, err
+	}
+	syncCmd.SetPartialOptions(target.PartialOptions())
+	syncCmd.SetSourcePaths(target.SourcePaths())
+	return &syncCmd, nil
+}
+
+// skipTarget reports whether the target's when condition rules it out on this
+// machine, recording the skip and printing the reason if verbose is set
+func skipTarget(report *reporter, name string, target src.TargetConfig, facts src.Facts, verbose bool) bool {
+	ok, reason := target.When.Match(facts)
+	if ok {
+		return false
+	}
+	if verbose {
+		fmt.Fprintf(messageWriter(report.cmd), "Skipping target '%s': %s\n", name, reason)
+	}
+	report.skipped(name, target, reason)
+	return true
+}
+
+// checksumEnabled reports whether the target's managed content carries a checksum line
+func checksumEnabled(target src.TargetConfig) bool {
+	return target.Checksum != nil && *target.Checksum
+}
+
+// escapeEnabled reports whether partial lines that look like markers are escaped
+func escapeEnabled(target src.TargetConfig) bool {
+	return target.Escape != nil && *target.Escape
+}
+
+// delimitEnabled reports whether each partial is wrapped in begin and end lines
+func delimitEnabled(target src.TargetConfig) bool {
+	return target.Delimit != nil && *target.Delimit
+}
+
+// targetTemplateData returns the data to render the target's partials with,
+// or nil when the target is not templated
+func targetTemplateData(target src.TargetConfig) *src.TemplateData {
+	if !target.Template {
+		return nil
+	}
+	data := src.NewTemplateData(target.Vars)
+	return &data
+}
+
+// appliedMessage describes a written target the way the build and own commands do
+func appliedMessage(target src.TargetConfig, change *src.FileChange) string {
+	if target.Mode == "own" {
+		return fmt.Sprintf("Wrote %d partial(s) to '%s' (own mode)", len(change.Partials), change.Path)
+	}
+	return fmt.Sprintf("Merged %d partial(s) into '%s'", len(change.Partials), change.Path)
+}
@@ -0,0 +1,247 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"github.com/cageis/parts/src"
+	"github.com/spf13/cobra"
+)
+
+// watchManifestPath allows tests to override the manifest location
+var watchManifestPath string
+
+func newWatchCmd() *cobra.Command {
+	var interval, debounce time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "watch [target-name...]",
+		Short: "Rebuild manifest targets whenever their partials change",
+		Long: `Watches the partials directory of every target in .parts.yaml, and the
+manifest itself, and re-applies a target when its partials change.
+
+Files are polled every --interval, so no daemon or OS notification service
+is needed. A target is rebuilt once its partials have been quiet for
+--debounce, so a burst of editor writes causes a single rebuild.
+
+Each rebuild is logged. Errors are logged and watching continues. When the
+manifest changes it is reloaded and targets whose configuration changed are
+rebuilt; an invalid manifest is reported and the previous one kept.
+A target's pre_apply and post_apply hooks run around each rebuild.
+
+Press Ctrl-C to stop.`,
+		Example: `  parts watch                 # Watch every target
+  parts watch ssh git         # Watch only the 'ssh' and 'git' targets
+  parts watch --interval 2s   # Poll less often`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if interval <= 0 {
+				return fmt.Errorf("--interval must be positive")
+			}
+			if debounce < 0 {
+				return fmt.Errorf("--debounce must not be negative")
+			}
+
+			manifestPath := watchManifestPath
+			if manifestPath == "" {
+				manifestPath = resolveManifestPath()
+			}
+			absManifest, err := filepath.Abs(manifestPath)
+			if err != nil {
+				return fmt.Errorf("failed to resolve manifest path: %w", err)
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+			defer stop()
+			return runWatch(ctx, cmd, absManifest, args, interval, debounce)
+		},
+	}
+
+	cmd.Flags().DurationVar(&interval, "interval", src.DefaultWatchInterval, "how often to check for changes")
+	cmd.Flags().DurationVar(&debounce, "debounce", src.DefaultWatchDebounce, "how long files must stay unchanged before a rebuild")
+	return cmd
+}
+
+// watchSession holds the manifest being watched and the targets selected from it
+type watchSession struct {
+	cmd          *cobra.Command
+	manifestPath string
+	args         []string
+	manifest     *src.Manifest
+	targets      map[string]src.TargetConfig
+	partials     *src.Watcher
+}
+
+// runWatch polls until ctx is cancelled. It fails only if the manifest can't
+// be loaded at start; later errors are logged.
+func runWatch(ctx context.Context, cmd *cobra.Command, manifestPath string, args []string, interval, debounce time.Duration) error {
+	manifest, err := src.LoadManifest(manifestPath)
+	if err != nil {
+		return err
+	}
+
+	session := &watchSession{
+		cmd:          cmd,
+		manifestPath: manifestPath,
+		args:         args,
+		targets:      map[string]src.TargetConfig{},
+		partials:     src.NewWatcher(debounce),
+	}
+	if _, err := session.load(manifest); err != nil {
+		return err
+	}
+
+	manifestWatcher := src.NewWatcher(debounce)
+	if err := manifestWatcher.Watch("manifest", manifestPath); err != nil {
+		return err
+	}
+
+	session.logf("Watching %d target(s) from '%s'", len(session.targets), manifestPath)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			session.logf("Stopped watching")
+			return nil
+		case now := <-ticker.C:
+			reload, err := manifestWatcher.Poll(now)
+			if err != nil {
+				session.logf("Error: %v", err)
+			}
+			if len(reload) > 0 {
+				session.reload()
+			}
+
+			names, err := session.partials.Poll(now)
+			if err != nil {
+				session.logf("Error: %v", err)
+			}
+			for _, name := range names {
+				session.rebuild(name)
+			}
+		}
+	}
+}
+
+// load selects the targets to watch from manifest and starts watching their
+// partials. Returns the names of targets that are new or whose configuration changed.
+func (s *watchSession) load(manifest *src.Manifest) ([]string, error) {
+	names, err := manifest.FilterTargets(s.args)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make(map[string]src.TargetConfig, len(names))
+	var changed []string
+	for _, name := range names {
+		target := manifest.ResolvedTarget(name)
+		targets[name] = target
+		if previous, ok := s.targets[name]; ok && reflect.DeepEqual(previous, target) {
+			continue
+		}
+
+		partialsDir, expandErr := src.ExpandTildePrefix(target.Partials)
+		if expandErr != nil {
+			return nil, fmt.Errorf("target '%s': %w", name, expandErr)
+		}
+		if watchErr := s.partials.Watch(name, partialsDir); watchErr != nil {
+			return nil, fmt.Errorf("target '%s': %w", name, watchErr)
+		}
+		changed = append(changed, name)
+	}
+	for name := range s.targets {
+		if _, ok := targets[name]; !ok {
+			s.partials.Unwatch(name)
+		}
+	}
+
+	s.manifest = manifest
+	s.targets = targets
+	return changed, nil
+}
+
+// reload re-reads the manifest and rebuilds targets whose configuration
+// changed, keeping the previous manifest if the new one is invalid
+func (s *watchSession) reload() {
+	manifest, err := src.LoadManifest(s.manifestPath)
+	if err != nil {
+		s.logf("Error: %v (keeping the previous manifest)", err)
+		return
+	}
+	changed, err := s.load(manifest)
+	if err != nil {
+		s.logf("Error: %v (keeping the previous manifest)", err)
+		return
+	}
+	s.logf("Reloaded manifest, watching %d target(s)", len(s.targets))
+	for _, name := range changed {
+		s.rebuild(name)
+	}
+}
+
+// rebuild re-applies one target and logs the outcome. It writes the target
+// before returning, so the next target sharing its file renders on top of it.
+func (s *watchSession) rebuild(name string) {
+	target := s.targets[name]
+	if ok, reason := target.When.Match(src.CurrentFacts()); !ok {
+		s.logf("Skipped '%s': %s", name, reason)
+		return
+	}
+
+	backups, err := targetBackupStore(s.manifest, target)
+	if err != nil {
+		s.logf("Error: target '%s': %v", name, err)
+		return
+	}
+	targetCmd, err := newTargetCommand(target)
+	if err != nil {
+		s.logf("Error: target '%s': %v", name, err)
+		return
+	}
+	change, err := targetCmd.Plan()
+	if err != nil {
+		s.logf("Error: target '%s': %v", name, err)
+		return
+	}
+	if !change.Changed() {
+		s.logf("'%s' is up to date", name)
+		return
+	}
+	if err := targetCmd.Validate(change); err != nil {
+		s.logf("Error: target '%s': %v", name, err)
+		return
+	}
+	if err := targetCmd.CheckEdits(change); err != nil {
+		s.logf("Error: target '%s': %v", name, err)
+		return
+	}
+
+	if err := runHook(s.cmd, s.manifest, name, target, src.HookPreApply, change.Path, true); err != nil {
+		s.logf("Error: target '%s': %v", name, err)
+		return
+	}
+
+	var tx src.Transaction
+	tx.Add(change, backups)
+	if err := tx.Commit(); err != nil {
+		s.logf("Error: target '%s': %v", name, err)
+		return
+	}
+	recordTargets(s.cmd, s.manifest, []string{name}, "apply")
+	s.logf("%s", appliedMessage(target, change))
+
+	if err := runHook(s.cmd, s.manifest, name, target, src.HookPostApply, change.Path, true); err != nil {
+		s.logf("Error: target '%s': %v", name, err)
+	}
+}
+
+// logf writes a timestamped line to the command's output
+func (s *watchSession) logf(format string, args ...interface{}) {
+	fmt.Fprintf(s.cmd.OutOrStdout(), "%s %s\n", time.Now().Format("15:04:05"), fmt.Sprintf(format, args...))
+}
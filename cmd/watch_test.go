@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a bytes.Buffer safe to read while the watch loop writes to it
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// waitFor polls cond until it holds or the test times out
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func fileContains(path, text string) func() bool {
+	return func() bool {
+		content, err := os.ReadFile(path)
+		return err == nil && strings.Contains(string(content), text)
+	}
+}
+
+func TestWatchCommand(t *testing.T) {
+	dir := t.TempDir()
+	manifestPath, targetFile, partialsDir := writeBackupManifest(t, dir)
+
+	var out syncBuffer
+	watchCmd := newWatchCmd()
+	watchCmd.SetOut(&out)
+	watchCmd.SetErr(&out)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- runWatch(ctx, watchCmd, manifestPath, nil, 10*time.Millisecond, 20*time.Millisecond)
+	}()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Watch failed: %v", err)
+		}
+	}()
+	waitFor(t, "watch to start", func() bool { return strings.Contains(out.String(), "Watching 1 target(s)") })
+
+	// Editing a partial rebuilds its target
+	if err := os.WriteFile(filepath.Join(partialsDir, "work"), []byte("Host work-edited\n"), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	waitFor(t, "the target to be rebuilt", fileContains(targetFile, "Host work-edited"))
+	waitFor(t, "the rebuild to be logged", func() bool { return strings.Contains(out.String(), "Merged 1 partial(s) into") })
+
+	// An invalid manifest is reported and the previous one kept
+	if err := os.WriteFile(manifestPath, []byte("targets: ["), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	waitFor(t, "the reload error", func() bool { return strings.Contains(out.String(), "keeping the previous manifest") })
+
+	// A fixed manifest with a new target is reloaded and the new target built
+	otherPartials := filepath.Join(dir, "git")
+	if err := os.MkdirAll(otherPartials, 0755); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(otherPartials, "user"), []byte("[user]\n"), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	otherTarget := filepath.Join(dir, "gitconfig")
+	manifest := "targets:\n" +
+		"  ssh:\n    target: " + targetFile + "\n    partials: " + partialsDir + "\n    comment: \"#\"\n" +
+		"  git:\n    target: " + otherTarget + "\n    partials: " + otherPartials + "\n    mode: own\n"
+	if err := os.WriteFile(manifestPath, []byte(manifest), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	waitFor(t, "the reload", func() bool { return strings.Contains(out.String(), "Reloaded manifest, watching 2 target(s)") })
+	waitFor(t, "the new target to be built", fileContains(otherTarget, "[user]"))
+
+	// Errors are logged and watching continues
+	if err := os.RemoveAll(otherPartials); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	waitFor(t, "the failed rebuild to be logged", func() bool { return strings.Contains(out.String(), "Error: target 'git'") })
+	if err := os.WriteFile(filepath.Join(partialsDir, "work"), []byte("Host work-again\n"), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	waitFor(t, "watching to continue", fileContains(targetFile, "Host work-again"))
+}
@@ -0,0 +1,76 @@
+package src
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultAdoptName names partials adopted from orphaned target content
+const DefaultAdoptName = "adopted-{n}"
+
+// OrphanedChunk is content in a target's managed block that belongs to no
+// partial: lines before the first source comment, or a section whose source
+// comment names a file that isn't one of the target's partials
+type OrphanedChunk struct {
+	Source  string // source comment path; empty for lines before the first one
+	Line    int    // first line of the chunk in the target file, 1-based
+	Content string
+	Adopted string // partial file the chunk is written to when adopted
+}
+
+// ValidateAdoptName checks an adopt_name pattern. It is a path relative to
+// the partials directory in which {n} is replaced by the first number that
+// gives a free file name and {name} by the base name of the chunk's source
+// comment ("adopted" for lines before the first one).
+func ValidateAdoptName(pattern string) error {
+	if pattern == "" {
+		return nil
+	}
+	if filepath.IsAbs(pattern) || strings.HasPrefix(pattern, "/") {
+		return fmt.Errorf("invalid adopt_name '%s': must be relative to the partials directory", pattern)
+	}
+	for _, segment := range strings.Split(filepath.ToSlash(pattern), "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return fmt.Errorf("invalid adopt_name '%s': must not contain empty, '.' or '..' path segments", pattern)
+		}
+	}
+	return nil
+}
+
+// adoptName picks the partial file an orphaned chunk is adopted into,
+// avoiding existing files and the names in taken. The name must be one the
+// target reads as a partial, or the chunk would be lost on the next apply.
+func adoptName(fsys FS, partialsDir, pattern string, opts PartialOptions, chunk OrphanedChunk, taken map[string]bool) (string, error) {
+	if pattern == "" {
+		pattern = DefaultAdoptName
+	}
+	name := "adopted"
+	if chunk.Source != "" {
+		name = filepath.Base(chunk.Source)
+	}
+	rel := strings.ReplaceAll(filepath.ToSlash(pattern), "{name}", name)
+
+	for n := 1; ; n++ {
+		candidate := strings.ReplaceAll(rel, "{n}", strconv.Itoa(n))
+		selected, err := opts.Selects(path.Clean(candidate))
+		if err != nil {
+			return "", err
+		}
+		if !selected {
+			return "", fmt.Errorf("adopted partial '%s' would not be read by the target (check adopt_name against recursive, include and exclude)", candidate)
+		}
+
+		full := filepath.Join(partialsDir, filepath.FromSlash(candidate))
+		if _, statErr := fsys.Stat(full); errors.Is(statErr, fs.ErrNotExist) && !taken[full] {
+			return full, nil
+		}
+		if !strings.Contains(rel, "{n}") {
+			return "", fmt.Errorf("adopted partial '%s' already exists (add {n} to adopt_name)", full)
+		}
+	}
+}
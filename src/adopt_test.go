@@ -0,0 +1,135 @@
+package src
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateAdoptName(t *testing.T) {
+	for _, pattern := range []string{"", "adopted-{n}", "{name}", "inbox/{name}-{n}.conf"} {
+		if err := ValidateAdoptName(pattern); err != nil {
+			t.Errorf("Expected '%s' to be valid: %v", pattern, err)
+		}
+	}
+	for _, pattern := range []string{"/tmp/{n}", "../{n}", "a//b", "./x"} {
+		if err := ValidateAdoptName(pattern); err == nil {
+			t.Errorf("Expected '%s' to be rejected", pattern)
+		}
+	}
+}
+
+func TestExtractOrphanedChunks(t *testing.T) {
+	content := "# PARTIALS>>>>>\n\nHost stray\n    User me\n# Source: /tmp/partials/work\nHost work\n"
+	orphans := ExtractOrphanedChunks(content, "#")
+	if len(orphans) != 1 {
+		t.Fatalf("Expected 1 orphaned chunk, got %+v", orphans)
+	}
+	if orphans[0].Line != 3 || orphans[0].Content != "Host stray\n    User me\n" {
+		t.Errorf("Unexpected chunk: %+v", orphans[0])
+	}
+
+	if orphans := ExtractOrphanedChunks("\n# Source: /tmp/partials/work\nHost work\n", "#"); len(orphans) != 0 {
+		t.Errorf("Blank lines are not orphaned content, got %+v", orphans)
+	}
+}
+
+// strayTarget applies one partial, then adds a line above its source comment
+// and a hand-written section to the target
+func strayTarget(t *testing.T) (targetFile, partialsDir string) {
+	t.Helper()
+	targetFile, partialsDir, _ = appliedSyncFixture(t, map[string]string{"work": "Host work\n"})
+	source := "# Source: " + filepath.Join(partialsDir, "work") + "\n"
+	handWritten := "# Source: " + filepath.Join(partialsDir, "bastion") + "\nHost bastion\n"
+	replaceInFile(t, targetFile, source, "Host stray\n"+source)
+	replaceInFile(t, targetFile, "Host work\n", "Host work\n"+handWritten)
+	return targetFile, partialsDir
+}
+
+func TestSyncTarget_ReportsOrphanedContent(t *testing.T) {
+	targetFile, partialsDir := strayTarget(t)
+
+	result, err := SyncTarget(targetFile, partialsDir, "#", "merge", false)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if len(result.Orphaned) != 2 || len(result.Unadopted()) != 2 {
+		t.Fatalf("Expected 2 unadopted chunks, got %+v", result.Orphaned)
+	}
+	if result.Orphaned[0].Source != "" || result.Orphaned[0].Line != 5 {
+		t.Errorf("Expected the stray line first, at line 5: %+v", result.Orphaned[0])
+	}
+	if filepath.Base(result.Orphaned[1].Source) != "bastion" {
+		t.Errorf("Expected the hand-written section second: %+v", result.Orphaned[1])
+	}
+	if !result.NeedsApply() {
+		t.Error("Orphaned content should keep the target out of sync")
+	}
+	entries, _ := os.ReadDir(partialsDir)
+	if len(entries) != 1 {
+		t.Errorf("Nothing should be written without adopt, got %d file(s)", len(entries))
+	}
+}
+
+func TestSyncTarget_AdoptsOrphanedContent(t *testing.T) {
+	targetFile, partialsDir := strayTarget(t)
+	// An existing file pushes the first chunk to the next free number
+	writeTree(t, partialsDir, map[string]string{"adopted-1": "Host older\n"})
+
+	command := NewPartialsSyncCommand(targetFile, partialsDir, "#", "merge")
+	if err := command.SetAdopt(true, ""); err != nil {
+		t.Fatalf("SetAdopt failed: %v", err)
+	}
+	result, err := command.Run()
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if result.UpdatedFiles != 2 || len(result.Unadopted()) != 0 {
+		t.Fatalf("Expected both chunks adopted, got %+v", result)
+	}
+
+	for name, want := range map[string]string{"adopted-2": "Host stray\n", "adopted-3": "Host bastion\n"} {
+		got, readErr := os.ReadFile(filepath.Join(partialsDir, name))
+		if readErr != nil || string(got) != want {
+			t.Errorf("Expected %s to hold %q, got %q (%v)", name, want, got, readErr)
+		}
+	}
+
+	// After the next build nothing is left over
+	buildCmd, _ := NewPartialsBuildCommand(targetFile, partialsDir, "#")
+	if err := buildCmd.Run(); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	result, err = SyncTarget(targetFile, partialsDir, "#", "merge", false)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if len(result.Orphaned) != 0 || result.UpdatedFiles != 0 {
+		t.Errorf("Expected the target to be in sync after adopting, got %+v", result)
+	}
+}
+
+func TestSyncTarget_AdoptNamePattern(t *testing.T) {
+	targetFile, partialsDir := strayTarget(t)
+
+	command := NewPartialsSyncCommand(targetFile, partialsDir, "#", "merge")
+	command.SetPartialOptions(PartialOptions{Recursive: true})
+	if err := command.SetAdopt(true, "inbox/{name}"); err != nil {
+		t.Fatalf("SetAdopt failed: %v", err)
+	}
+	if _, err := command.Run(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	for _, name := range []string{"adopted", "bastion"} {
+		if _, err := os.Stat(filepath.Join(partialsDir, "inbox", name)); err != nil {
+			t.Errorf("Expected inbox/%s to be adopted: %v", name, err)
+		}
+	}
+
+	// A name the target wouldn't read is refused rather than lost
+	command.SetPartialOptions(PartialOptions{Include: []string{"*.conf"}})
+	if _, _, err := command.Plan(); err == nil || !strings.Contains(err.Error(), "would not be read") {
+		t.Errorf("Expected an unselected adopt name to be rejected, got %v", err)
+	}
+}
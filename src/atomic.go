@@ -0,0 +1,190 @@
+package src
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileChange is the rendered result of a command, ready to be written.
+// Original and Existed describe the file as it was when the change was planned,
+// so a written change can be rolled back. Skipped lists partials left out
+// because their condition doesn't hold on this machine.
+type FileChange struct {
+	Path         string
+	Content      []byte
+	Mode         fs.FileMode
+	Original     []byte
+	OriginalMode fs.FileMode
+	Existed      bool
+	Partials     []string
+	Skipped      []SkippedPartial
+}
+
+// Changed reports whether writing the change would modify the file
+func (c *FileChange) Changed() bool {
+	return !c.Existed || string(c.Original) != string(c.Content) || c.OriginalMode.Perm() != c.Mode.Perm()
+}
+
+// WriteFileAtomic writes data to a temporary file in the same directory as
+// path, fsyncs it and renames it into place, so readers see either the old
+// or the new content and a crash never leaves a truncated file behind.
+// Symlinks are followed so the link itself is preserved, and the owner of an
+// existing file is kept where the platform allows it.
+func WriteFileAtomic(path string, data []byte, mode fs.FileMode) error {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	existing, statErr := os.Stat(path)
+
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".parts-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file in '%s': %w", dir, err)
+	}
+	tmpName := tmp.Name()
+	// Clean up the temporary file on any failure before the rename
+	committed := false
+	defer func() {
+		if !committed {
+			_ = tmp.Close()
+			_ = os.Remove(tmpName)
+		}
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		return fmt.Errorf("failed to write temporary file '%s': %w", tmpName, err)
+	}
+	if err := tmp.Chmod(mode.Perm()); err != nil {
+		return fmt.Errorf("failed to set mode on temporary file '%s': %w", tmpName, err)
+	}
+	if statErr == nil {
+		preserveOwner(tmp, existing)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("failed to sync temporary file '%s': %w", tmpName, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temporary file '%s': %w", tmpName, err)
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		return fmt.Errorf("failed to rename '%s' to '%s': %w", tmpName, path, err)
+	}
+	committed = true
+
+	// Persist the rename itself; not every platform supports syncing directories
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		_ = d.Close()
+	}
+	return nil
+}
+
+// Transaction writes a set of file changes all-or-nothing: if any write
+// fails, the changes already written are rolled back.
+type Transaction struct {
+	changes []*FileChange
+	backups []*BackupStore
+	fsys    FS
+}
+
+// SetFS writes and rolls back the changes on fsys instead of the real
+// filesystem. Backups are still stored on disk.
+func (t *Transaction) SetFS(fsys FS) {
+	t.fsys = fsys
+}
+
+// Add queues a change, backing the file up to store (if non-nil) before it is written
+func (t *Transaction) Add(change *FileChange, store *BackupStore) {
+	t.changes = append(t.changes, change)
+	t.backups = append(t.backups, store)
+}
+
+// Len returns the number of queued changes
+func (t *Transaction) Len() int {
+	return len(t.changes)
+}
+
+// Commit writes every queued change in order. On failure the changes written
+// so far are restored to their original content and the error is returned.
+func (t *Transaction) Commit() error {
+	fsys := fileSystem(t.fsys)
+	for i, change := range t.changes {
+		// A file changed twice is backed up once, before its first change
+		store := t.backups[i]
+		if t.changedBefore(i) {
+			store = nil
+		}
+		if err := writeChange(fsys, change, store); err != nil {
+			if rollbackErr := t.rollback(i); rollbackErr != nil {
+				return fmt.Errorf("%w (rollback failed: %v)", err, rollbackErr)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// rollback restores the files of the first n changes, newest first. A file
+// changed more than once gets the content it had before its first change.
+func (t *Transaction) rollback(n int) error {
+	fsys := fileSystem(t.fsys)
+	var failed []string
+	for i := n - 1; i >= 0; i-- {
+		if t.changedBefore(i) {
+			continue
+		}
+		change := t.changes[i]
+		var err error
+		if change.Existed {
+			err = fsys.WriteFile(change.Path, change.Original, change.OriginalMode)
+		} else {
+			err = fsys.Remove(change.Path)
+		}
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			failed = append(failed, err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// changedBefore reports whether a change before the i-th one writes the same file
+func (t *Transaction) changedBefore(i int) bool {
+	path := filepath.Clean(t.changes[i].Path)
+	for _, change := range t.changes[:i] {
+		if filepath.Clean(change.Path) == path {
+			return true
+		}
+	}
+	return false
+}
+
+// writeChange backs up and writes a single change to fsys, atomically on disk
+func writeChange(fsys FS, change *FileChange, store *BackupStore) error {
+	if err := backupBeforeWrite(fsys, store, change.Path); err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(change.Path)
+	if err := fsys.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory '%s': %w", dir, err)
+	}
+
+	if err := fsys.WriteFile(change.Path, change.Content, change.Mode); err != nil {
+		return fmt.Errorf("failed to write '%s': %w", change.Path, err)
+	}
+	return nil
+}
+
+// CommitChange writes a single change atomically, backing it up to store if non-nil
+func CommitChange(change *FileChange, store *BackupStore) error {
+	var tx Transaction
+	tx.Add(change, store)
+	return tx.Commit()
+}
@@ -0,0 +1,136 @@
+package src
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomic_ReplacesContentAndMode(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config")
+	if err := os.WriteFile(path, []byte("old\n"), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	if err := WriteFileAtomic(path, []byte("new\n"), 0600); err != nil {
+		t.Fatalf("WriteFileAtomic failed: %v", err)
+	}
+
+	content, _ := os.ReadFile(path)
+	if string(content) != "new\n" {
+		t.Errorf("Expected new content, got %q", content)
+	}
+	info, _ := os.Stat(path)
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %o", info.Mode().Perm())
+	}
+
+	// No temporary files are left behind
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("Expected only the target file in %s, got %d entries", dir, len(entries))
+	}
+}
+
+func TestWriteFileAtomic_FollowsSymlink(t *testing.T) {
+	dir := t.TempDir()
+	real := filepath.Join(dir, "dotfiles-config")
+	link := filepath.Join(dir, "config")
+	if err := os.WriteFile(real, []byte("old\n"), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if err := os.Symlink(real, link); err != nil {
+		t.Skipf("Symlinks not supported: %v", err)
+	}
+
+	if err := WriteFileAtomic(link, []byte("new\n"), 0644); err != nil {
+		t.Fatalf("WriteFileAtomic failed: %v", err)
+	}
+
+	info, err := os.Lstat(link)
+	if err != nil {
+		t.Fatalf("Lstat failed: %v", err)
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		t.Error("Symlink should be preserved")
+	}
+	content, _ := os.ReadFile(real)
+	if string(content) != "new\n" {
+		t.Errorf("Expected link target to be updated, got %q", content)
+	}
+}
+
+func TestTransaction_RollsBackOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first")
+	created := filepath.Join(dir, "created")
+	if err := os.WriteFile(first, []byte("original\n"), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	// A regular file where a directory is needed makes the last write fail
+	blocker := filepath.Join(dir, "blocker")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	var tx Transaction
+	tx.Add(&FileChange{Path: first, Content: []byte("changed\n"), Mode: 0644,
+		Original: []byte("original\n"), OriginalMode: 0644, Existed: true}, nil)
+	tx.Add(&FileChange{Path: created, Content: []byte("new\n"), Mode: 0644}, nil)
+	tx.Add(&FileChange{Path: filepath.Join(blocker, "target"), Content: []byte("x\n"), Mode: 0644}, nil)
+
+	if err := tx.Commit(); err == nil {
+		t.Fatal("Expected commit to fail")
+	}
+
+	content, _ := os.ReadFile(first)
+	if string(content) != "original\n" {
+		t.Errorf("Expected first file rolled back, got %q", content)
+	}
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Error("Expected newly created file to be removed on rollback")
+	}
+}
+
+func TestTransaction_UsesFS(t *testing.T) {
+	fsys := NewMemFS()
+	memTree(t, fsys, map[string]string{"/mem/first": "original\n", "/mem/blocker": ""})
+
+	var tx Transaction
+	tx.SetFS(fsys)
+	tx.Add(&FileChange{Path: "/mem/first", Content: []byte("changed\n"), Mode: 0644,
+		Original: []byte("original\n"), OriginalMode: 0644, Existed: true}, nil)
+	tx.Add(&FileChange{Path: "/mem/created", Content: []byte("new\n"), Mode: 0644}, nil)
+	tx.Add(&FileChange{Path: "/mem/blocker/target", Content: []byte("x\n"), Mode: 0644}, nil)
+
+	if err := tx.Commit(); err == nil {
+		t.Fatal("Expected commit to fail")
+	}
+	if got := readMem(t, fsys, "/mem/first"); got != "original\n" {
+		t.Errorf("Expected first file rolled back, got %q", got)
+	}
+	if _, err := fsys.Stat("/mem/created"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected newly created file to be removed on rollback, got %v", err)
+	}
+	if _, err := os.Stat("/mem"); !os.IsNotExist(err) {
+		t.Errorf("The transaction must not touch the real filesystem: %v", err)
+	}
+}
+
+func TestFileChange_Changed(t *testing.T) {
+	unchanged := &FileChange{Content: []byte("a"), Original: []byte("a"), Existed: true, Mode: 0644, OriginalMode: 0644}
+	if unchanged.Changed() {
+		t.Error("Identical content and mode should not be a change")
+	}
+	modified := &FileChange{Content: []byte("b"), Original: []byte("a"), Existed: true, Mode: 0644, OriginalMode: 0644}
+	if !modified.Changed() {
+		t.Error("Different content should be a change")
+	}
+	created := &FileChange{Content: []byte("a")}
+	if !created.Changed() {
+		t.Error("A new file should be a change")
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+// +build !windows
+
+package src
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// preserveOwner gives f the owner and group of the file described by info.
+// Failures are ignored: only privileged processes may change ownership, and
+// an unprivileged process already owns the files it rewrites.
+func preserveOwner(f *os.File, info fs.FileInfo) {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		_ = f.Chown(int(stat.Uid), int(stat.Gid))
+	}
+}
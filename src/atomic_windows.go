@@ -0,0 +1,12 @@
+//go:build windows
+// +build windows
+
+package src
+
+import (
+	"io/fs"
+	"os"
+)
+
+// preserveOwner is a no-op on Windows, where renamed files keep the ACLs of their directory
+func preserveOwner(f *os.File, info fs.FileInfo) {}
@@ -0,0 +1,319 @@
+package src
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// DefaultBackupKeep is the number of backups retained per file when no
+// retention is configured
+const DefaultBackupKeep = 10
+
+// backupIDFormat is a fixed-width UTC timestamp, so IDs sort chronologically
+const backupIDFormat = "20060102T150405.000000000Z"
+
+// Backup describes a single stored copy of a file
+type Backup struct {
+	ID      string
+	Path    string
+	File    string
+	Created time.Time
+	Size    int64
+	Mode    fs.FileMode
+}
+
+// BackupStore keeps timestamped copies of files before Parts modifies them.
+// Each original path gets its own directory (the URL-escaped absolute path),
+// holding one file per backup named after its timestamp ID.
+type BackupStore struct {
+	dir  string
+	keep int
+}
+
+// DefaultBackupDir returns $XDG_STATE_HOME/parts/backups, falling back to
+// ~/.local/state/parts/backups
+func DefaultBackupDir() (string, error) {
+	home, err := stateHome()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "backups"), nil
+}
+
+// stateHome returns $XDG_STATE_HOME/parts, falling back to ~/.local/state/parts
+func stateHome() (string, error) {
+	if xdg := os.Getenv("XDG_STATE_HOME"); xdg != "" {
+		return filepath.Join(xdg, "parts"), nil
+	}
+	home, err := resolveHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "state", "parts"), nil
+}
+
+// NewBackupStore creates a backup store rooted at dir.
+// An empty dir selects DefaultBackupDir; keep <= 0 disables pruning.
+func NewBackupStore(dir string, keep int) (*BackupStore, error) {
+	if dir == "" {
+		defaultDir, err := DefaultBackupDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve backup directory: %w", err)
+		}
+		dir = defaultDir
+	}
+	expanded, err := ExpandTildePrefix(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand backup directory path: %w", err)
+	}
+	return &BackupStore{dir: expanded, keep: keep}, nil
+}
+
+// Dir returns the root directory of the store
+func (s *BackupStore) Dir() string {
+	return s.dir
+}
+
+// Keep returns the number of backups retained per file
+func (s *BackupStore) Keep() int {
+	return s.keep
+}
+
+// pathDir returns the store directory holding backups of the given file
+func (s *BackupStore) pathDir(path string) (string, string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get absolute path for '%s': %w", path, err)
+	}
+	return filepath.Join(s.dir, url.PathEscape(abs)), abs, nil
+}
+
+// Save copies the current content of path into the store and applies the
+// retention policy. Returns nil without error if path does not exist yet.
+func (s *BackupStore) Save(path string) (*Backup, error) {
+	return s.save(OSFS{}, path)
+}
+
+// save is Save reading path from fsys; the store itself is always on disk
+func (s *BackupStore) save(fsys FS, path string) (*Backup, error) {
+	dir, abs, err := s.pathDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := fsys.Stat(abs)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat '%s': %w", abs, err)
+	}
+
+	content, err := fsys.ReadFile(abs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read '%s' for backup: %w", abs, err)
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory '%s': %w", dir, err)
+	}
+
+	created := time.Now().UTC()
+	id := created.Format(backupIDFormat)
+	file := filepath.Join(dir, id)
+	// Two saves within the same nanosecond would collide; nudge forward
+	for {
+		if _, statErr := os.Stat(file); os.IsNotExist(statErr) {
+			break
+		}
+		created = created.Add(time.Nanosecond)
+		id = created.Format(backupIDFormat)
+		file = filepath.Join(dir, id)
+	}
+
+	if err := os.WriteFile(file, content, info.Mode().Perm()); err != nil {
+		return nil, fmt.Errorf("failed to write backup '%s': %w", file, err)
+	}
+
+	if s.keep > 0 {
+		if _, err := s.Prune(abs, s.keep); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Backup{
+		ID:      id,
+		Path:    abs,
+		File:    file,
+		Created: created,
+		Size:    int64(len(content)),
+		Mode:    info.Mode().Perm(),
+	}, nil
+}
+
+// List returns the backups of path, newest first
+func (s *BackupStore) List(path string) ([]Backup, error) {
+	dir, abs, err := s.pathDir(path)
+	if err != nil {
+		return nil, err
+	}
+	return s.listDir(dir, abs)
+}
+
+// ListAll returns every backup in the store, grouped by path and newest first
+func (s *BackupStore) ListAll() ([]Backup, error) {
+	entries, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup directory '%s': %w", s.dir, err)
+	}
+
+	var all []Backup
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		original, unescapeErr := url.PathUnescape(entry.Name())
+		if unescapeErr != nil {
+			continue // not one of ours
+		}
+		backups, listErr := s.listDir(filepath.Join(s.dir, entry.Name()), original)
+		if listErr != nil {
+			return nil, listErr
+		}
+		all = append(all, backups...)
+	}
+	return all, nil
+}
+
+// listDir reads the backups stored in dir for the given original path
+func (s *BackupStore) listDir(dir, original string) ([]Backup, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup directory '%s': %w", dir, err)
+	}
+
+	var backups []Backup
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		created, parseErr := time.Parse(backupIDFormat, entry.Name())
+		if parseErr != nil {
+			continue // not a backup file
+		}
+		info, infoErr := entry.Info()
+		if infoErr != nil {
+			return nil, fmt.Errorf("failed to stat backup '%s': %w", entry.Name(), infoErr)
+		}
+		backups = append(backups, Backup{
+			ID:      entry.Name(),
+			Path:    original,
+			File:    filepath.Join(dir, entry.Name()),
+			Created: created,
+			Size:    info.Size(),
+			Mode:    info.Mode().Perm(),
+		})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].ID > backups[j].ID
+	})
+	return backups, nil
+}
+
+// Get returns the backup of path with the given ID, or the newest if id is empty
+func (s *BackupStore) Get(path, id string) (*Backup, error) {
+	backups, err := s.List(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(backups) == 0 {
+		return nil, fmt.Errorf("no backups found for '%s'", path)
+	}
+	if id == "" {
+		return &backups[0], nil
+	}
+	for i := range backups {
+		if backups[i].ID == id {
+			return &backups[i], nil
+		}
+	}
+	return nil, fmt.Errorf("backup '%s' not found for '%s'", id, path)
+}
+
+// Read returns the stored content of a backup
+func (s *BackupStore) Read(b *Backup) ([]byte, error) {
+	content, err := os.ReadFile(b.File)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup '%s': %w", b.File, err)
+	}
+	return content, nil
+}
+
+// Restore writes the backup with the given ID (newest if empty) back to path
+// atomically, with the mode the file had when it was backed up. The current
+// content of path is backed up first so a restore can be undone.
+func (s *BackupStore) Restore(path, id string) (*Backup, error) {
+	backup, err := s.Get(path, id)
+	if err != nil {
+		return nil, err
+	}
+	content, err := s.Read(backup)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.Save(backup.Path); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(backup.Path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory for '%s': %w", backup.Path, err)
+	}
+	if err := WriteFileAtomic(backup.Path, content, backup.Mode); err != nil {
+		return nil, fmt.Errorf("failed to restore '%s': %w", backup.Path, err)
+	}
+	return backup, nil
+}
+
+// Prune removes all but the newest keep backups of path and returns the removed ones
+func (s *BackupStore) Prune(path string, keep int) ([]Backup, error) {
+	backups, err := s.List(path)
+	if err != nil {
+		return nil, err
+	}
+	if keep < 0 || len(backups) <= keep {
+		return nil, nil
+	}
+
+	removed := backups[keep:]
+	for _, b := range removed {
+		if err := os.Remove(b.File); err != nil {
+			return nil, fmt.Errorf("failed to remove backup '%s': %w", b.File, err)
+		}
+	}
+	return removed, nil
+}
+
+// backupBeforeWrite saves a copy of path on fsys when a store is configured
+func backupBeforeWrite(fsys FS, store *BackupStore, path string) error {
+	if store == nil {
+		return nil
+	}
+	if _, err := store.save(fsys, path); err != nil {
+		return fmt.Errorf("failed to back up '%s': %w", path, err)
+	}
+	return nil
+}
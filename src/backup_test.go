@@ -0,0 +1,239 @@
+package src
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBackupStore_SaveAndList(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "config")
+	if err := os.WriteFile(target, []byte("first\n"), 0600); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	store, err := NewBackupStore(filepath.Join(dir, "backups"), 0)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	first, err := store.Save(target)
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := os.WriteFile(target, []byte("second\n"), 0600); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	second, err := store.Save(target)
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	backups, err := store.List(target)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("Expected 2 backups, got %d", len(backups))
+	}
+	if backups[0].ID != second.ID || backups[1].ID != first.ID {
+		t.Errorf("Expected newest first, got %s, %s", backups[0].ID, backups[1].ID)
+	}
+	if backups[0].Mode != 0600 {
+		t.Errorf("Expected backup to keep mode 0600, got %o", backups[0].Mode)
+	}
+
+	content, err := store.Read(&backups[1])
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if string(content) != "first\n" {
+		t.Errorf("Unexpected backup content: %q", content)
+	}
+
+	all, err := store.ListAll()
+	if err != nil {
+		t.Fatalf("ListAll failed: %v", err)
+	}
+	if len(all) != 2 || all[0].Path != target {
+		t.Errorf("Expected ListAll to report 2 backups of %s, got %+v", target, all)
+	}
+}
+
+func TestBackupStore_SaveMissingFile(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewBackupStore(filepath.Join(dir, "backups"), 0)
+
+	backup, err := store.Save(filepath.Join(dir, "does-not-exist"))
+	if err != nil {
+		t.Fatalf("Save of missing file should not fail: %v", err)
+	}
+	if backup != nil {
+		t.Error("Expected no backup for a missing file")
+	}
+}
+
+func TestBackupStore_Retention(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "hosts")
+	store, _ := NewBackupStore(filepath.Join(dir, "backups"), 2)
+
+	for _, content := range []string{"a", "b", "c", "d"} {
+		if err := os.WriteFile(target, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed: %v", err)
+		}
+		if _, err := store.Save(target); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+
+	backups, _ := store.List(target)
+	if len(backups) != 2 {
+		t.Fatalf("Expected retention to keep 2 backups, got %d", len(backups))
+	}
+	newest, _ := store.Read(&backups[0])
+	if string(newest) != "d" {
+		t.Errorf("Expected newest backup 'd', got %q", newest)
+	}
+
+	removed, err := store.Prune(target, 1)
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if len(removed) != 1 {
+		t.Errorf("Expected 1 pruned backup, got %d", len(removed))
+	}
+}
+
+func TestBackupStore_Restore(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "config")
+	if err := os.WriteFile(target, []byte("original\n"), 0600); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	store, _ := NewBackupStore(filepath.Join(dir, "backups"), 0)
+	saved, _ := store.Save(target)
+
+	if err := os.WriteFile(target, []byte("broken\n"), 0600); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if err := os.Chmod(target, 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	restored, err := store.Restore(target, saved.ID)
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if restored.ID != saved.ID {
+		t.Errorf("Expected restore of %s, got %s", saved.ID, restored.ID)
+	}
+
+	content, _ := os.ReadFile(target)
+	if string(content) != "original\n" {
+		t.Errorf("Expected restored content, got %q", content)
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		t.Fatalf("Failed to stat restored file: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the backed up mode 0600, got %o", info.Mode().Perm())
+	}
+
+	// The overwritten content is kept so the restore can be undone
+	latest, err := store.Get(target, "")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	undo, _ := store.Read(latest)
+	if string(undo) != "broken\n" {
+		t.Errorf("Expected pre-restore content to be backed up, got %q", undo)
+	}
+
+	if _, err := store.Get(target, "no-such-id"); err == nil {
+		t.Error("Expected error for unknown backup ID")
+	}
+}
+
+func TestPartialsBuildCommand_Backup(t *testing.T) {
+	aggregateFile, _, command := testSetup(t)
+	original, _ := os.ReadFile(aggregateFile)
+
+	store, _ := NewBackupStore(filepath.Join(t.TempDir(), "backups"), 0)
+	command.SetBackupStore(store)
+	if err := command.Run(); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	backup, err := store.Get(aggregateFile, "")
+	if err != nil {
+		t.Fatalf("Expected a backup after build: %v", err)
+	}
+	content, _ := store.Read(backup)
+	if string(content) != string(original) {
+		t.Errorf("Backup should hold the pre-build content, got %q", content)
+	}
+}
+
+func TestPartialsBuildCommand_DryRunSkipsBackup(t *testing.T) {
+	aggregateFile, _, command := testSetup(t)
+
+	store, _ := NewBackupStore(filepath.Join(t.TempDir(), "backups"), 0)
+	command.SetBackupStore(store)
+	command.SetDryRun(true)
+	if err := command.Run(); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	backups, _ := store.List(aggregateFile)
+	if len(backups) != 0 {
+		t.Errorf("Dry run should not create backups, got %d", len(backups))
+	}
+}
+
+func TestManifest_BackupStore(t *testing.T) {
+	dir := t.TempDir()
+	manifestPath := filepath.Join(dir, ".parts.yaml")
+	backupDir := filepath.Join(dir, "my-backups")
+	yaml := `defaults:
+  backup: true
+  backup_dir: ` + backupDir + `
+  backup_keep: 3
+targets:
+  ssh:
+    target: /tmp/ssh-config
+    partials: ./ssh/
+`
+	if err := os.WriteFile(manifestPath, []byte(yaml), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	manifest, err := LoadManifest(manifestPath)
+	if err != nil {
+		t.Fatalf("Failed to load manifest: %v", err)
+	}
+	store, err := manifest.BackupStore()
+	if err != nil {
+		t.Fatalf("BackupStore failed: %v", err)
+	}
+	if store.Dir() != backupDir {
+		t.Errorf("Expected backup dir %s, got %s", backupDir, store.Dir())
+	}
+	if store.Keep() != 3 {
+		t.Errorf("Expected keep 3, got %d", store.Keep())
+	}
+}
+
+func TestDefaultBackupDir_XDGStateHome(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", "/tmp/state")
+	dir, err := DefaultBackupDir()
+	if err != nil {
+		t.Fatalf("DefaultBackupDir failed: %v", err)
+	}
+	if !strings.HasPrefix(dir, "/tmp/state/parts") {
+		t.Errorf("Expected XDG state location, got %s", dir)
+	}
+}
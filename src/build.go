@@ -2,18 +2,31 @@ package src
 
 import (
 	"fmt"
+	"io"
 	"io/fs"
-	"os"
 	"path/filepath"
 	"strings"
 )
 
 // PartialsBuildCommand handles building/merging partials into aggregate files
 type PartialsBuildCommand struct {
-	aggregateFile string
-	partialsDir   string
-	commentChars  string
-	dryRun        bool
+	aggregateFile  string
+	partialsDir    string
+	commentChars   string
+	sectionID      string
+	placement      Placement
+	partialOptions PartialOptions
+	templateData   *TemplateData
+	validator      *Validator
+	checksum       bool
+	escapeMarkers  bool
+	delimit        bool
+	sourcePaths    SourcePaths
+	force          bool
+	dryRun         bool
+	backups        *BackupStore
+	out            io.Writer
+	fsys           FS
 }
 
 // NewPartialsBuildCommand creates a new build command.
@@ -28,7 +41,11 @@ func NewPartialsBuildCommand(aggregateFile, partialsDir, commentChars string) (P
 		return PartialsBuildCommand{}, fmt.Errorf("failed to expand partials directory path: %w", err)
 	}
 
-	return PartialsBuildCommand{expandedAgg, expandedPartials, commentChars, false}, nil
+	return PartialsBuildCommand{
+		aggregateFile: expandedAgg,
+		partialsDir:   expandedPartials,
+		commentChars:  commentChars,
+	}, nil
 }
 
 // SetDryRun sets the dry-run mode for the build command
@@ -36,6 +53,102 @@ func (p *PartialsBuildCommand) SetDryRun(dryRun bool) {
 	p.dryRun = dryRun
 }
 
+// SetSectionID selects a named PARTIALS section, so several sections can share one file.
+// Returns an error if the ID cannot be embedded in a marker line.
+func (p *PartialsBuildCommand) SetSectionID(id string) error {
+	if err := ValidateSectionID(id); err != nil {
+		return err
+	}
+	p.sectionID = id
+	return nil
+}
+
+// SetPlacement controls where the section is inserted when the file has none yet.
+// An existing section is always rewritten in place.
+func (p *PartialsBuildCommand) SetPlacement(placement Placement) {
+	p.placement = placement
+}
+
+// SetPartialOptions controls recursion and include/exclude filtering of the partials directory
+func (p *PartialsBuildCommand) SetPartialOptions(opts PartialOptions) {
+	p.partialOptions = opts
+}
+
+// SetTemplateData renders every partial as a text/template with data before merging.
+// A nil data copies partials verbatim.
+func (p *PartialsBuildCommand) SetTemplateData(data *TemplateData) {
+	p.templateData = data
+}
+
+// SetValidator checks the rendered file before it is written; nil disables validation
+func (p *PartialsBuildCommand) SetValidator(validator *Validator) {
+	p.validator = validator
+}
+
+// Validate checks a planned change with the command's validator
+func (p PartialsBuildCommand) Validate(change *FileChange) error {
+	return ValidateChange(change, p.validator)
+}
+
+// SetChecksum writes a checksum line (content hash, parts version and apply
+// time) as the first line of the section, so later hand edits are detected
+func (p *PartialsBuildCommand) SetChecksum(checksum bool) {
+	p.checksum = checksum
+}
+
+// SetEscapeMarkers escapes partial lines that would be read as marker syntax
+// instead of refusing them; sync reverses the escaping
+func (p *PartialsBuildCommand) SetEscapeMarkers(escape bool) {
+	p.escapeMarkers = escape
+}
+
+// SetDelimitPartials wraps each partial in begin and end lines naming its
+// path relative to the partials directory, so sync restores it byte for byte
+func (p *PartialsBuildCommand) SetDelimitPartials(delimit bool) {
+	p.delimit = delimit
+}
+
+// SetSourcePaths controls how partial paths are written in Source comments and delimiters
+func (p *PartialsBuildCommand) SetSourcePaths(paths SourcePaths) {
+	p.sourcePaths = paths
+}
+
+// SetForce overwrites the section even if it was edited by hand
+func (p *PartialsBuildCommand) SetForce(force bool) {
+	p.force = force
+}
+
+// CheckEdits returns ErrHandEdited if the change would overwrite a section
+// that was edited since its checksum was written, unless forced
+func (p PartialsBuildCommand) CheckEdits(change *FileChange) error {
+	if p.force {
+		return nil
+	}
+	style := p.getCommentStyle()
+	current, found := sectionBody(string(change.Original), style, p.sectionID)
+	if !found {
+		return nil
+	}
+	planned, _ := sectionBody(string(change.Content), style, p.sectionID)
+	return checkEdits(p.aggregateFile, current, planned)
+}
+
+// SetBackupStore enables backups of the aggregate file before it is modified
+func (p *PartialsBuildCommand) SetBackupStore(store *BackupStore) {
+	p.backups = store
+}
+
+// SetOutput sends progress messages and dry-run previews to w instead of stdout
+func (p *PartialsBuildCommand) SetOutput(w io.Writer) {
+	p.out = w
+}
+
+// SetFS reads and writes files through fsys instead of the real filesystem.
+// Backups are still stored on disk.
+func (p *PartialsBuildCommand) SetFS(fsys FS) {
+	p.fsys = fsys
+}
+
 // getCommentStyle returns the resolved comment style for this command
 func (p PartialsBuildCommand) getCommentStyle() CommentStyle {
 	return ResolveCommentStyle(p.commentChars, p.aggregateFile)
@@ -43,124 +156,149 @@ func (p PartialsBuildCommand) getCommentStyle() CommentStyle {
 
 // GetStartFlag returns the start marker for this build command
 func (p PartialsBuildCommand) GetStartFlag() string {
-	style := p.getCommentStyle()
-	if style.End != "" {
-		// Multi-character comment style with proper header block
-		return fmt.Sprintf("%s\n%s %s\n%s", style.Start, style.Start, PartialStartMarker, style.End)
-	}
-	// Single-character comment style with header block
-	return fmt.Sprintf("%s %s\n%s %s\n%s %s",
-		style.Start, MarkerSeparator, style.Start, PartialStartMarker, style.Start, MarkerSeparator)
+	return buildStartFlag(p.getCommentStyle(), p.sectionID)
 }
 
 // GetEndFlag returns the end marker for this build command
 func (p PartialsBuildCommand) GetEndFlag() string {
-	style := p.getCommentStyle()
-	if style.End != "" {
-		// Multi-character comment style with proper footer block
-		return fmt.Sprintf("%s\n%s %s\n%s", style.Start, style.Start, PartialEndMarker, style.End)
-	}
-	// Single-character comment style with footer block
-	return fmt.Sprintf("%s %s\n%s %s\n%s %s",
-		style.Start, MarkerSeparator, style.Start, PartialEndMarker, style.Start, MarkerSeparator)
+	return buildEndFlag(p.getCommentStyle(), p.sectionID)
 }
 
-// Run executes the build command
-func (p PartialsBuildCommand) Run() error {
+// Plan renders the aggregate file with the partials section rebuilt,
+// without writing anything
+func (p PartialsBuildCommand) Plan() (*FileChange, error) {
+	fsys := fileSystem(p.fsys)
 	path, err := filepath.Abs(p.aggregateFile)
 	if err != nil {
-		return fmt.Errorf("failed to get absolute path for aggregate file '%s': %w", p.aggregateFile, err)
+		return nil, fmt.Errorf("failed to get absolute path for aggregate file '%s': %w", p.aggregateFile, err)
 	}
 
 	// Get original file permissions before reading
 	var originalMode fs.FileMode = 0600 // default if file doesn't exist
-	if info, statErr := os.Stat(path); statErr == nil {
+	if info, statErr := fsys.Stat(path); statErr == nil {
 		originalMode = info.Mode()
 	}
 
-	agg, err := os.ReadFile(path)
+	agg, err := fsys.ReadFile(path)
 	if err != nil {
-		return fmt.Errorf("failed to read aggregate file '%s': %w", path, err)
+		return nil, fmt.Errorf("failed to read aggregate file '%s': %w", path, err)
 	}
 	output := string(agg)
-
-	startIndex := strings.Index(output, p.GetStartFlag())
-	endIndex := strings.Index(output, p.GetEndFlag())
-
-	if startIndex != -1 && endIndex != -1 {
-		before := output[:startIndex]
-		afterStart := endIndex + len(p.GetEndFlag())
-		// Skip the trailing newline after the end flag if present
-		if afterStart < len(output) && output[afterStart] == '\n' {
-			afterStart++
-		}
-		after := output[afterStart:]
-		output = before + after
+	existing, err := parseSection(output, p.getCommentStyle(), p.sectionID)
+	if err != nil {
+		return nil, malformedSection(p.aggregateFile, err)
 	}
 
-	// Add separator newline if content doesn't end with one
-	if !strings.HasSuffix(output, "\n") {
-		output += "\n"
-	}
-	output += p.GetStartFlag()
-	output += "\n"
+	var body strings.Builder
 
-	files, err := os.ReadDir(p.partialsDir)
+	files, err := listPartials(fsys, p.partialsDir, p.partialOptions)
+	if err != nil {
+		return nil, err
+	}
+	files, skipped, err := filterPartials(fsys, files, CurrentFacts())
 	if err != nil {
-		return fmt.Errorf("failed to read partials directory '%s': %w", p.partialsDir, err)
+		return nil, err
 	}
 
+	var partials []string
+
 	// Each file: read contents into var to be written later.
 	for _, file := range files {
-		if file.IsDir() {
-			continue
+		partialPath := file.Path
+		fileContents, readErr := readPartial(fsys, partialPath, p.templateData)
+		if readErr != nil {
+			return nil, readErr
+		}
+		style := p.getCommentStyle()
+		fileContents, err = escapePartial(style, partialPath, fileContents, p.escapeMarkers)
+		if err != nil {
+			return nil, err
 		}
+		partials = append(partials, partialPath)
 
-		partialPath := filepath.Join(p.partialsDir, file.Name())
-		fileContents, readErr := os.ReadFile(partialPath)
-		if readErr != nil {
-			return fmt.Errorf("failed to read partial file '%s': %w", partialPath, readErr)
+		sourcePath := p.sourcePaths.format(p.partialsDir, partialPath, p.delimit)
+		if p.delimit {
+			writeDelimited(&body, style, sourcePath, fileContents)
+			continue
 		}
 
 		// Add source file path comment before each partial's content
-		style := p.getCommentStyle()
-		if style.End != "" {
-			// Multi-character comment style - need to close the comment
-			output += fmt.Sprintf("%s Source: %s %s\n", style.Start, partialPath, style.End)
-		} else {
-			// Single-character comment style
-			output += fmt.Sprintf("%s Source: %s\n", style.Start, partialPath)
+		if !p.sourcePaths.hidden() {
+			body.WriteString(commentLine(style, "Source: "+sourcePath))
 		}
-		output += string(fileContents)
-		output += "\n"
+		body.Write(fileContents)
+		body.WriteString("\n")
 	}
 
-	output += p.GetEndFlag()
-	output += "\n"
+	managed := body.String()
+	if p.checksum {
+		var previous *Checksum
+		if existing != nil {
+			previous, _ = splitChecksum(output[existing.BodyStart:existing.BodyEnd])
+		}
+		managed = stampChecksum(p.getCommentStyle(), managed, previous)
+	}
+	section := p.GetStartFlag() + "\n" + managed + p.GetEndFlag() + "\n"
 
-	if p.dryRun {
-		fmt.Printf("DRY RUN: Would write to '%s'\n", p.aggregateFile)
-		fmt.Printf("Content preview:\n")
-		fmt.Printf("--- BEGIN FILE CONTENT ---\n")
-		fmt.Print(output)
-		fmt.Printf("--- END FILE CONTENT ---\n")
-		fmt.Printf("Total length: %d characters\n", len(output))
-		return nil
+	// Rewrite an existing section where it is; otherwise insert it per placement
+	if existing != nil {
+		// Skip the trailing newline after the end flag if present
+		afterEnd := existing.End
+		if afterEnd < len(output) && output[afterEnd] == '\n' {
+			afterEnd++
+		}
+		output = output[:existing.Start] + section + output[afterEnd:]
+	} else {
+		output = p.placement.insert(output, section)
 	}
 
-	err = os.WriteFile(p.aggregateFile, []byte(output), originalMode)
+	return &FileChange{
+		Path:         p.aggregateFile,
+		Content:      []byte(output),
+		Mode:         originalMode,
+		Original:     agg,
+		OriginalMode: originalMode,
+		Existed:      true,
+		Partials:     partials,
+		Skipped:      skipped,
+	}, nil
+}
+
+// Run executes the build command
+func (p PartialsBuildCommand) Run() error {
+	out := messageOutput(p.out)
+	change, err := p.Plan()
 	if err != nil {
-		return fmt.Errorf("failed to write aggregate file '%s': %w", p.aggregateFile, err)
+		return err
+	}
+	if err := p.Validate(change); err != nil {
+		return err
 	}
+	output := string(change.Content)
+	editErr := p.CheckEdits(change)
 
-	// Count partial files for success message
-	partialCount := 0
-	for _, f := range files {
-		if !f.IsDir() {
-			partialCount++
+	if p.dryRun {
+		if editErr != nil {
+			fmt.Fprintf(out, "Warning: %v\n", editErr)
 		}
+		printSkippedPartials(out, change.Skipped)
+		fmt.Fprintf(out, "DRY RUN: Would write to '%s'\n", p.aggregateFile)
+		fmt.Fprintf(out, "Content preview:\n")
+		fmt.Fprintf(out, "--- BEGIN FILE CONTENT ---\n")
+		fmt.Fprint(out, output)
+		fmt.Fprintf(out, "--- END FILE CONTENT ---\n")
+		fmt.Fprintf(out, "Total length: %d characters\n", len(output))
+		return nil
 	}
-	fmt.Printf("Merged %d partial(s) into '%s'\n", partialCount, p.aggregateFile)
+	if editErr != nil {
+		return editErr
+	}
+
+	if err := writeChange(fileSystem(p.fsys), change, p.backups); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Merged %d partial(s) into '%s'\n", len(change.Partials), p.aggregateFile)
 
 	return nil
 }
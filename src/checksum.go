@@ -0,0 +1,130 @@
+package src
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ChecksumMarker starts the line that records what parts wrote: the first
+// line inside a PARTIALS section, or the first line of an own-mode file
+const ChecksumMarker = "PARTIALS-CHECKSUM"
+
+// Version is the parts version recorded in checksum lines. Release builds set
+// it with -ldflags "-X github.com/cageis/parts/src.Version=<version>".
+var Version = "dev"
+
+// ErrHandEdited is returned when writing would overwrite managed content that
+// was edited since parts wrote it
+var ErrHandEdited = errors.New("managed content was edited since the last apply")
+
+// Checksum is the metadata of a checksum line
+type Checksum struct {
+	Hash    string // HashContent of the managed content below the line
+	Version string
+	Applied time.Time
+}
+
+// formatChecksumLine renders c as a comment line in style
+func formatChecksumLine(style CommentStyle, c Checksum) string {
+	line := fmt.Sprintf("%s %s %s version=%s applied=%s",
+		style.Start, ChecksumMarker, c.Hash, c.Version, c.Applied.UTC().Format(time.RFC3339))
+	if style.End != "" {
+		line += " " + style.End
+	}
+	return line
+}
+
+// parseChecksumLine reads a line written by formatChecksumLine, in any comment style
+func parseChecksumLine(line string) (Checksum, bool) {
+	idx := strings.Index(line, ChecksumMarker+" ")
+	if idx == -1 {
+		return Checksum{}, false
+	}
+	fields := strings.Fields(line[idx+len(ChecksumMarker):])
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "sha256:") {
+		return Checksum{}, false
+	}
+	c := Checksum{Hash: fields[0]}
+	for _, field := range fields[1:] {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "version":
+			c.Version = kv[1]
+		case "applied":
+			if applied, err := time.Parse(time.RFC3339, kv[1]); err == nil {
+				c.Applied = applied
+			}
+		}
+	}
+	return c, true
+}
+
+// splitChecksum separates a leading checksum line from managed content.
+// Content written before checksums existed has none and is returned whole.
+func splitChecksum(content string) (*Checksum, string) {
+	line, rest := content, ""
+	if i := strings.IndexByte(content, '\n'); i != -1 {
+		line, rest = content[:i], content[i+1:]
+	}
+	c, ok := parseChecksumLine(line)
+	if !ok {
+		return nil, content
+	}
+	return &c, rest
+}
+
+// stampChecksum prefixes body with its checksum line. The previous checksum is
+// kept when the body is unchanged, so reapplying doesn't touch the file.
+func stampChecksum(style CommentStyle, body string, previous *Checksum) string {
+	c := Checksum{Hash: HashContent([]byte(body)), Version: Version, Applied: time.Now()}
+	if previous != nil && previous.Hash == c.Hash {
+		c = *previous
+	}
+	return formatChecksumLine(style, c) + "\n" + body
+}
+
+// checkEdits returns ErrHandEdited when current, the managed content found in
+// path, no longer matches its checksum and planned would replace it.
+// Content without a checksum line can't be checked and passes.
+func checkEdits(path, current, planned string) error {
+	recorded, body := splitChecksum(current)
+	if recorded == nil || recorded.Hash == HashContent([]byte(body)) {
+		return nil
+	}
+	if _, plannedBody := splitChecksum(planned); plannedBody == body {
+		return nil // the edits are already in the partials
+	}
+	return fmt.Errorf("'%s': %w (run 'parts sync' to keep the edits or --force to overwrite them)", path, ErrHandEdited)
+}
+
+// acceptEdits rewrites the checksum line of current to match its content, so
+// edits that were synced into the partials no longer count as hand edits.
+// Returns false when there is no checksum line or it already matches.
+func acceptEdits(style CommentStyle, current string) (string, bool) {
+	recorded, body := splitChecksum(current)
+	if recorded == nil {
+		return current, false
+	}
+	hash := HashContent([]byte(body))
+	if recorded.Hash == hash {
+		return current, false
+	}
+	accepted := *recorded
+	accepted.Hash = hash
+	return formatChecksumLine(style, accepted) + "\n" + body, true
+}
+
+// sectionBody returns the managed content between the start flag line and
+// the end flag of a section
+func sectionBody(content string, style CommentStyle, id string) (string, bool) {
+	section, err := parseSection(content, style, id)
+	if err != nil || section == nil {
+		return "", false
+	}
+	return content[section.BodyStart:section.BodyEnd], true
+}
@@ -0,0 +1,188 @@
+package src
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// checksumBuild returns a build command for the fixture with checksums on
+func checksumBuild(t *testing.T, fsys FS, target, partialsDir string) PartialsBuildCommand {
+	t.Helper()
+	build, err := NewPartialsBuildCommand(target, partialsDir, "#")
+	if err != nil {
+		t.Fatalf("Failed to create build command: %v", err)
+	}
+	build.SetFS(fsys)
+	build.SetOutput(io.Discard)
+	build.SetChecksum(true)
+	return build
+}
+
+func TestBuild_ChecksumLine(t *testing.T) {
+	fsys := NewMemFS()
+	target, partialsDir := targetFixture(t, fsys, "/mem", map[string]string{"work": "Host work\n"})
+	build := checksumBuild(t, fsys, target, partialsDir)
+	if err := build.Run(); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	content := readMem(t, fsys, target)
+	body, found := sectionBody(content, build.getCommentStyle(), "")
+	if !found {
+		t.Fatalf("Section not found in:\n%s", content)
+	}
+	written, rest := splitChecksum(body)
+	if written == nil || written.Version != Version || written.Applied.IsZero() {
+		t.Fatalf("Expected a checksum line with version and time, got:\n%s", body)
+	}
+	if written.Hash != HashContent([]byte(rest)) || !strings.Contains(rest, "Host work\n") {
+		t.Errorf("Checksum should cover the section content, got %+v for %q", written, rest)
+	}
+
+	// Reapplying unchanged partials keeps the line, timestamp included
+	change, err := build.Plan()
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if change.Changed() {
+		t.Errorf("Reapplying should not change the file:\n%s", change.Content)
+	}
+
+	// Sync must not mistake the checksum line for stray content
+	sync := NewPartialsSyncCommand(target, partialsDir, "#", "merge")
+	sync.SetFS(fsys)
+	sync.SetOutput(io.Discard)
+	result, err := sync.Run()
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if len(result.Orphaned) != 0 || result.UpdatedFiles != 0 {
+		t.Errorf("Expected nothing to sync, got %+v", result)
+	}
+}
+
+func TestBuild_RefusesHandEdits(t *testing.T) {
+	fsys := NewMemFS()
+	target, partialsDir := targetFixture(t, fsys, "/mem", map[string]string{"work": "Host work\n"})
+	build := checksumBuild(t, fsys, target, partialsDir)
+	if err := build.Run(); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	edited := strings.Replace(readMem(t, fsys, target), "Host work", "Host edited", 1)
+	if err := fsys.WriteFile(target, []byte(edited), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	// A partial change would overwrite the edit
+	if err := fsys.WriteFile(partialsDir+"/work", []byte("Host work2\n"), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	err := build.Run()
+	if !errors.Is(err, ErrHandEdited) {
+		t.Fatalf("Expected ErrHandEdited, got %v", err)
+	}
+	if readMem(t, fsys, target) != edited {
+		t.Error("A refused build must not write the target")
+	}
+
+	build.SetForce(true)
+	if err := build.Run(); err != nil {
+		t.Fatalf("Forced build failed: %v", err)
+	}
+	if !strings.Contains(readMem(t, fsys, target), "Host work2\n") {
+		t.Error("A forced build should overwrite the edit")
+	}
+}
+
+func TestBuild_AcceptsSyncedEdits(t *testing.T) {
+	fsys := NewMemFS()
+	target, partialsDir := targetFixture(t, fsys, "/mem", map[string]string{"work": "Host work\n"})
+	memTree(t, fsys, map[string]string{partialsDir + "/other": "Host other\n"})
+	build := checksumBuild(t, fsys, target, partialsDir)
+	if err := build.Run(); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	edited := strings.Replace(readMem(t, fsys, target), "Host work", "Host edited", 1)
+	if err := fsys.WriteFile(target, []byte(edited), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	sync := NewPartialsSyncCommand(target, partialsDir, "#", "merge")
+	sync.SetFS(fsys)
+	sync.SetOutput(io.Discard)
+	if _, err := sync.Run(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	// The partials now carry the edit, and a later partial change applies cleanly
+	if err := fsys.WriteFile(partialsDir+"/other", []byte("Host other2\n"), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if err := build.Run(); err != nil {
+		t.Fatalf("Build after sync failed: %v", err)
+	}
+	content := readMem(t, fsys, target)
+	if !strings.Contains(content, "Host edited\n") || !strings.Contains(content, "Host other2\n") {
+		t.Errorf("Expected both the synced edit and the partial change, got:\n%s", content)
+	}
+}
+
+func TestBuild_ChecksumAcceptsOldMarkers(t *testing.T) {
+	fsys := NewMemFS()
+	target, partialsDir := targetFixture(t, fsys, "/mem", map[string]string{"work": "Host work\n"})
+	build := checksumBuild(t, fsys, target, partialsDir)
+	build.SetChecksum(false)
+	if err := build.Run(); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	edited := strings.Replace(readMem(t, fsys, target), "Host work", "Host edited", 1)
+	if err := fsys.WriteFile(target, []byte(edited), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	// A section without a checksum line can't be checked, so it is rewritten
+	build.SetChecksum(true)
+	if err := build.Run(); err != nil {
+		t.Fatalf("Build over an old section failed: %v", err)
+	}
+	if !strings.Contains(readMem(t, fsys, target), ChecksumMarker) {
+		t.Error("Expected the checksum line to be added")
+	}
+}
+
+func TestOwn_RefusesHandEdits(t *testing.T) {
+	fsys := NewMemFS()
+	target, partialsDir := targetFixture(t, fsys, "/mem", map[string]string{"work": "Host work\n"})
+	own := NewPartialsOwnCommand(target, partialsDir, "#")
+	own.SetFS(fsys)
+	own.SetOutput(io.Discard)
+	own.SetChecksum(true)
+	if err := own.Run(); err != nil {
+		t.Fatalf("Own failed: %v", err)
+	}
+	content := readMem(t, fsys, target)
+	if !strings.HasPrefix(content, "# "+ChecksumMarker+" sha256:") {
+		t.Fatalf("Expected a checksum header line, got:\n%s", content)
+	}
+
+	if err := fsys.WriteFile(target, []byte(content+"Host extra\n"), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if err := own.Run(); !errors.Is(err, ErrHandEdited) {
+		t.Fatalf("Expected ErrHandEdited, got %v", err)
+	}
+}
+
+func TestParseChecksumLine(t *testing.T) {
+	style := CommentStyle{Start: "/*", End: "*/"}
+	line := formatChecksumLine(style, Checksum{Hash: "sha256:abc", Version: "v1.2.3"})
+	parsed, ok := parseChecksumLine(line)
+	if !ok || parsed.Hash != "sha256:abc" || parsed.Version != "v1.2.3" {
+		t.Errorf("Failed to parse %q: %+v", line, parsed)
+	}
+	if _, ok := parseChecksumLine("# PARTIALS-CHECKSUM md5:abc"); ok {
+		t.Error("Only sha256 hashes should be recognised")
+	}
+}
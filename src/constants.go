@@ -1,8 +1,22 @@
 package src
 
+import "errors"
+
 // Partials section markers
 const (
 	PartialStartMarker = "PARTIALS>>>>>"
 	PartialEndMarker   = "PARTIALS<<<<<"
 	MarkerSeparator    = "============================"
 )
+
+// Per-partial delimiters, written around each partial instead of a Source
+// comment when delimiting is enabled. The end line of a partial without a
+// final newline is DelimiterEndNoEOL.
+const (
+	DelimiterBegin    = "PARTIAL-BEGIN"
+	DelimiterEnd      = "PARTIAL-END"
+	DelimiterEndNoEOL = "PARTIAL-END-NOEOL"
+)
+
+// ErrNoPartialsSection is returned when a file has no PARTIALS section to operate on
+var ErrNoPartialsSection = errors.New("no partials section found")
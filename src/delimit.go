@@ -0,0 +1,43 @@
+package src
+
+import (
+	"fmt"
+	"strings"
+)
+
+// commentLine renders text as one comment line in style, with its newline
+func commentLine(style CommentStyle, text string) string {
+	if style.End != "" {
+		return fmt.Sprintf("%s %s %s\n", style.Start, text, style.End)
+	}
+	return fmt.Sprintf("%s %s\n", style.Start, text)
+}
+
+// writeDelimited writes content between begin and end lines naming rel. A
+// final newline is added if missing, and the end line records that it was.
+func writeDelimited(w *strings.Builder, style CommentStyle, rel string, content []byte) {
+	w.WriteString(commentLine(style, DelimiterBegin+" "+rel))
+	w.Write(content)
+	end := DelimiterEnd
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		w.WriteByte('\n')
+		end = DelimiterEndNoEOL
+	}
+	w.WriteString(commentLine(style, end+" "+rel))
+}
+
+// parseDelimiter reads a begin or end line written by writeDelimited,
+// returning its marker word and path
+func parseDelimiter(line string, style CommentStyle) (word, rel string, ok bool) {
+	text := strings.TrimRight(line, " \t\r")
+	if style.End != "" {
+		text = strings.TrimSuffix(text, " "+style.End)
+	}
+	for _, candidate := range []string{DelimiterBegin, DelimiterEnd, DelimiterEndNoEOL} {
+		prefix := style.Start + " " + candidate + " "
+		if strings.HasPrefix(text, prefix) {
+			return candidate, strings.TrimSpace(text[len(prefix):]), true
+		}
+	}
+	return "", "", false
+}
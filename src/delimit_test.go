@@ -0,0 +1,104 @@
+package src
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+// delimitedPartials have endings that Source comments can't carry through a sync
+var delimitedPartials = map[string]string{
+	"a-noeol":    "Host a",
+	"b-blank":    "Host b\n\n\n",
+	"c-empty":    "",
+	"d-crlf":     "Host d\r\n",
+	"nested/e":   "Host e\n",
+	"f-leading":  "\n\nHost f\n",
+	"g-noeol-nl": "Host g\n\nlast",
+}
+
+func TestDelimitedPartials_RoundTrip(t *testing.T) {
+	for _, commentChars := range []string{"#", "/*"} {
+		t.Run(commentChars, func(t *testing.T) {
+			fsys := NewMemFS()
+			target, partialsDir := "/mem/config", "/mem/partials"
+			files := map[string]string{target: "before\n"}
+			for name, content := range delimitedPartials {
+				files[partialsDir+"/"+name] = content
+			}
+			memTree(t, fsys, files)
+
+			build, err := NewPartialsBuildCommand(target, partialsDir, commentChars)
+			if err != nil {
+				t.Fatalf("Failed to create build command: %v", err)
+			}
+			build.SetFS(fsys)
+			build.SetOutput(io.Discard)
+			build.SetPartialOptions(PartialOptions{Recursive: true})
+			build.SetDelimitPartials(true)
+			if err := build.Run(); err != nil {
+				t.Fatalf("Build failed: %v", err)
+			}
+			built := readMem(t, fsys, target)
+			style := ResolveCommentStyle(commentChars, target)
+			if !strings.Contains(built, commentLine(style, DelimiterBegin+" nested/e")) ||
+				!strings.Contains(built, "Host a\n"+commentLine(style, DelimiterEndNoEOL+" a-noeol")) {
+				t.Fatalf("Expected relative delimiters, got:\n%s", built)
+			}
+
+			sync := NewPartialsSyncCommand(target, partialsDir, commentChars, "merge")
+			sync.SetFS(fsys)
+			sync.SetOutput(io.Discard)
+			sync.SetPartialOptions(PartialOptions{Recursive: true})
+			result, err := sync.Run()
+			if err != nil {
+				t.Fatalf("Sync failed: %v", err)
+			}
+			if result.UpdatedFiles != 0 || len(result.Orphaned) != 0 || len(result.Deleted) != 0 {
+				t.Fatalf("Expected an unchanged target to sync nothing, got %+v", result)
+			}
+
+			// Trailing blank lines added in the target are kept exactly
+			edited := strings.Replace(built, "Host e\n", "Host e2\n\n", 1)
+			if err := fsys.WriteFile(target, []byte(edited), 0644); err != nil {
+				t.Fatalf("Failed: %v", err)
+			}
+			if _, err := sync.Run(); err != nil {
+				t.Fatalf("Sync failed: %v", err)
+			}
+			for name, content := range delimitedPartials {
+				if name == "nested/e" {
+					content = "Host e2\n\n"
+				}
+				if got := readMem(t, fsys, partialsDir+"/"+name); got != content {
+					t.Errorf("Partial '%s': expected %q, got %q", name, content, got)
+				}
+			}
+		})
+	}
+}
+
+func TestDelimitedPartials_Own(t *testing.T) {
+	fsys := NewMemFS()
+	memTree(t, fsys, map[string]string{"/mem/partials/a": "alpha", "/mem/partials/b": "beta\n\n"})
+
+	own := NewPartialsOwnCommand("/mem/out", "/mem/partials", "#")
+	own.SetFS(fsys)
+	own.SetOutput(io.Discard)
+	own.SetDelimitPartials(true)
+	if err := own.Run(); err != nil {
+		t.Fatalf("Own failed: %v", err)
+	}
+	expected := "# PARTIAL-BEGIN a\nalpha\n# PARTIAL-END-NOEOL a\n# PARTIAL-BEGIN b\nbeta\n\n# PARTIAL-END b\n"
+	if got := readMem(t, fsys, "/mem/out"); got != expected {
+		t.Fatalf("Expected:\n%s\ngot:\n%s", expected, got)
+	}
+
+	sections, err := ExtractPartialSections(expected, "#")
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if sections["a"] != "alpha" || sections["b"] != "beta\n\n" {
+		t.Errorf("Expected exact contents keyed by relative path, got %q", sections)
+	}
+}
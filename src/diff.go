@@ -0,0 +1,295 @@
+package src
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DefaultDiffContext is the number of unchanged lines shown around each change
+const DefaultDiffContext = 3
+
+// DevNull names the missing side of a created or deleted file in a diff
+const DevNull = "/dev/null"
+
+// ANSI escapes used by ColorizeDiff
+const (
+	ansiReset = "\x1b[0m"
+	ansiBold  = "\x1b[1m"
+	ansiRed   = "\x1b[31m"
+	ansiGreen = "\x1b[32m"
+	ansiCyan  = "\x1b[36m"
+)
+
+// diffOp is one line of an edit script: ' ' (kept), '-' (deleted) or '+' (inserted).
+// Lines keep their trailing newline so a missing final newline is a change too.
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// DiffPaths returns the "---" and "+++" names for a file in a patch. Paths are
+// made absolute and prefixed with a/ and b/, so the patch applies from the
+// filesystem root with `patch -d / -p1`. Pass existed=false for a created file
+// and deleted=true for a removed one.
+func DiffPaths(path string, existed, deleted bool) (oldName, newName string) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	rel := strings.TrimPrefix(filepath.ToSlash(abs), "/")
+	oldName, newName = "a/"+rel, "b/"+rel
+	if !existed {
+		oldName = DevNull
+	}
+	if deleted {
+		newName = DevNull
+	}
+	return oldName, newName
+}
+
+// Diff returns the unified diff from the file's original content to the
+// planned content, or "" when the content is unchanged
+func (c *FileChange) Diff(context int) string {
+	oldName, newName := DiffPaths(c.Path, c.Existed, false)
+	return UnifiedDiff(oldName, newName, string(c.Original), string(c.Content), context)
+}
+
+// UnifiedDiff returns a unified diff of a and b with context lines around each
+// hunk, in the format accepted by patch. Returns "" if a and b are equal.
+func UnifiedDiff(oldName, newName, a, b string, context int) string {
+	if a == b {
+		return ""
+	}
+	if context < 0 {
+		context = 0
+	}
+	ops := diffLines(splitLines(a), splitLines(b))
+
+	// Line positions in a and b before each op, for hunk headers
+	oldPos := make([]int, len(ops)+1)
+	newPos := make([]int, len(ops)+1)
+	for i, op := range ops {
+		oldPos[i+1], newPos[i+1] = oldPos[i], newPos[i]
+		if op.kind != '+' {
+			oldPos[i+1]++
+		}
+		if op.kind != '-' {
+			newPos[i+1]++
+		}
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+
+	i := 0
+	for i < len(ops) {
+		for i < len(ops) && ops[i].kind == ' ' {
+			i++
+		}
+		if i == len(ops) {
+			break
+		}
+
+		// Extend the hunk while changes are separated by at most 2*context kept lines
+		lastChange := i
+		for j := i; j < len(ops); j++ {
+			if ops[j].kind != ' ' {
+				lastChange = j
+			} else if j-lastChange > 2*context {
+				break
+			}
+		}
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		end := lastChange + 1 + context
+		if end > len(ops) {
+			end = len(ops)
+		}
+
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n",
+			hunkRange(oldPos[start], oldPos[end]-oldPos[start]),
+			hunkRange(newPos[start], newPos[end]-newPos[start]))
+		for _, op := range ops[start:end] {
+			out.WriteByte(op.kind)
+			out.WriteString(op.line)
+			if !strings.HasSuffix(op.line, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		i = end
+	}
+
+	return out.String()
+}
+
+// hunkRange formats a hunk range the way diff -u does: an empty range names
+// the line before it, and a count of 1 is omitted
+func hunkRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// splitLines splits s after each newline; the last line may lack one
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines computes a shortest edit script from a to b with the linear
+// space variant of Myers' algorithm, so memory grows with the input rather
+// than with the number of differences
+func diffLines(a, b []string) []diffOp {
+	ops := appendDiff(nil, a, b)
+
+	// Within each run of changes, list deletions before insertions as diff -u does
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		j := i
+		for j < len(ops) && ops[j].kind != ' ' {
+			j++
+		}
+		sort.SliceStable(ops[i:j], func(p, q int) bool {
+			return ops[i+p].kind == '-' && ops[i+q].kind == '+'
+		})
+		i = j
+	}
+	return ops
+}
+
+// appendDiff appends the edit script from a to b to ops. Common lines at
+// either end are kept as they are; the rest is split at its middle snake.
+func appendDiff(ops []diffOp, a, b []string) []diffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		ops = append(ops, diffOp{' ', a[prefix]})
+		prefix++
+	}
+	a, b = a[prefix:], b[prefix:]
+	suffix := 0
+	for suffix < len(a) && suffix < len(b) && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	kept := a[len(a)-suffix:]
+	a, b = a[:len(a)-suffix], b[:len(b)-suffix]
+
+	switch {
+	case len(a) == 0:
+		for _, line := range b {
+			ops = append(ops, diffOp{'+', line})
+		}
+	case len(b) == 0:
+		for _, line := range a {
+			ops = append(ops, diffOp{'-', line})
+		}
+	default:
+		// Both ends differ, so at least two edits remain and each half is smaller
+		x, y, u, v := middleSnake(a, b)
+		ops = appendDiff(ops, a[:x], b[:y])
+		for _, line := range a[x:u] {
+			ops = append(ops, diffOp{' ', line})
+		}
+		ops = appendDiff(ops, a[u:], b[v:])
+	}
+
+	for _, line := range kept {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops
+}
+
+// middleSnake returns the start (x, y) and end (u, v) of the snake in the
+// middle of a shortest edit path from a to b, searching forward from the
+// start and backward from the end until the two searches meet
+func middleSnake(a, b []string) (x, y, u, v int) {
+	n, m := len(a), len(b)
+	delta := n - m
+	odd := delta%2 != 0
+	maxD := (n + m + 1) / 2
+	offset := maxD + 1
+
+	// forward[offset+k] is the furthest x reached on diagonal k = x-y from the
+	// start; backward[offset+k] the smallest x on diagonal delta+k from the end
+	forward := make([]int, 2*offset+1)
+	backward := make([]int, 2*offset+1)
+	backward[offset+1] = n + 1
+
+	for d := 0; d <= maxD; d++ {
+		for k := -d; k <= d; k += 2 {
+			if k == -d || (k != d && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+			y = x - k
+			u, v = x, y
+			for u < n && v < m && a[u] == b[v] {
+				u++
+				v++
+			}
+			forward[offset+k] = u
+			// An odd delta lets the paths meet on a forward step
+			if back := k - delta; odd && back >= -(d-1) && back <= d-1 && u >= backward[offset+back] {
+				return x, y, u, v
+			}
+		}
+
+		for k := -d; k <= d; k += 2 {
+			if k == -d || (k != d && backward[offset+k+1]-1 <= backward[offset+k-1]) {
+				u = backward[offset+k+1] - 1
+			} else {
+				u = backward[offset+k-1]
+			}
+			v = u - (delta + k)
+			x, y = u, v
+			for x > 0 && y > 0 && a[x-1] == b[y-1] {
+				x--
+				y--
+			}
+			backward[offset+k] = x
+			// An even delta lets the paths meet on a backward step
+			if fwd := delta + k; !odd && fwd >= -d && fwd <= d && x <= forward[offset+fwd] {
+				return x, y, u, v
+			}
+		}
+	}
+	// Unreachable: the searches meet within (n+m+1)/2 steps
+	return 0, 0, 0, 0
+}
+
+// ColorizeDiff adds ANSI colors to a unified diff for terminal output
+func ColorizeDiff(diff string) string {
+	var out strings.Builder
+	for _, line := range splitLines(diff) {
+		switch {
+		case strings.HasPrefix(line, "--- "), strings.HasPrefix(line, "+++ "):
+			out.WriteString(ansiBold + strings.TrimSuffix(line, "\n") + ansiReset + "\n")
+		case strings.HasPrefix(line, "@@"):
+			out.WriteString(ansiCyan + strings.TrimSuffix(line, "\n") + ansiReset + "\n")
+		case strings.HasPrefix(line, "-"):
+			out.WriteString(ansiRed + strings.TrimSuffix(line, "\n") + ansiReset + "\n")
+		case strings.HasPrefix(line, "+"):
+			out.WriteString(ansiGreen + strings.TrimSuffix(line, "\n") + ansiReset + "\n")
+		default:
+			out.WriteString(line)
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,185 @@
+package src
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	a := "1\n2\n3\nfour\n5\n6\n7\n8\n9\n10\n11\n"
+	b := "1\n2\n3\nFOUR\n5\n6\n7\n8\n9\n10\n11\n12\n"
+
+	// Same output as diff -u
+	expected := `--- a/old
++++ b/new
+@@ -1,7 +1,7 @@
+ 1
+ 2
+ 3
+-four
++FOUR
+ 5
+ 6
+ 7
+@@ -9,3 +9,4 @@
+ 9
+ 10
+ 11
++12
+`
+	if got := UnifiedDiff("a/old", "b/new", a, b, 3); got != expected {
+		t.Errorf("Unexpected diff:\n%s\nwant:\n%s", got, expected)
+	}
+
+	// Changes at most 2*context lines apart merge into one hunk
+	if got := UnifiedDiff("a", "b", a, b, 4); strings.Count(got, "@@ -") != 1 {
+		t.Errorf("Expected a single hunk with context 4:\n%s", got)
+	}
+
+	if got := UnifiedDiff("a", "b", a, a, 3); got != "" {
+		t.Errorf("Expected no diff for equal content, got:\n%s", got)
+	}
+}
+
+func TestUnifiedDiff_EdgeCases(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     string
+		expected string
+	}{
+		{"create", "", "x\n", "--- a\n+++ b\n@@ -0,0 +1 @@\n+x\n"},
+		{"delete", "x\ny\n", "", "--- a\n+++ b\n@@ -1,2 +0,0 @@\n-x\n-y\n"},
+		{"missing final newline", "x\n", "x",
+			"--- a\n+++ b\n@@ -1 +1 @@\n-x\n+x\n\\ No newline at end of file\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UnifiedDiff("a", "b", tt.a, tt.b, 3); got != tt.expected {
+				t.Errorf("Expected:\n%q\ngot:\n%q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestDiffLines_Reconstructs(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	randomLines := func() []string {
+		lines := make([]string, rng.Intn(12))
+		for i := range lines {
+			lines[i] = string(rune('a'+rng.Intn(4))) + "\n"
+		}
+		return lines
+	}
+
+	for i := 0; i < 500; i++ {
+		a, b := randomLines(), randomLines()
+		var gotA, gotB []string
+		kept := 0
+		for _, op := range diffLines(a, b) {
+			if op.kind == ' ' {
+				kept++
+			}
+			if op.kind != '+' {
+				gotA = append(gotA, op.line)
+			}
+			if op.kind != '-' {
+				gotB = append(gotB, op.line)
+			}
+		}
+		if strings.Join(gotA, "") != strings.Join(a, "") || strings.Join(gotB, "") != strings.Join(b, "") {
+			t.Fatalf("Edit script does not reconstruct inputs:\na=%q\nb=%q", a, b)
+		}
+		if want := longestCommon(a, b); kept != want {
+			t.Fatalf("Edit script keeps %d lines, want %d:\na=%q\nb=%q", kept, want, a, b)
+		}
+	}
+}
+
+// longestCommon returns the length of the longest common subsequence of a and b
+func longestCommon(a, b []string) int {
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			case lengths[i+1][j] > lengths[i][j+1]:
+				lengths[i][j] = lengths[i+1][j]
+			default:
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+	return lengths[0][0]
+}
+
+func TestDiffLines_LinearMemory(t *testing.T) {
+	// Replacing every line is the worst case: D = n+m
+	a, b := make([]string, 3000), make([]string, 3000)
+	for i := range a {
+		a[i] = fmt.Sprintf("old %d\n", i)
+		b[i] = fmt.Sprintf("new %d\n", i)
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	ops := diffLines(a, b)
+	runtime.ReadMemStats(&after)
+
+	if len(ops) != len(a)+len(b) {
+		t.Fatalf("Expected %d edits, got %d", len(a)+len(b), len(ops))
+	}
+	// Saving the frontier at every step would allocate over 500MB here
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 64<<20 {
+		t.Errorf("diffLines allocated %d bytes", allocated)
+	}
+}
+
+func TestUnifiedDiff_AppliesWithPatch(t *testing.T) {
+	if _, err := exec.LookPath("patch"); err != nil {
+		t.Skip("patch not installed")
+	}
+
+	cases := []struct{ a, b string }{
+		{"Host *\n    User me\n", "Host work\n    User admin\n\nHost *\n    User me\n"},
+		{"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n", "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\ntwelve"},
+		{"no newline", "no newline\nadded\n"},
+	}
+	for _, tc := range cases {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "file")
+		if err := os.WriteFile(path, []byte(tc.a), 0644); err != nil {
+			t.Fatalf("Failed: %v", err)
+		}
+		oldName, newName := DiffPaths(path, true, false)
+		diff := UnifiedDiff(oldName, newName, tc.a, tc.b, DefaultDiffContext)
+
+		cmd := exec.Command("patch", "-d", "/", "-p1", "--silent")
+		cmd.Stdin = strings.NewReader(diff)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("patch failed: %v\n%s\ndiff:\n%s", err, out, diff)
+		}
+		got, _ := os.ReadFile(path)
+		if string(got) != tc.b {
+			t.Errorf("Patched content %q, want %q", got, tc.b)
+		}
+	}
+}
+
+func TestColorizeDiff(t *testing.T) {
+	colored := ColorizeDiff("--- a\n+++ b\n@@ -1 +1 @@\n-x\n+y\n")
+	for _, want := range []string{ansiRed + "-x" + ansiReset, ansiGreen + "+y" + ansiReset, ansiCyan + "@@ -1 +1 @@"} {
+		if !strings.Contains(colored, want) {
+			t.Errorf("Expected %q in colored diff:\n%q", want, colored)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package src
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// EscapeMarker follows the comment start of a partial line that would
+// otherwise be read as marker syntax, when escaping is enabled
+const EscapeMarker = "PARTIALS-ESCAPE"
+
+// ErrMarkerCollision is returned when a partial can't be merged without
+// being mistaken for the markers around it
+var ErrMarkerCollision = errors.New("partial collides with marker syntax")
+
+// markerWords are the words that make a comment line parts syntax
+var markerWords = []string{
+	PartialStartMarker, PartialEndMarker, ChecksumMarker, EscapeMarker, "Source:",
+	DelimiterBegin, DelimiterEnd, DelimiterEndNoEOL,
+}
+
+// isMarkerSyntax reports whether line, in a file commented with start, would
+// be read as a section marker, separator, checksum line, source comment or
+// escaped line rather than content
+func isMarkerSyntax(line, start string) bool {
+	text := strings.TrimRight(line, " \t\r\n")
+	if text == start+" "+MarkerSeparator {
+		return true
+	}
+	for _, word := range markerWords {
+		prefix := start + " " + word
+		if text == prefix || strings.HasPrefix(text, prefix+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// escapePartial prepares a partial's content for merging in style. Lines that
+// would be read as marker syntax are escaped when escape is set and refused
+// otherwise, as is a path that would end the source comment early.
+func escapePartial(style CommentStyle, path string, content []byte, escape bool) ([]byte, error) {
+	if style.End != "" && strings.Contains(path, style.End) {
+		return nil, fmt.Errorf("'%s': %w: the path contains '%s', which ends the source comment (rename the file)",
+			path, ErrMarkerCollision, style.End)
+	}
+
+	lines := strings.SplitAfter(string(content), "\n")
+	escaped := false
+	for i, line := range lines {
+		if !isMarkerSyntax(line, style.Start) {
+			continue
+		}
+		if !escape {
+			return nil, fmt.Errorf("'%s' line %d: %w: %q (set 'escape_markers: true' or change the line)",
+				path, i+1, ErrMarkerCollision, strings.TrimRight(line, "\r\n"))
+		}
+		lines[i] = style.Start + " " + EscapeMarker + line[len(style.Start):]
+		escaped = true
+	}
+	if !escaped {
+		return content, nil
+	}
+	return []byte(strings.Join(lines, "")), nil
+}
+
+// unescapeLine reverses escapePartial for one line
+func unescapeLine(line, start string) string {
+	prefix := start + " " + EscapeMarker + " "
+	if strings.HasPrefix(line, prefix) {
+		return start + line[len(prefix)-1:]
+	}
+	return line
+}
@@ -0,0 +1,86 @@
+package src
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// collidingPartial is a partial that documents the markers parts writes
+const collidingPartial = "Host work\n# Source: ~/.ssh/config.d/work\n# PARTIALS<<<<<\n# " + MarkerSeparator + "\n# PARTIALS-ESCAPE\nnote: PARTIALS<<<<< mid-line\n"
+
+func TestBuild_RefusesMarkerCollision(t *testing.T) {
+	fsys := NewMemFS()
+	target, partialsDir := targetFixture(t, fsys, "/mem", map[string]string{"work": "Host work\n"})
+	memTree(t, fsys, map[string]string{partialsDir + "/work": collidingPartial})
+
+	build := checksumBuild(t, fsys, target, partialsDir)
+	err := build.Run()
+	if !errors.Is(err, ErrMarkerCollision) || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("Expected a collision at line 2, got %v", err)
+	}
+	if readMem(t, fsys, target) != "# My config\n" {
+		t.Error("A refused build must not write the target")
+	}
+}
+
+func TestBuild_EscapedMarkersRoundTrip(t *testing.T) {
+	fsys := NewMemFS()
+	target, partialsDir := targetFixture(t, fsys, "/mem", map[string]string{"work": "Host work\n"})
+	memTree(t, fsys, map[string]string{partialsDir + "/work": collidingPartial})
+
+	build := checksumBuild(t, fsys, target, partialsDir)
+	build.SetEscapeMarkers(true)
+	if err := build.Run(); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	content := readMem(t, fsys, target)
+	if !strings.Contains(content, "# PARTIALS-ESCAPE Source: ~/.ssh/config.d/work\n") ||
+		!strings.Contains(content, "# PARTIALS-ESCAPE PARTIALS-ESCAPE\n") ||
+		!strings.Contains(content, "note: PARTIALS<<<<< mid-line\n") {
+		t.Fatalf("Expected the colliding lines to be escaped, got:\n%s", content)
+	}
+	if _, err := parseSection(content, build.getCommentStyle(), ""); err != nil {
+		t.Fatalf("Escaped content broke the section: %v", err)
+	}
+
+	// Sync restores the partial exactly, and an edit next to the escaped lines comes back
+	sync := NewPartialsSyncCommand(target, partialsDir, "#", "merge")
+	sync.SetFS(fsys)
+	sync.SetOutput(io.Discard)
+	result, err := sync.Run()
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if result.UpdatedFiles != 0 || len(result.Orphaned) != 0 {
+		t.Fatalf("Expected nothing to sync, got %+v", result)
+	}
+
+	edited := strings.Replace(content, "Host work\n", "Host edited\n", 1)
+	if err := fsys.WriteFile(target, []byte(edited), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if _, err := sync.Run(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	expected := strings.Replace(collidingPartial, "Host work\n", "Host edited\n", 1)
+	if got := readMem(t, fsys, partialsDir+"/work"); got != expected {
+		t.Errorf("Expected the unescaped partial:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+func TestEscapePartial_PathEndsComment(t *testing.T) {
+	style := CommentStyle{Start: "/*", End: "*/"}
+	_, err := escapePartial(style, "partials/a*/b.css", []byte("a {}\n"), true)
+	if !errors.Is(err, ErrMarkerCollision) {
+		t.Errorf("Expected a path containing '*/' to be refused, got %v", err)
+	}
+
+	// Bodies are not inside a comment, so '*/' there is content
+	body := []byte("/* a comment */\na {}\n")
+	got, err := escapePartial(style, "partials/a.css", body, false)
+	if err != nil || string(got) != string(body) {
+		t.Errorf("Expected the body unchanged, got %q (%v)", got, err)
+	}
+}
@@ -0,0 +1,470 @@
+package src
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FS is the filesystem the build, own, remove and sync commands read partials
+// and targets from and write them to. Names are native paths; relative ones
+// are resolved against the working directory.
+type FS interface {
+	ReadFile(name string) ([]byte, error)
+	// WriteFile replaces the content and mode of name; its directory must exist
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+	Stat(name string) (fs.FileInfo, error)
+	// ReadDir lists a directory sorted by name, like os.ReadDir
+	ReadDir(name string) ([]fs.DirEntry, error)
+	Rename(oldname, newname string) error
+	Remove(name string) error
+	MkdirAll(name string, perm fs.FileMode) error
+}
+
+// fileSystem returns fsys, defaulting to the real filesystem
+func fileSystem(fsys FS) FS {
+	if fsys == nil {
+		return OSFS{}
+	}
+	return fsys
+}
+
+// OSFS is the real filesystem. Writes go through WriteFileAtomic.
+type OSFS struct{}
+
+func (OSFS) ReadFile(name string) ([]byte, error) { return os.ReadFile(name) }
+
+func (OSFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return WriteFileAtomic(name, data, perm)
+}
+
+func (OSFS) Stat(name string) (fs.FileInfo, error)      { return os.Stat(name) }
+func (OSFS) ReadDir(name string) ([]fs.DirEntry, error) { return os.ReadDir(name) }
+func (OSFS) Rename(oldname, newname string) error       { return os.Rename(oldname, newname) }
+func (OSFS) Remove(name string) error                   { return os.Remove(name) }
+func (OSFS) MkdirAll(name string, perm fs.FileMode) error {
+	return os.MkdirAll(name, perm)
+}
+
+// PrefixFS maps every absolute path below Root on the real filesystem, so
+// "/etc/hosts" is read from "<Root>/etc/hosts". Errors report the unprefixed
+// path. Writes, renames, removes and new directories are confined to Root:
+// a path that resolves outside it through a symlink fails with
+// ErrOutsideRoot. Reads follow symlinks anywhere.
+type PrefixFS struct {
+	Root string
+}
+
+// NewPrefixFS returns a PrefixFS rooted at root, expanding a leading ~
+func NewPrefixFS(root string) (PrefixFS, error) {
+	expanded, err := ExpandTildePrefix(root)
+	if err != nil {
+		return PrefixFS{}, fmt.Errorf("failed to expand root path: %w", err)
+	}
+	abs, err := filepath.Abs(expanded)
+	if err != nil {
+		return PrefixFS{}, fmt.Errorf("failed to get absolute path for root '%s': %w", root, err)
+	}
+	return PrefixFS{Root: abs}, nil
+}
+
+// ErrOutsideRoot is returned when a PrefixFS write would leave its root
+var ErrOutsideRoot = errors.New("path resolves outside the root")
+
+// hostPath returns the path of name below Root
+func (p PrefixFS) hostPath(name string) (string, error) {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(p.Root, abs), nil
+}
+
+// writablePath returns the path of name below Root for op, failing if
+// symlinks resolve it outside Root. The last element is only followed when
+// followLast is set, since removing or renaming a link doesn't touch its target.
+func (p PrefixFS) writablePath(op, name string, followLast bool) (string, error) {
+	path, err := p.hostPath(name)
+	if err != nil {
+		return "", err
+	}
+	root, err := filepath.EvalSymlinks(p.Root)
+	if err != nil {
+		return "", unprefix(err, name)
+	}
+
+	// Resolve the deepest existing ancestor; the rest doesn't exist yet
+	existing, rest := path, ""
+	if !followLast {
+		existing, rest = filepath.Dir(path), filepath.Base(path)
+	}
+	resolved, err := filepath.EvalSymlinks(existing)
+	for err != nil && existing != filepath.Dir(existing) {
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = filepath.Dir(existing)
+		resolved, err = filepath.EvalSymlinks(existing)
+	}
+	if err != nil {
+		return "", unprefix(err, name)
+	}
+
+	rel, err := filepath.Rel(root, filepath.Join(resolved, rest))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", &fs.PathError{Op: op, Path: name, Err: ErrOutsideRoot}
+	}
+	return path, nil
+}
+
+// unprefix rewrites the path of a *fs.PathError back to name
+func unprefix(err error, name string) error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return &fs.PathError{Op: pathErr.Op, Path: name, Err: pathErr.Err}
+	}
+	return err
+}
+
+func (p PrefixFS) ReadFile(name string) ([]byte, error) {
+	path, err := p.hostPath(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	return data, unprefix(err, name)
+}
+
+func (p PrefixFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	path, err := p.writablePath("write", name, true)
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(path, data, perm)
+}
+
+func (p PrefixFS) Stat(name string) (fs.FileInfo, error) {
+	path, err := p.hostPath(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	return info, unprefix(err, name)
+}
+
+func (p PrefixFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	path, err := p.hostPath(name)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(path)
+	return entries, unprefix(err, name)
+}
+
+func (p PrefixFS) Rename(oldname, newname string) error {
+	oldPath, err := p.writablePath("rename", oldname, false)
+	if err != nil {
+		return err
+	}
+	newPath, err := p.writablePath("rename", newname, false)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(oldPath, newPath)
+	var linkErr *os.LinkError
+	if errors.As(err, &linkErr) {
+		return &os.LinkError{Op: linkErr.Op, Old: oldname, New: newname, Err: linkErr.Err}
+	}
+	return err
+}
+
+func (p PrefixFS) Remove(name string) error {
+	path, err := p.writablePath("remove", name, false)
+	if err != nil {
+		return err
+	}
+	return unprefix(os.Remove(path), name)
+}
+
+func (p PrefixFS) MkdirAll(name string, perm fs.FileMode) error {
+	path, err := p.writablePath("mkdir", name, true)
+	if err != nil {
+		return err
+	}
+	return unprefix(os.MkdirAll(path, perm), name)
+}
+
+// PlannedFS serves changes that are planned but not yet written on top of
+// another filesystem, so targets sharing a file are each rendered on the
+// content planned before them. Writes go to the underlying filesystem.
+type PlannedFS struct {
+	fsys    FS
+	planned map[string]*FileChange // absolute, cleaned path -> change
+}
+
+// NewPlannedFS returns a PlannedFS over fsys, defaulting to the real filesystem
+func NewPlannedFS(fsys FS) *PlannedFS {
+	return &PlannedFS{fsys: fileSystem(fsys), planned: make(map[string]*FileChange)}
+}
+
+// Plan serves change for later reads of its path
+func (p *PlannedFS) Plan(change *FileChange) {
+	if key, err := filepath.Abs(change.Path); err == nil {
+		p.planned[key] = change
+	}
+}
+
+// change returns the change planned for name, if any
+func (p *PlannedFS) change(name string) (*FileChange, bool) {
+	key, err := filepath.Abs(name)
+	if err != nil {
+		return nil, false
+	}
+	change, ok := p.planned[key]
+	return change, ok
+}
+
+// forget drops the change planned for name once it is written over
+func (p *PlannedFS) forget(name string) {
+	if key, err := filepath.Abs(name); err == nil {
+		delete(p.planned, key)
+	}
+}
+
+func (p *PlannedFS) ReadFile(name string) ([]byte, error) {
+	if change, ok := p.change(name); ok {
+		return append([]byte(nil), change.Content...), nil
+	}
+	return p.fsys.ReadFile(name)
+}
+
+func (p *PlannedFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	p.forget(name)
+	return p.fsys.WriteFile(name, data, perm)
+}
+
+func (p *PlannedFS) Stat(name string) (fs.FileInfo, error) {
+	if change, ok := p.change(name); ok {
+		return memInfo{name: filepath.Base(name), entry: memEntry{data: change.Content, mode: change.Mode.Perm(), modTime: time.Now()}}, nil
+	}
+	return p.fsys.Stat(name)
+}
+
+func (p *PlannedFS) ReadDir(name string) ([]fs.DirEntry, error) { return p.fsys.ReadDir(name) }
+
+func (p *PlannedFS) Rename(oldname, newname string) error {
+	p.forget(oldname)
+	p.forget(newname)
+	return p.fsys.Rename(oldname, newname)
+}
+
+func (p *PlannedFS) Remove(name string) error {
+	p.forget(name)
+	return p.fsys.Remove(name)
+}
+
+func (p *PlannedFS) MkdirAll(name string, perm fs.FileMode) error {
+	return p.fsys.MkdirAll(name, perm)
+}
+
+// MemFS is an in-memory filesystem, for tests and for embedding the engine
+// without touching the disk. The root directory always exists. It is safe
+// for concurrent use.
+type MemFS struct {
+	mu      sync.Mutex
+	entries map[string]*memEntry // absolute, cleaned path -> entry
+}
+
+// memEntry is a file or a directory of a MemFS
+type memEntry struct {
+	data    []byte
+	mode    fs.FileMode // includes fs.ModeDir for directories
+	modTime time.Time
+}
+
+// NewMemFS returns an empty in-memory filesystem
+func NewMemFS() *MemFS {
+	return &MemFS{entries: make(map[string]*memEntry)}
+}
+
+// key returns the map key of name
+func (m *MemFS) key(name string) (string, error) {
+	return filepath.Abs(name)
+}
+
+// lookup returns the entry at key; the root is a directory
+func (m *MemFS) lookup(key string) (*memEntry, bool) {
+	if key == filepath.Dir(key) {
+		return &memEntry{mode: fs.ModeDir | 0755}, true
+	}
+	entry, ok := m.entries[key]
+	return entry, ok
+}
+
+// isDir reports whether key is a directory
+func (m *MemFS) isDir(key string) bool {
+	entry, ok := m.lookup(key)
+	return ok && entry.mode.IsDir()
+}
+
+func (m *MemFS) ReadFile(name string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key, err := m.key(name)
+	if err != nil {
+		return nil, err
+	}
+	entry, ok := m.lookup(key)
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if entry.mode.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: errors.New("is a directory")}
+	}
+	return append([]byte(nil), entry.data...), nil
+}
+
+func (m *MemFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key, err := m.key(name)
+	if err != nil {
+		return err
+	}
+	if !m.isDir(filepath.Dir(key)) {
+		return &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if entry, ok := m.lookup(key); ok && entry.mode.IsDir() {
+		return &fs.PathError{Op: "open", Path: name, Err: errors.New("is a directory")}
+	}
+	m.entries[key] = &memEntry{data: append([]byte(nil), data...), mode: perm.Perm(), modTime: time.Now()}
+	return nil
+}
+
+func (m *MemFS) Stat(name string) (fs.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key, err := m.key(name)
+	if err != nil {
+		return nil, err
+	}
+	entry, ok := m.lookup(key)
+	if !ok {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return memInfo{name: filepath.Base(key), entry: *entry}, nil
+}
+
+func (m *MemFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key, err := m.key(name)
+	if err != nil {
+		return nil, err
+	}
+	if !m.isDir(key) {
+		if _, ok := m.lookup(key); ok {
+			return nil, &fs.PathError{Op: "readdirent", Path: name, Err: errors.New("not a directory")}
+		}
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	var entries []fs.DirEntry
+	for path, entry := range m.entries {
+		if filepath.Dir(path) == key {
+			entries = append(entries, fs.FileInfoToDirEntry(memInfo{name: filepath.Base(path), entry: *entry}))
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func (m *MemFS) Rename(oldname, newname string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	oldKey, err := m.key(oldname)
+	if err != nil {
+		return err
+	}
+	newKey, err := m.key(newname)
+	if err != nil {
+		return err
+	}
+	if _, ok := m.entries[oldKey]; !ok {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: fs.ErrNotExist}
+	}
+	if !m.isDir(filepath.Dir(newKey)) {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: fs.ErrNotExist}
+	}
+	// Move a directory together with everything below it
+	moved := make(map[string]*memEntry)
+	for path, entry := range m.entries {
+		if path == oldKey || strings.HasPrefix(path, oldKey+string(filepath.Separator)) {
+			moved[newKey+strings.TrimPrefix(path, oldKey)] = entry
+			delete(m.entries, path)
+		}
+	}
+	for path, entry := range moved {
+		m.entries[path] = entry
+	}
+	return nil
+}
+
+func (m *MemFS) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key, err := m.key(name)
+	if err != nil {
+		return err
+	}
+	if _, ok := m.entries[key]; !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	for path := range m.entries {
+		if filepath.Dir(path) == key {
+			return &fs.PathError{Op: "remove", Path: name, Err: errors.New("directory not empty")}
+		}
+	}
+	delete(m.entries, key)
+	return nil
+}
+
+func (m *MemFS) MkdirAll(name string, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key, err := m.key(name)
+	if err != nil {
+		return err
+	}
+	var missing []string
+	for dir := key; ; dir = filepath.Dir(dir) {
+		entry, ok := m.lookup(dir)
+		if ok {
+			if !entry.mode.IsDir() {
+				return &fs.PathError{Op: "mkdir", Path: name, Err: errors.New("not a directory")}
+			}
+			break
+		}
+		missing = append(missing, dir)
+	}
+	for _, dir := range missing {
+		m.entries[dir] = &memEntry{mode: fs.ModeDir | perm.Perm(), modTime: time.Now()}
+	}
+	return nil
+}
+
+// memInfo describes a MemFS entry
+type memInfo struct {
+	name  string
+	entry memEntry
+}
+
+func (i memInfo) Name() string       { return i.name }
+func (i memInfo) Size() int64        { return int64(len(i.entry.data)) }
+func (i memInfo) Mode() fs.FileMode  { return i.entry.mode }
+func (i memInfo) ModTime() time.Time { return i.entry.modTime }
+func (i memInfo) IsDir() bool        { return i.entry.mode.IsDir() }
+func (i memInfo) Sys() interface{}   { return nil }
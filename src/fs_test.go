@@ -0,0 +1,288 @@
+package src
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// memTree writes files (path -> content) into fsys, creating directories
+func memTree(t *testing.T, fsys FS, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		if err := fsys.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatalf("Failed to create directory for '%s': %v", name, err)
+		}
+		if err := fsys.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write '%s': %v", name, err)
+		}
+	}
+}
+
+// readMem reads a file from fsys, failing the test if it can't
+func readMem(t *testing.T, fsys FS, name string) string {
+	t.Helper()
+	data, err := fsys.ReadFile(name)
+	if err != nil {
+		t.Fatalf("Failed to read '%s': %v", name, err)
+	}
+	return string(data)
+}
+
+func TestMemFS(t *testing.T) {
+	fsys := NewMemFS()
+	memTree(t, fsys, map[string]string{"/a/b": "B", "/a/c/d": "D", "/a/0": "0"})
+
+	if err := fsys.WriteFile("/missing/file", nil, 0644); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Writing into a missing directory should fail with ErrNotExist, got %v", err)
+	}
+	if _, err := fsys.ReadFile("/a/nope"); !os.IsNotExist(err) {
+		t.Errorf("Expected a not-exist error, got %v", err)
+	}
+
+	entries, err := fsys.ReadDir("/a")
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if strings.Join(names, ",") != "0,b,c" || !entries[2].IsDir() {
+		t.Errorf("Expected sorted entries 0,b,c with c a directory, got %v", names)
+	}
+
+	if err := fsys.WriteFile("/a/b", []byte("B2"), 0600); err != nil {
+		t.Fatalf("Overwrite failed: %v", err)
+	}
+	info, err := fsys.Stat("/a/b")
+	if err != nil || info.Mode().Perm() != 0600 || info.Size() != 2 {
+		t.Errorf("Expected 2 bytes with mode 0600, got %v (%v)", info, err)
+	}
+
+	if err := fsys.Remove("/a/c"); err == nil {
+		t.Error("Removing a non-empty directory should fail")
+	}
+	if err := fsys.Rename("/a/c", "/a/e"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	if got := readMem(t, fsys, "/a/e/d"); got != "D" {
+		t.Errorf("Renamed directory should keep its files, got %q", got)
+	}
+	if err := fsys.Remove("/a/b"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if _, err := fsys.Stat("/a/b"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Removed file should be gone, got %v", err)
+	}
+}
+
+func TestMemFS_BuildSyncRemove(t *testing.T) {
+	fsys := NewMemFS()
+	target, partialsDir := "/mem/etc/config", "/mem/partials"
+	memTree(t, fsys, map[string]string{
+		target:                            "# My config\n",
+		partialsDir + "/base":             "Host base\n",
+		partialsDir + "/nested/work":      "Host work\n",
+		partialsDir + "/nested/skip":      "Host skip\n",
+		partialsDir + "/nested/skip.when": "os: not-" + CurrentFacts().OS + "\n",
+	})
+
+	build, err := NewPartialsBuildCommand(target, partialsDir, "#")
+	if err != nil {
+		t.Fatalf("Failed to create build command: %v", err)
+	}
+	build.SetFS(fsys)
+	build.SetOutput(io.Discard)
+	build.SetPartialOptions(PartialOptions{Recursive: true})
+	if err := build.Run(); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	built := readMem(t, fsys, target)
+	if !strings.Contains(built, "Host base\n") || !strings.Contains(built, "Host work\n") || strings.Contains(built, "Host skip") {
+		t.Fatalf("Unexpected build output:\n%s", built)
+	}
+	if _, err := os.Stat("/mem"); !os.IsNotExist(err) {
+		t.Fatalf("Build should not touch the disk, got %v", err)
+	}
+
+	// Edit the target and sync the change back into the nested partial
+	if err := fsys.WriteFile(target, []byte(strings.Replace(built, "Host work", "Host work2", 1)), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	sync := NewPartialsSyncCommand(target, partialsDir, "#", "merge")
+	sync.SetFS(fsys)
+	sync.SetOutput(io.Discard)
+	sync.SetPartialOptions(PartialOptions{Recursive: true})
+	result, err := sync.Run()
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if result.UpdatedFiles != 1 || readMem(t, fsys, partialsDir+"/nested/work") != "Host work2\n" {
+		t.Fatalf("Expected the nested partial to be updated, got %+v", result)
+	}
+
+	remove, err := NewPartialsRemoveCommand(target, "#")
+	if err != nil {
+		t.Fatalf("Failed to create remove command: %v", err)
+	}
+	remove.SetFS(fsys)
+	remove.SetOutput(io.Discard)
+	if err := remove.Run(); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if got := readMem(t, fsys, target); got != "# My config\n" {
+		t.Errorf("Expected the section to be removed, got %q", got)
+	}
+}
+
+func TestMemFS_OwnCreatesDirectories(t *testing.T) {
+	fsys := NewMemFS()
+	memTree(t, fsys, map[string]string{"/mem/partials/a": "alpha", "/mem/partials/b": "beta\n"})
+
+	own := NewPartialsOwnCommand("/mem/out/new/file", "/mem/partials", "")
+	own.SetFS(fsys)
+	own.SetOutput(io.Discard)
+	if err := own.Run(); err != nil {
+		t.Fatalf("Own failed: %v", err)
+	}
+	if got := readMem(t, fsys, "/mem/out/new/file"); got != "alpha\nbeta\n" {
+		t.Errorf("Unexpected own output %q", got)
+	}
+}
+
+func TestPrefixFS(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"etc/config":    "# My config\n",
+		"partials/base": "Host base\n",
+	})
+	fsys, err := NewPrefixFS(root)
+	if err != nil {
+		t.Fatalf("NewPrefixFS failed: %v", err)
+	}
+
+	build, err := NewPartialsBuildCommand("/etc/config", "/partials", "#")
+	if err != nil {
+		t.Fatalf("Failed to create build command: %v", err)
+	}
+	build.SetFS(fsys)
+	build.SetOutput(io.Discard)
+	if err := build.Run(); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(root, "etc", "config"))
+	if err != nil {
+		t.Fatalf("Failed to read built file: %v", err)
+	}
+	if !strings.Contains(string(got), "# Source: /partials/base\nHost base\n") {
+		t.Errorf("Source comments should carry the unprefixed path, got:\n%s", got)
+	}
+
+	_, err = fsys.ReadFile("/etc/missing")
+	if !os.IsNotExist(err) || strings.Contains(err.Error(), root) {
+		t.Errorf("Expected a not-exist error naming the unprefixed path, got %v", err)
+	}
+}
+
+func TestPrefixFS_ConfinesWrites(t *testing.T) {
+	root, outside := t.TempDir(), t.TempDir()
+	writeTree(t, root, map[string]string{"etc/config": "# My config\n"})
+	writeTree(t, outside, map[string]string{"hosts": "outside\n"})
+	links := map[string]string{
+		filepath.Join(root, "etc", "hosts"): filepath.Join(outside, "hosts"),
+		filepath.Join(root, "escape"):       outside,
+		filepath.Join(root, "etc", "alias"): filepath.Join(root, "etc", "config"),
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, link); err != nil {
+			t.Skipf("symlinks not supported: %v", err)
+		}
+	}
+	fsys, err := NewPrefixFS(root)
+	if err != nil {
+		t.Fatalf("NewPrefixFS failed: %v", err)
+	}
+
+	for _, write := range []struct {
+		name string
+		op   func() error
+	}{
+		{"file link", func() error { return fsys.WriteFile("/etc/hosts", []byte("x\n"), 0644) }},
+		{"directory link", func() error { return fsys.WriteFile("/escape/new", []byte("x\n"), 0644) }},
+		{"mkdir", func() error { return fsys.MkdirAll("/escape/sub", 0755) }},
+		{"rename", func() error { return fsys.Rename("/etc/config", "/escape/config") }},
+		{"remove", func() error { return fsys.Remove("/escape/hosts") }},
+	} {
+		err := write.op()
+		if !errors.Is(err, ErrOutsideRoot) || strings.Contains(err.Error(), root) {
+			t.Errorf("%s: expected ErrOutsideRoot naming the unprefixed path, got %v", write.name, err)
+		}
+	}
+	if got, _ := os.ReadFile(filepath.Join(outside, "hosts")); string(got) != "outside\n" {
+		t.Errorf("A file outside the root was modified: %q", got)
+	}
+	entries, _ := os.ReadDir(outside)
+	if len(entries) != 1 {
+		t.Errorf("Expected nothing new outside the root, got %d entries", len(entries))
+	}
+
+	// Links that stay inside the root are followed, and links themselves can be removed
+	if err := fsys.WriteFile("/etc/alias", []byte("aliased\n"), 0644); err != nil {
+		t.Fatalf("Write through an inside link failed: %v", err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(root, "etc", "config")); string(got) != "aliased\n" {
+		t.Errorf("Expected the link target to be written, got %q", got)
+	}
+	if err := fsys.Remove("/etc/hosts"); err != nil {
+		t.Errorf("Removing a link to outside the root failed: %v", err)
+	}
+}
+
+func TestPlannedFS_SharedTarget(t *testing.T) {
+	fsys := NewMemFS()
+	target, personalDir := targetFixture(t, fsys, "/mem", map[string]string{"hosts": "Host personal\n"})
+	memTree(t, fsys, map[string]string{"/mem/team/hosts": "Host team\n", "/mem/blocker": ""})
+
+	planned := NewPlannedFS(fsys)
+	var tx Transaction
+	tx.SetFS(fsys)
+	for section, partialsDir := range map[string]string{"personal": personalDir, "team": "/mem/team"} {
+		build, err := NewPartialsBuildCommand(target, partialsDir, "#")
+		if err != nil {
+			t.Fatalf("Failed to create build command: %v", err)
+		}
+		if err := build.SetSectionID(section); err != nil {
+			t.Fatalf("Failed to set section: %v", err)
+		}
+		build.SetFS(planned)
+		change, err := build.Plan()
+		if err != nil {
+			t.Fatalf("Plan of '%s' failed: %v", section, err)
+		}
+		planned.Plan(change)
+		tx.Add(change, nil)
+	}
+
+	// Nothing is written until the transaction commits
+	if got := readMem(t, fsys, target); got != "# My config\n" {
+		t.Fatalf("Planning wrote the target: %q", got)
+	}
+	if got := readMem(t, planned, target); !strings.Contains(got, "Host personal\n") || !strings.Contains(got, "Host team\n") {
+		t.Fatalf("Expected both sections planned, got:\n%s", got)
+	}
+
+	// A failing later write restores the content from before the first change
+	tx.Add(&FileChange{Path: "/mem/blocker/target", Content: []byte("x\n"), Mode: 0644}, nil)
+	if err := tx.Commit(); err == nil {
+		t.Fatal("Expected commit to fail")
+	}
+	if got := readMem(t, fsys, target); got != "# My config\n" {
+		t.Errorf("Expected the original target after rollback, got %q", got)
+	}
+}
@@ -0,0 +1,157 @@
+package src
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Hook events a target can run commands on
+const (
+	HookPreApply   = "pre_apply"
+	HookPostApply  = "post_apply"
+	HookPreRemove  = "pre_remove"
+	HookPostRemove = "post_remove"
+	HookPostSync   = "post_sync"
+)
+
+// DefaultHookTimeout bounds a hook without its own timeout
+const DefaultHookTimeout = 60 * time.Second
+
+// Hook is a shell command run before or after a target is modified. It is
+// written as a plain command or as a mapping with run, timeout and changed_only.
+type Hook struct {
+	Run         string        `yaml:"run"`
+	Timeout     time.Duration `yaml:"timeout"`
+	ChangedOnly bool          `yaml:"changed_only"`
+}
+
+// UnmarshalYAML accepts a plain command as well as the full mapping
+func (h *Hook) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&h.Run)
+	}
+	type plain Hook
+	return node.Decode((*plain)(h))
+}
+
+// Validate checks that the hook has a command and a usable timeout
+func (h *Hook) Validate() error {
+	if h == nil {
+		return nil
+	}
+	if h.Run == "" {
+		return fmt.Errorf("hook has no 'run' command")
+	}
+	if h.Timeout < 0 {
+		return fmt.Errorf("hook timeout must not be negative")
+	}
+	return nil
+}
+
+// Hooks are the commands a target runs around apply, remove and sync
+type Hooks struct {
+	PreApply   *Hook `yaml:"pre_apply"`
+	PostApply  *Hook `yaml:"post_apply"`
+	PreRemove  *Hook `yaml:"pre_remove"`
+	PostRemove *Hook `yaml:"post_remove"`
+	PostSync   *Hook `yaml:"post_sync"`
+}
+
+// Get returns the hook for an event, or nil
+func (h Hooks) Get(event string) *Hook {
+	switch event {
+	case HookPreApply:
+		return h.PreApply
+	case HookPostApply:
+		return h.PostApply
+	case HookPreRemove:
+		return h.PreRemove
+	case HookPostRemove:
+		return h.PostRemove
+	case HookPostSync:
+		return h.PostSync
+	}
+	return nil
+}
+
+// Validate checks every configured hook
+func (h Hooks) Validate() error {
+	for _, event := range []string{HookPreApply, HookPostApply, HookPreRemove, HookPostRemove, HookPostSync} {
+		if err := h.Get(event).Validate(); err != nil {
+			return fmt.Errorf("%s: %w", event, err)
+		}
+	}
+	return nil
+}
+
+// Merge returns h with unset hooks taken from defaults
+func (h Hooks) Merge(defaults Hooks) Hooks {
+	pick := func(hook, fallback *Hook) *Hook {
+		if hook != nil {
+			return hook
+		}
+		return fallback
+	}
+	return Hooks{
+		PreApply:   pick(h.PreApply, defaults.PreApply),
+		PostApply:  pick(h.PostApply, defaults.PostApply),
+		PreRemove:  pick(h.PreRemove, defaults.PreRemove),
+		PostRemove: pick(h.PostRemove, defaults.PostRemove),
+		PostSync:   pick(h.PostSync, defaults.PostSync),
+	}
+}
+
+// HookEnv describes the target a hook runs for. It is passed to the command
+// as PARTS_TARGET, PARTS_TARGET_PATH, PARTS_HOOK and PARTS_CHANGED (1 or 0).
+type HookEnv struct {
+	Target  string
+	Path    string
+	Event   string
+	Changed bool
+}
+
+// ShouldRun reports whether the hook fires for a change: changed_only hooks
+// are skipped when the file content is unchanged
+func (h *Hook) ShouldRun(changed bool) bool {
+	return h != nil && (changed || !h.ChangedOnly)
+}
+
+// Execute runs the hook through the platform shell in dir, streaming its
+// output to stdout and stderr. A non-zero exit or a timeout is an error.
+func (h *Hook) Execute(env HookEnv, dir string, stdout, stderr io.Writer) error {
+	timeout := h.Timeout
+	if timeout == 0 {
+		timeout = DefaultHookTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	changed := "0"
+	if env.Changed {
+		changed = "1"
+	}
+	command := ShellCommand(ctx, h.Run)
+	command.Dir = dir
+	command.Stdout = stdout
+	command.Stderr = stderr
+	command.Env = append(os.Environ(),
+		"PARTS_TARGET="+env.Target,
+		"PARTS_TARGET_PATH="+env.Path,
+		"PARTS_HOOK="+env.Event,
+		"PARTS_CHANGED="+changed,
+	)
+
+	err := command.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("%s hook timed out after %s", env.Event, timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("%s hook '%s' failed: %w", env.Event, h.Run, err)
+	}
+	return nil
+}
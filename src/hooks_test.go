@@ -0,0 +1,84 @@
+package src
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestHooks_Unmarshal(t *testing.T) {
+	var hooks Hooks
+	input := `
+pre_apply: nginx -t
+post_apply:
+  run: systemctl reload nginx
+  timeout: 10s
+  changed_only: true
+`
+	if err := yaml.Unmarshal([]byte(input), &hooks); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if hooks.PreApply == nil || hooks.PreApply.Run != "nginx -t" || hooks.PreApply.ChangedOnly {
+		t.Errorf("Unexpected pre_apply: %+v", hooks.PreApply)
+	}
+	post := hooks.Get(HookPostApply)
+	if post == nil || post.Run != "systemctl reload nginx" || post.Timeout != 10*time.Second || !post.ChangedOnly {
+		t.Errorf("Unexpected post_apply: %+v", post)
+	}
+	if hooks.Get(HookPostSync) != nil {
+		t.Error("Expected no post_sync hook")
+	}
+
+	if err := (Hooks{PostSync: &Hook{}}).Validate(); err == nil || !strings.Contains(err.Error(), "post_sync") {
+		t.Errorf("Expected a missing run error, got %v", err)
+	}
+
+	merged := Hooks{PreApply: &Hook{Run: "target"}}.Merge(Hooks{PreApply: &Hook{Run: "default"}, PostSync: &Hook{Run: "sync"}})
+	if merged.PreApply.Run != "target" || merged.PostSync == nil || merged.PostSync.Run != "sync" {
+		t.Errorf("Unexpected merge: %+v", merged)
+	}
+}
+
+func TestHook_Execute(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("requires /bin/sh")
+	}
+	dir := t.TempDir()
+
+	var stdout, stderr bytes.Buffer
+	hook := &Hook{Run: `echo "$PARTS_TARGET $PARTS_TARGET_PATH $PARTS_HOOK $PARTS_CHANGED $(pwd)"; echo oops >&2`}
+	env := HookEnv{Target: "nginx", Path: "/etc/nginx/conf.d/app.conf", Event: HookPostApply, Changed: true}
+	if err := hook.Execute(env, dir, &stdout, &stderr); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	want := "nginx /etc/nginx/conf.d/app.conf post_apply 1 "
+	if !strings.HasPrefix(stdout.String(), want) || !strings.Contains(stdout.String(), dir) {
+		t.Errorf("Expected %q and the working directory, got %q", want, stdout.String())
+	}
+	if stderr.String() != "oops\n" {
+		t.Errorf("Expected stderr to be passed through, got %q", stderr.String())
+	}
+
+	failing := &Hook{Run: "exit 3"}
+	if err := failing.Execute(env, dir, &stdout, &stderr); err == nil || !strings.Contains(err.Error(), "post_apply hook 'exit 3' failed") {
+		t.Errorf("Expected a hook failure, got %v", err)
+	}
+
+	slow := &Hook{Run: "exec sleep 5", Timeout: 50 * time.Millisecond}
+	if err := slow.Execute(env, dir, &stdout, &stderr); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Expected a timeout, got %v", err)
+	}
+
+	changedOnly := &Hook{Run: "true", ChangedOnly: true}
+	if changedOnly.ShouldRun(false) || !changedOnly.ShouldRun(true) || !(&Hook{Run: "true"}).ShouldRun(false) {
+		t.Error("Unexpected ShouldRun result")
+	}
+	var none *Hook
+	if none.ShouldRun(true) {
+		t.Error("Expected a nil hook never to run")
+	}
+}
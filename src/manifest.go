@@ -3,6 +3,7 @@ package src
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"sort"
 
 	"gopkg.in/yaml.v3"
@@ -10,24 +11,74 @@ import (
 
 // TargetConfig represents a single target in the manifest
 type TargetConfig struct {
-	Target   string `yaml:"target"`
-	Partials string `yaml:"partials"`
-	Comment  string `yaml:"comment"`
-	Mode     string `yaml:"mode"`
-	Backup   *bool  `yaml:"backup"`
+	Target     string                 `yaml:"target"`
+	Partials   string                 `yaml:"partials"`
+	Comment    string                 `yaml:"comment"`
+	Mode       string                 `yaml:"mode"`
+	Section    string                 `yaml:"section"`
+	Placement  Placement              `yaml:"placement"`
+	Recursive  bool                   `yaml:"recursive"`
+	Include    []string               `yaml:"include"`
+	Exclude    []string               `yaml:"exclude"`
+	Order      []string               `yaml:"order"`
+	Sort       string                 `yaml:"sort"`
+	Priority   map[string]int         `yaml:"priority"`
+	Template   bool                   `yaml:"template"`
+	Vars       map[string]interface{} `yaml:"vars"`
+	When       *When                  `yaml:"when"`
+	Validate   string                 `yaml:"validate"`
+	Hooks      Hooks                  `yaml:"hooks"`
+	Backup     *bool                  `yaml:"backup"`
+	AdoptName  string                 `yaml:"adopt_name"`
+	Checksum   *bool                  `yaml:"checksum"`
+	Escape     *bool                  `yaml:"escape_markers"`
+	Delimit    *bool                  `yaml:"delimit_partials"`
+	SourcePath string                 `yaml:"source_path"`
+
+	manifestDir string // directory of the manifest, set by ResolvedTarget
+}
+
+// SourcePaths returns how the target's partial paths are written and resolved
+func (t TargetConfig) SourcePaths() SourcePaths {
+	return SourcePaths{Style: t.SourcePath, ManifestDir: t.manifestDir}
+}
+
+// PartialOptions returns the partial selection and ordering configured for the target
+func (t TargetConfig) PartialOptions() PartialOptions {
+	return PartialOptions{
+		Recursive: t.Recursive,
+		Include:   t.Include,
+		Exclude:   t.Exclude,
+		Order:     t.Order,
+		Sort:      t.Sort,
+		Priority:  t.Priority,
+	}
 }
 
 // ManifestDefaults represents the defaults section of the manifest
 type ManifestDefaults struct {
-	Comment string `yaml:"comment"`
-	Mode    string `yaml:"mode"`
-	Backup  bool   `yaml:"backup"`
+	Comment    string                 `yaml:"comment"`
+	Mode       string                 `yaml:"mode"`
+	Placement  Placement              `yaml:"placement"`
+	Sort       string                 `yaml:"sort"`
+	Vars       map[string]interface{} `yaml:"vars"`
+	Backup     bool                   `yaml:"backup"`
+	BackupDir  string                 `yaml:"backup_dir"`
+	BackupKeep int                    `yaml:"backup_keep"`
+	StateFile  string                 `yaml:"state_file"`
+	Hooks      Hooks                  `yaml:"hooks"`
+	AdoptName  string                 `yaml:"adopt_name"`
+	Checksum   bool                   `yaml:"checksum"`
+	Escape     bool                   `yaml:"escape_markers"`
+	Delimit    bool                   `yaml:"delimit_partials"`
+	SourcePath string                 `yaml:"source_path"`
 }
 
 // Manifest represents a parsed .parts.yaml file
 type Manifest struct {
 	Defaults ManifestDefaults        `yaml:"defaults"`
 	Targets  map[string]TargetConfig `yaml:"targets"`
+	path     string
 }
 
 // LoadManifest reads and validates a .parts.yaml file
@@ -45,6 +96,7 @@ func LoadManifest(path string) (*Manifest, error) {
 	if err := manifest.validate(); err != nil {
 		return nil, err
 	}
+	manifest.path = path
 
 	return &manifest, nil
 }
@@ -67,6 +119,40 @@ func (m *Manifest) validate() error {
 		if !validModes[target.Mode] {
 			return fmt.Errorf("target '%s': invalid mode '%s' (must be 'merge' or 'own')", name, target.Mode)
 		}
+		if err := ValidateSectionID(target.Section); err != nil {
+			return fmt.Errorf("target '%s': %w", name, err)
+		}
+		if err := target.PartialOptions().Validate(); err != nil {
+			return fmt.Errorf("target '%s': %w", name, err)
+		}
+		if err := target.When.Validate(); err != nil {
+			return fmt.Errorf("target '%s': %w", name, err)
+		}
+		if _, err := ParseValidator(target.Validate); err != nil {
+			return fmt.Errorf("target '%s': %w", name, err)
+		}
+		if err := target.Hooks.Validate(); err != nil {
+			return fmt.Errorf("target '%s': hooks: %w", name, err)
+		}
+		if err := ValidateAdoptName(target.AdoptName); err != nil {
+			return fmt.Errorf("target '%s': %w", name, err)
+		}
+		if err := ValidateSourcePathStyle(target.SourcePath); err != nil {
+			return fmt.Errorf("target '%s': %w", name, err)
+		}
+	}
+
+	if err := (PartialOptions{Sort: m.Defaults.Sort}).Validate(); err != nil {
+		return fmt.Errorf("defaults: %w", err)
+	}
+	if err := m.Defaults.Hooks.Validate(); err != nil {
+		return fmt.Errorf("defaults: hooks: %w", err)
+	}
+	if err := ValidateAdoptName(m.Defaults.AdoptName); err != nil {
+		return fmt.Errorf("defaults: %w", err)
+	}
+	if err := ValidateSourcePathStyle(m.Defaults.SourcePath); err != nil {
+		return fmt.Errorf("defaults: %w", err)
 	}
 
 	return nil
@@ -93,14 +179,70 @@ func (m *Manifest) ResolvedTarget(name string) TargetConfig {
 		}
 	}
 
+	if target.Placement.IsZero() {
+		target.Placement = m.Defaults.Placement
+	}
+
+	if target.Sort == "" {
+		target.Sort = m.Defaults.Sort
+	}
+
+	// Per-target vars override default vars key by key
+	if len(m.Defaults.Vars) > 0 {
+		vars := make(map[string]interface{}, len(m.Defaults.Vars)+len(target.Vars))
+		for key, value := range m.Defaults.Vars {
+			vars[key] = value
+		}
+		for key, value := range target.Vars {
+			vars[key] = value
+		}
+		target.Vars = vars
+	}
+
 	if target.Backup == nil {
 		backup := m.Defaults.Backup
 		target.Backup = &backup
 	}
 
+	if target.Checksum == nil {
+		checksum := m.Defaults.Checksum
+		target.Checksum = &checksum
+	}
+
+	if target.Escape == nil {
+		escape := m.Defaults.Escape
+		target.Escape = &escape
+	}
+
+	if target.Delimit == nil {
+		delimit := m.Defaults.Delimit
+		target.Delimit = &delimit
+	}
+
+	if target.SourcePath == "" {
+		target.SourcePath = m.Defaults.SourcePath
+	}
+	target.manifestDir = filepath.Dir(m.path)
+
+	target.Hooks = target.Hooks.Merge(m.Defaults.Hooks)
+
+	if target.AdoptName == "" {
+		target.AdoptName = m.Defaults.AdoptName
+	}
+
 	return target
 }
 
+// BackupStore returns the backup store configured by the manifest defaults.
+// backup_keep of 0 keeps DefaultBackupKeep copies; a negative value keeps all.
+func (m *Manifest) BackupStore() (*BackupStore, error) {
+	keep := m.Defaults.BackupKeep
+	if keep == 0 {
+		keep = DefaultBackupKeep
+	}
+	return NewBackupStore(m.Defaults.BackupDir, keep)
+}
+
 // FilterTargets returns sorted target names, filtered by the given names.
 // If names is nil or empty, returns all target names sorted.
 // Returns an error if any requested name doesn't exist.
@@ -127,3 +269,40 @@ func (m *Manifest) FilterTargets(names []string) ([]string, error) {
 
 	return names, nil
 }
+
+// Path returns the file the manifest was loaded from
+func (m *Manifest) Path() string {
+	return m.path
+}
+
+// StatePath returns the state file of the manifest: state_file from the
+// defaults (relative to the manifest's directory) or DefaultStatePath
+func (m *Manifest) StatePath() (string, error) {
+	if m.Defaults.StateFile == "" {
+		return DefaultStatePath(m.path)
+	}
+	expanded, err := ExpandTildePrefix(m.Defaults.StateFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to expand state file path: %w", err)
+	}
+	if !filepath.IsAbs(expanded) {
+		expanded = filepath.Join(filepath.Dir(m.path), expanded)
+	}
+	return expanded, nil
+}
+
+// LoadState reads the manifest's state file
+func (m *Manifest) LoadState() (*State, error) {
+	path, err := m.StatePath()
+	if err != nil {
+		return nil, err
+	}
+	state, err := LoadState(path)
+	if err != nil {
+		return nil, err
+	}
+	if abs, absErr := filepath.Abs(m.path); absErr == nil {
+		state.Manifest = abs
+	}
+	return state, nil
+}
@@ -3,6 +3,7 @@ package src
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -343,3 +344,278 @@ func containsSubstring(s, sub string) bool {
 	}
 	return false
 }
+
+func TestLoadManifest_InvalidSectionID(t *testing.T) {
+	dir := t.TempDir()
+	manifestPath := filepath.Join(dir, ".parts.yaml")
+	yaml := `targets:
+  ssh:
+    target: /tmp/ssh-config
+    partials: ./ssh/
+    section: "two words"
+`
+	if err := os.WriteFile(manifestPath, []byte(yaml), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	if _, err := LoadManifest(manifestPath); err == nil || !containsString(err.Error(), "invalid section ID") {
+		t.Errorf("Expected invalid section ID error, got %v", err)
+	}
+}
+
+func TestLoadManifest_Ordering(t *testing.T) {
+	dir := t.TempDir()
+	manifestPath := filepath.Join(dir, ".parts.yaml")
+	yaml := `defaults:
+  sort: natural
+targets:
+  ssh:
+    target: /tmp/ssh-config
+    partials: ./ssh/
+    order: [base, work]
+    priority:
+      local: 100
+  hosts:
+    target: /etc/hosts
+    partials: ./hosts/
+    sort: lexical
+`
+	if err := os.WriteFile(manifestPath, []byte(yaml), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	manifest, err := LoadManifest(manifestPath)
+	if err != nil {
+		t.Fatalf("LoadManifest failed: %v", err)
+	}
+	ssh := manifest.ResolvedTarget("ssh").PartialOptions()
+	if ssh.Sort != SortNatural || len(ssh.Order) != 2 || ssh.Order[0] != "base" || ssh.Priority["local"] != 100 {
+		t.Errorf("Unexpected ssh ordering options: %+v", ssh)
+	}
+	if hosts := manifest.ResolvedTarget("hosts"); hosts.Sort != SortLexical {
+		t.Errorf("Expected target sort to override default, got %q", hosts.Sort)
+	}
+
+	bad := `targets:
+  ssh:
+    target: /tmp/ssh-config
+    partials: ./ssh/
+    sort: shuffled
+`
+	if err := os.WriteFile(manifestPath, []byte(bad), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if _, err := LoadManifest(manifestPath); err == nil || !containsString(err.Error(), "invalid sort") {
+		t.Errorf("Expected invalid sort error, got %v", err)
+	}
+}
+
+func TestLoadManifest_TemplateVars(t *testing.T) {
+	dir := t.TempDir()
+	manifestPath := filepath.Join(dir, ".parts.yaml")
+	yaml := `defaults:
+  vars:
+    user: alice
+    email: alice@example.com
+targets:
+  ssh:
+    target: /tmp/ssh-config
+    partials: ./ssh/
+    template: true
+    vars:
+      user: deploy
+  git:
+    target: /tmp/gitconfig
+    partials: ./git/
+`
+	if err := os.WriteFile(manifestPath, []byte(yaml), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	manifest, err := LoadManifest(manifestPath)
+	if err != nil {
+		t.Fatalf("LoadManifest failed: %v", err)
+	}
+	ssh := manifest.ResolvedTarget("ssh")
+	if !ssh.Template || ssh.Vars["user"] != "deploy" || ssh.Vars["email"] != "alice@example.com" {
+		t.Errorf("Expected target vars to override defaults, got template=%v vars=%v", ssh.Template, ssh.Vars)
+	}
+	if git := manifest.ResolvedTarget("git"); git.Template || git.Vars["user"] != "alice" {
+		t.Errorf("Expected default vars on untemplated target, got %v", git.Vars)
+	}
+	if manifest.Defaults.Vars["user"] != "alice" {
+		t.Error("ResolvedTarget must not modify the default vars")
+	}
+}
+
+func TestLoadManifest_When(t *testing.T) {
+	dir := t.TempDir()
+	manifestPath := filepath.Join(dir, ".parts.yaml")
+	yaml := `targets:
+  ssh:
+    target: /tmp/ssh-config
+    partials: ./ssh/
+    when:
+      host: "dev-*"
+      os: [linux, darwin]
+`
+	if err := os.WriteFile(manifestPath, []byte(yaml), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	manifest, err := LoadManifest(manifestPath)
+	if err != nil {
+		t.Fatalf("LoadManifest failed: %v", err)
+	}
+	when := manifest.ResolvedTarget("ssh").When
+	if when == nil || len(when.OS) != 2 || when.Host[0] != "dev-*" {
+		t.Errorf("Unexpected when: %+v", when)
+	}
+
+	bad := `targets:
+  ssh:
+    target: /tmp/ssh-config
+    partials: ./ssh/
+    when:
+      host: "[dev"
+`
+	if err := os.WriteFile(manifestPath, []byte(bad), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if _, err := LoadManifest(manifestPath); err == nil || !containsString(err.Error(), "invalid when pattern") {
+		t.Errorf("Expected invalid when pattern error, got %v", err)
+	}
+}
+
+func TestManifest_StatePath(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", "/tmp/state")
+	dir := t.TempDir()
+	manifestPath := filepath.Join(dir, ".parts.yaml")
+	if err := os.WriteFile(manifestPath, []byte("targets:\n  ssh:\n    target: /tmp/ssh-config\n    partials: ./ssh/\n"), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	manifest, err := LoadManifest(manifestPath)
+	if err != nil {
+		t.Fatalf("LoadManifest failed: %v", err)
+	}
+	path, err := manifest.StatePath()
+	if err != nil || !strings.HasPrefix(path, "/tmp/state/parts/state/") {
+		t.Errorf("Expected the default state path, got %s (%v)", path, err)
+	}
+
+	manifest.Defaults.StateFile = ".parts.state.json"
+	path, err = manifest.StatePath()
+	if err != nil || path != filepath.Join(dir, ".parts.state.json") {
+		t.Errorf("Expected state_file relative to the manifest, got %s (%v)", path, err)
+	}
+	state, err := manifest.LoadState()
+	if err != nil || state.Path() != path || state.Manifest != manifestPath {
+		t.Errorf("Unexpected state %+v (%v)", state, err)
+	}
+}
+
+func TestLoadManifest_Validate(t *testing.T) {
+	dir := t.TempDir()
+	manifestPath := filepath.Join(dir, ".parts.yaml")
+	write := func(validate string) error {
+		yaml := "targets:\n  ssh:\n    target: /tmp/ssh-config\n    partials: ./ssh/\n    validate: " + validate + "\n"
+		if err := os.WriteFile(manifestPath, []byte(yaml), 0644); err != nil {
+			t.Fatalf("Failed: %v", err)
+		}
+		_, err := LoadManifest(manifestPath)
+		return err
+	}
+
+	for _, valid := range []string{"ssh", "hosts", "\"sshd -t -f {}\""} {
+		if err := write(valid); err != nil {
+			t.Errorf("Expected validate: %s to load, got %v", valid, err)
+		}
+	}
+	if err := write("sshconfig"); err == nil || !strings.Contains(err.Error(), "unknown validator") {
+		t.Errorf("Expected an unknown validator error, got %v", err)
+	}
+}
+
+func TestLoadManifest_Hooks(t *testing.T) {
+	dir := t.TempDir()
+	manifestPath := filepath.Join(dir, ".parts.yaml")
+	yaml := `defaults:
+  hooks:
+    post_apply: echo default
+targets:
+  nginx:
+    target: /tmp/nginx.conf
+    partials: ./nginx/
+    hooks:
+      post_apply:
+        run: systemctl reload nginx
+        changed_only: true
+  ssh:
+    target: /tmp/ssh-config
+    partials: ./ssh/
+`
+	if err := os.WriteFile(manifestPath, []byte(yaml), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	manifest, err := LoadManifest(manifestPath)
+	if err != nil {
+		t.Fatalf("LoadManifest failed: %v", err)
+	}
+	if hook := manifest.ResolvedTarget("nginx").Hooks.PostApply; hook.Run != "systemctl reload nginx" || !hook.ChangedOnly {
+		t.Errorf("Expected the target hook to override the default, got %+v", hook)
+	}
+	if hook := manifest.ResolvedTarget("ssh").Hooks.PostApply; hook == nil || hook.Run != "echo default" {
+		t.Errorf("Expected the default hook, got %+v", hook)
+	}
+
+	invalid := "targets:\n  ssh:\n    target: /tmp/x\n    partials: ./ssh/\n    hooks:\n      pre_remove:\n        timeout: 5s\n"
+	if err := os.WriteFile(manifestPath, []byte(invalid), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if _, err := LoadManifest(manifestPath); err == nil || !strings.Contains(err.Error(), "pre_remove: hook has no 'run' command") {
+		t.Errorf("Expected a hook validation error, got %v", err)
+	}
+}
+
+func TestLoadManifest_SourcePath(t *testing.T) {
+	dir := t.TempDir()
+	manifestPath := filepath.Join(dir, ".parts.yaml")
+	yaml := `defaults:
+  source_path: partials
+targets:
+  ssh:
+    target: /tmp/ssh-config
+    partials: ./ssh/
+  hosts:
+    target: /etc/hosts
+    partials: ./hosts/
+    source_path: manifest
+`
+	if err := os.WriteFile(manifestPath, []byte(yaml), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	manifest, err := LoadManifest(manifestPath)
+	if err != nil {
+		t.Fatalf("LoadManifest failed: %v", err)
+	}
+	if ssh := manifest.ResolvedTarget("ssh").SourcePaths(); ssh.Style != SourcePathPartials {
+		t.Errorf("Expected default source path style, got %q", ssh.Style)
+	}
+	hosts := manifest.ResolvedTarget("hosts").SourcePaths()
+	if hosts.Style != SourcePathManifest || hosts.ManifestDir != filepath.Dir(manifest.Path()) {
+		t.Errorf("Unexpected hosts source paths: %+v", hosts)
+	}
+
+	bad := `targets:
+  ssh:
+    target: /tmp/ssh-config
+    partials: ./ssh/
+    source_path: relative
+`
+	if err := os.WriteFile(manifestPath, []byte(bad), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if _, err := LoadManifest(manifestPath); err == nil || !containsString(err.Error(), "invalid source path style") {
+		t.Errorf("Expected invalid source path style error, got %v", err)
+	}
+}
@@ -0,0 +1,238 @@
+package src
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// sectionIDPattern restricts section IDs to a single marker-safe word
+var sectionIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ValidateSectionID checks that id can be embedded in a marker line.
+// The empty ID selects the unnamed section used before section IDs existed.
+func ValidateSectionID(id string) error {
+	if id == "" || sectionIDPattern.MatchString(id) {
+		return nil
+	}
+	return fmt.Errorf("invalid section ID '%s' (use letters, digits, '.', '_' and '-')", id)
+}
+
+// markerText returns the marker word, followed by the section ID when named
+func markerText(marker, id string) string {
+	if id == "" {
+		return marker
+	}
+	return marker + " " + id
+}
+
+// buildMarkerBlock constructs the three-line header or footer block around a marker
+func buildMarkerBlock(style CommentStyle, marker, id string) string {
+	text := markerText(marker, id)
+	if style.End != "" {
+		// Multi-character comment style with proper header block
+		return fmt.Sprintf("%s\n%s %s\n%s", style.Start, style.Start, text, style.End)
+	}
+	// Single-character comment style with header block
+	return fmt.Sprintf("%s %s\n%s %s\n%s %s",
+		style.Start, MarkerSeparator, style.Start, text, style.Start, MarkerSeparator)
+}
+
+// buildStartFlag constructs the start marker string for a given comment style and section
+func buildStartFlag(style CommentStyle, id string) string {
+	return buildMarkerBlock(style, PartialStartMarker, id)
+}
+
+// buildEndFlag constructs the end marker string for a given comment style and section
+func buildEndFlag(style CommentStyle, id string) string {
+	return buildMarkerBlock(style, PartialEndMarker, id)
+}
+
+// Kinds of MarkerError
+const (
+	MarkerMissingEnd   = "missing end"
+	MarkerMissingStart = "missing start"
+	MarkerReversed     = "reversed"
+	MarkerDuplicate    = "duplicate"
+	MarkerNested       = "nested"
+	MarkerExtraEnd     = "extra end"
+)
+
+// ErrMalformedSection is wrapped by every MarkerError
+var ErrMalformedSection = errors.New("malformed partials section")
+
+// MarkerError describes broken markers of a section: Line is the offending
+// marker line and Related the line of the marker it conflicts with
+type MarkerError struct {
+	Kind    string
+	Line    int
+	Related int
+	Section string // ID of a nested section, when it isn't the one parsed
+}
+
+func (e *MarkerError) Error() string {
+	var problem string
+	switch e.Kind {
+	case MarkerMissingEnd:
+		problem = "start marker has no end marker"
+	case MarkerMissingStart:
+		problem = "end marker has no start marker"
+	case MarkerReversed:
+		problem = fmt.Sprintf("end marker comes before the start marker at line %d", e.Related)
+	case MarkerDuplicate:
+		problem = fmt.Sprintf("second section with the same name (the first starts at line %d)", e.Related)
+	case MarkerNested:
+		problem = fmt.Sprintf("start marker inside the section starting at line %d", e.Related)
+		if e.Section != "" {
+			problem = fmt.Sprintf("section '%s' starts inside the section starting at line %d", e.Section, e.Related)
+		}
+	case MarkerExtraEnd:
+		problem = fmt.Sprintf("extra end marker (the section ends at line %d)", e.Related)
+	default:
+		problem = e.Kind
+	}
+	return fmt.Sprintf("line %d: %s", e.Line, problem)
+}
+
+func (e *MarkerError) Unwrap() error {
+	return ErrMalformedSection
+}
+
+// Section locates a well-formed PARTIALS section in a file. The marker
+// blocks are the marker lines plus the comment lines buildMarkerBlock puts
+// around them, where those survived.
+type Section struct {
+	Start     int // first byte of the start block
+	BodyStart int // first byte after the start block's line
+	BodyEnd   int // first byte of the end block
+	End       int // just past the end block, excluding its newline
+	StartLine int // 1-based line of the start marker
+	EndLine   int // 1-based line of the end marker
+}
+
+// markerLine is a start or end marker line of any section
+type markerLine struct {
+	start  bool
+	id     string
+	line   int // 1-based
+	offset int // first byte of the line
+	end    int // just past the line, excluding its newline
+}
+
+// scanMarkers returns the marker lines of every section written in style
+func scanMarkers(content string, style CommentStyle) []markerLine {
+	var markers []markerLine
+	offset := 0
+	for i, line := range strings.SplitAfter(content, "\n") {
+		text := strings.TrimRight(line, " \t\r\n")
+		for _, kind := range []struct {
+			marker string
+			start  bool
+		}{{PartialStartMarker, true}, {PartialEndMarker, false}} {
+			prefix := style.Start + " " + kind.marker
+			if text == prefix || strings.HasPrefix(text, prefix+" ") {
+				markers = append(markers, markerLine{
+					start:  kind.start,
+					id:     strings.TrimSpace(strings.TrimPrefix(text, prefix)),
+					line:   i + 1,
+					offset: offset,
+					end:    offset + len(strings.TrimRight(line, "\n")),
+				})
+			}
+		}
+		offset += len(line)
+	}
+	return markers
+}
+
+// blockBounds returns the byte range of the marker block around m: the
+// marker line and, if they match, the lines before and after it
+func blockBounds(content string, style CommentStyle, m markerLine) (start, end int) {
+	lines := strings.Split(buildMarkerBlock(style, "", ""), "\n")
+	before, after := lines[0], lines[len(lines)-1]
+
+	start, end = m.offset, m.end
+	if m.offset > 0 {
+		prevStart := strings.LastIndexByte(content[:m.offset-1], '\n') + 1
+		if strings.TrimRight(content[prevStart:m.offset-1], " \t\r") == before {
+			start = prevStart
+		}
+	}
+	if m.end < len(content) {
+		nextStart := m.end + 1
+		nextEnd := len(content)
+		if i := strings.IndexByte(content[nextStart:], '\n'); i != -1 {
+			nextEnd = nextStart + i
+		}
+		if strings.TrimRight(content[nextStart:nextEnd], " \t\r") == after {
+			end = nextEnd
+		}
+	}
+	return start, end
+}
+
+// malformedSection explains a MarkerError found in path
+func malformedSection(path string, err error) error {
+	return fmt.Errorf("partials section in '%s' is malformed: %w (fix it with 'parts repair %s')", path, err, path)
+}
+
+// parseSection locates the section with the given ID in content. It returns
+// nil without error when the file has no markers for the section, and a
+// *MarkerError when its markers are missing, reversed, duplicated or nested.
+// Sections with other IDs (or the unnamed section) never match.
+func parseSection(content string, style CommentStyle, id string) (*Section, error) {
+	var starts, ends, others []markerLine
+	for _, m := range scanMarkers(content, style) {
+		switch {
+		case m.id != id:
+			if m.start {
+				others = append(others, m)
+			}
+		case m.start:
+			starts = append(starts, m)
+		default:
+			ends = append(ends, m)
+		}
+	}
+
+	switch {
+	case len(starts) == 0 && len(ends) == 0:
+		return nil, nil
+	case len(starts) == 0:
+		return nil, &MarkerError{Kind: MarkerMissingStart, Line: ends[0].line}
+	case len(ends) == 0:
+		return nil, &MarkerError{Kind: MarkerMissingEnd, Line: starts[0].line}
+	case ends[0].line < starts[0].line:
+		return nil, &MarkerError{Kind: MarkerReversed, Line: ends[0].line, Related: starts[0].line}
+	case len(starts) > 1 && starts[1].line < ends[0].line:
+		return nil, &MarkerError{Kind: MarkerNested, Line: starts[1].line, Related: starts[0].line}
+	case len(starts) > 1:
+		return nil, &MarkerError{Kind: MarkerDuplicate, Line: starts[1].line, Related: starts[0].line}
+	case len(ends) > 1:
+		return nil, &MarkerError{Kind: MarkerExtraEnd, Line: ends[1].line, Related: ends[0].line}
+	}
+	start, end := starts[0], ends[0]
+	for _, other := range others {
+		if other.line > start.line && other.line < end.line {
+			return nil, &MarkerError{Kind: MarkerNested, Line: other.line, Related: start.line, Section: other.id}
+		}
+	}
+
+	section := &Section{StartLine: start.line, EndLine: end.line}
+	var startBlockEnd int
+	section.Start, startBlockEnd = blockBounds(content, style, start)
+	section.BodyEnd, section.End = blockBounds(content, style, end)
+	// In an empty section a lone comment line can't belong to both blocks
+	if section.BodyEnd < startBlockEnd {
+		section.BodyEnd = end.offset
+	}
+	section.BodyStart = startBlockEnd
+	if section.BodyStart < len(content) && content[section.BodyStart] == '\n' {
+		section.BodyStart++
+	}
+	if section.BodyStart > section.BodyEnd {
+		section.BodyStart = section.BodyEnd
+	}
+	return section, nil
+}
@@ -0,0 +1,242 @@
+package src
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateSectionID(t *testing.T) {
+	valid := []string{"", "ssh", "team-hosts", "personal_v2", "a.b"}
+	for _, id := range valid {
+		if err := ValidateSectionID(id); err != nil {
+			t.Errorf("Expected '%s' to be valid, got %v", id, err)
+		}
+	}
+	invalid := []string{"two words", "-leading", "new\nline", "x>>"}
+	for _, id := range invalid {
+		if err := ValidateSectionID(id); err == nil {
+			t.Errorf("Expected '%s' to be rejected", id)
+		}
+	}
+}
+
+func TestBuildStartFlag_WithSectionID(t *testing.T) {
+	hash := CommentStyle{Start: "#"}
+	if got := buildStartFlag(hash, "team"); !strings.Contains(got, "# PARTIALS>>>>> team\n") {
+		t.Errorf("Expected named marker line, got %q", got)
+	}
+	block := CommentStyle{Start: "/*", End: "*/"}
+	if got := buildEndFlag(block, "team"); got != "/*\n/* PARTIALS<<<<< team\n*/" {
+		t.Errorf("Unexpected block-comment end flag %q", got)
+	}
+}
+
+// writePartials creates a partials directory holding a single partial
+func writePartials(t *testing.T, dir, name, content string) string {
+	partialsDir := filepath.Join(dir, name)
+	if err := os.MkdirAll(partialsDir, 0755); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(partialsDir, name), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	return partialsDir
+}
+
+// buildSection runs a build command for the given section
+func buildSection(t *testing.T, target, partialsDir, id string) {
+	command, err := NewPartialsBuildCommand(target, partialsDir, "#")
+	if err != nil {
+		t.Fatalf("Failed to create command: %v", err)
+	}
+	if err := command.SetSectionID(id); err != nil {
+		t.Fatalf("SetSectionID failed: %v", err)
+	}
+	if err := command.Run(); err != nil {
+		t.Fatalf("Build of section '%s' failed: %v", id, err)
+	}
+}
+
+func TestNamedSections_Independent(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "hosts")
+	if err := os.WriteFile(target, []byte("127.0.0.1 localhost\n"), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	personal := writePartials(t, dir, "personal", "10.0.0.1 nas\n")
+	team := writePartials(t, dir, "team", "10.1.0.1 build\n")
+
+	buildSection(t, target, personal, "personal")
+	buildSection(t, target, team, "team")
+
+	// Rebuilding one section leaves the other in place and does not duplicate it
+	if err := os.WriteFile(filepath.Join(personal, "personal"), []byte("10.0.0.2 nas\n"), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	buildSection(t, target, personal, "personal")
+
+	content, _ := os.ReadFile(target)
+	result := string(content)
+	if strings.Count(result, "# PARTIALS>>>>> personal\n") != 1 || strings.Count(result, "# PARTIALS>>>>> team\n") != 1 {
+		t.Fatalf("Expected one of each section, got:\n%s", result)
+	}
+	if !strings.Contains(result, "10.0.0.2 nas") || strings.Contains(result, "10.0.0.1 nas") {
+		t.Errorf("Personal section not rebuilt:\n%s", result)
+	}
+	if !strings.Contains(result, "10.1.0.1 build") {
+		t.Errorf("Team section lost:\n%s", result)
+	}
+
+	// Removing the team section leaves the personal one alone
+	remove, _ := NewPartialsRemoveCommand(target, "#")
+	if err := remove.SetSectionID("team"); err != nil {
+		t.Fatalf("SetSectionID failed: %v", err)
+	}
+	if err := remove.Run(); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	content, _ = os.ReadFile(target)
+	result = string(content)
+	if strings.Contains(result, "PARTIALS>>>>> team") || strings.Contains(result, "10.1.0.1 build") {
+		t.Errorf("Team section should be removed:\n%s", result)
+	}
+	if !strings.Contains(result, "# PARTIALS>>>>> personal\n") || !strings.Contains(result, "10.0.0.2 nas") {
+		t.Errorf("Personal section should survive:\n%s", result)
+	}
+}
+
+func TestNamedSections_UnnamedSectionUntouched(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "config")
+	if err := os.WriteFile(target, []byte(""), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	legacy := writePartials(t, dir, "legacy", "Host legacy\n")
+	team := writePartials(t, dir, "team", "Host team\n")
+
+	buildSection(t, target, legacy, "")
+	buildSection(t, target, team, "team")
+	buildSection(t, target, legacy, "")
+
+	content, _ := os.ReadFile(target)
+	result := string(content)
+	if strings.Count(result, "# PARTIALS>>>>>\n") != 1 || strings.Count(result, "Host legacy") != 1 {
+		t.Errorf("Unnamed section duplicated:\n%s", result)
+	}
+	if strings.Count(result, "Host team") != 1 {
+		t.Errorf("Named section damaged by unnamed rebuild:\n%s", result)
+	}
+}
+
+func TestSyncTarget_NamedSection(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "config")
+	if err := os.WriteFile(target, []byte(""), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	personal := writePartials(t, dir, "personal", "Host personal\n")
+	team := writePartials(t, dir, "team", "Host team\n")
+	buildSection(t, target, personal, "personal")
+	buildSection(t, target, team, "team")
+
+	content, _ := os.ReadFile(target)
+	modified := strings.Replace(string(content), "Host team", "Host team-edited", 1)
+	modified = strings.Replace(modified, "Host personal", "Host personal-edited", 1)
+	if err := os.WriteFile(target, []byte(modified), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	command := NewPartialsSyncCommand(target, team, "#", "merge")
+	if err := command.SetSectionID("team"); err != nil {
+		t.Fatalf("SetSectionID failed: %v", err)
+	}
+	result, err := command.Run()
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if result.UpdatedFiles != 1 {
+		t.Errorf("Expected 1 updated file, got %d", result.UpdatedFiles)
+	}
+
+	teamContent, _ := os.ReadFile(filepath.Join(team, "team"))
+	if string(teamContent) != "Host team-edited\n" {
+		t.Errorf("Team partial not synced: %q", teamContent)
+	}
+	personalContent, _ := os.ReadFile(filepath.Join(personal, "personal"))
+	if string(personalContent) != "Host personal\n" {
+		t.Errorf("Personal partial should not be touched by a team sync: %q", personalContent)
+	}
+}
+
+func TestParseSection(t *testing.T) {
+	style := CommentStyle{Start: "#"}
+	start, end := buildStartFlag(style, ""), buildEndFlag(style, "")
+	team := buildStartFlag(style, "team") + "\n" + buildEndFlag(style, "team")
+
+	tests := []struct {
+		name    string
+		content string
+		kind    string
+		line    int
+		body    string
+	}{
+		{"no markers", "a\n", "", 0, ""},
+		{"well-formed", "a\n" + start + "\nbody\n" + end + "\nb\n", "", 0, "body\n"},
+		{"empty", start + "\n" + end + "\n", "", 0, ""},
+		{"missing end", "a\n" + start + "\nbody\n", MarkerMissingEnd, 3, ""},
+		{"missing start", "a\nbody\n" + end + "\n", MarkerMissingStart, 4, ""},
+		{"reversed", end + "\nbody\n" + start + "\n", MarkerReversed, 2, ""},
+		{"duplicate", start + "\n" + end + "\n" + start + "\n" + end + "\n", MarkerDuplicate, 8, ""},
+		{"nested", start + "\n" + start + "\n" + end + "\n", MarkerNested, 5, ""},
+		{"extra end", start + "\n" + end + "\n" + end + "\n", MarkerExtraEnd, 8, ""},
+		{"other section inside", start + "\n" + team + "\n" + end + "\n", MarkerNested, 5, ""},
+		{"other section outside", team + "\n" + start + "\nbody\n" + end + "\n", "", 0, "body\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			section, err := parseSection(test.content, style, "")
+			if test.kind == "" {
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if section != nil && test.content[section.BodyStart:section.BodyEnd] != test.body {
+					t.Errorf("Expected body %q, got %q", test.body, test.content[section.BodyStart:section.BodyEnd])
+				}
+				return
+			}
+			var markerErr *MarkerError
+			if !errors.As(err, &markerErr) || !errors.Is(err, ErrMalformedSection) {
+				t.Fatalf("Expected a MarkerError, got %v", err)
+			}
+			if markerErr.Kind != test.kind || markerErr.Line != test.line {
+				t.Errorf("Expected %s at line %d, got %s at line %d", test.kind, test.line, markerErr.Kind, markerErr.Line)
+			}
+		})
+	}
+}
+
+func TestBuild_RefusesMalformedSection(t *testing.T) {
+	fsys := NewMemFS()
+	target, partialsDir := targetFixture(t, fsys, "/mem", map[string]string{"work": "Host work\n"})
+	style := CommentStyle{Start: "#"}
+	broken := buildEndFlag(style, "") + "\nHost old\n" + buildStartFlag(style, "") + "\n"
+	memTree(t, fsys, map[string]string{target: broken})
+
+	build := checksumBuild(t, fsys, target, partialsDir)
+	err := build.Run()
+	if !errors.Is(err, ErrMalformedSection) || !strings.Contains(err.Error(), "line 2:") {
+		t.Fatalf("Expected a line-numbered malformed section error, got %v", err)
+	}
+	if readMem(t, fsys, target) != broken {
+		t.Error("A malformed section must not be rewritten")
+	}
+
+	remove, _ := NewPartialsRemoveCommand(target, "#")
+	remove.SetFS(fsys)
+	if err := remove.Run(); !errors.Is(err, ErrMalformedSection) {
+		t.Errorf("Expected remove to refuse the malformed section, got %v", err)
+	}
+}
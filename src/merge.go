@@ -0,0 +1,192 @@
+package src
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Ways to settle a hunk both sides changed differently
+const (
+	ConflictRefuse  = "refuse"  // report the conflict and leave the file alone
+	ConflictMarkers = "markers" // write both versions between conflict markers
+	ConflictOurs    = "ours"    // keep our version of the hunk
+	ConflictTheirs  = "theirs"  // take their version of the hunk
+)
+
+// Conflict marker lines written by ConflictMarkers
+const (
+	ConflictStartMarker = "<<<<<<<"
+	ConflictSeparator   = "======="
+	ConflictEndMarker   = ">>>>>>>"
+)
+
+// MergeResult is the outcome of a three-way merge
+type MergeResult struct {
+	Content   string
+	Conflicts []MergeConflict
+}
+
+// MergeConflict is a hunk both sides changed differently
+type MergeConflict struct {
+	Line   int // first line of the hunk in the base, 1-based
+	Ours   string
+	Theirs string
+}
+
+// mergeHunk replaces base lines [start, end) with lines
+type mergeHunk struct {
+	start, end int
+	lines      []string
+}
+
+// ValidateConflictMode checks a conflict resolution name
+func ValidateConflictMode(mode string) error {
+	switch mode {
+	case "", ConflictRefuse, ConflictMarkers, ConflictOurs, ConflictTheirs:
+		return nil
+	}
+	return fmt.Errorf("invalid conflict mode '%s' (must be '%s', '%s', '%s' or '%s')",
+		mode, ConflictRefuse, ConflictMarkers, ConflictOurs, ConflictTheirs)
+}
+
+// Merge3 merges the changes ours and theirs each made to base, line by line.
+// Changes to separate lines combine; changes to the same or adjacent lines
+// conflict unless both sides made the same edit. Conflicts are settled by
+// mode, labelling the sides oursLabel and theirsLabel in conflict markers.
+// ConflictRefuse leaves our version in Content.
+func Merge3(base, ours, theirs, mode, oursLabel, theirsLabel string) MergeResult {
+	baseLines := splitLines(base)
+	oursHunks := diffHunks(baseLines, splitLines(ours))
+	theirsHunks := diffHunks(baseLines, splitLines(theirs))
+
+	var out strings.Builder
+	var conflicts []MergeConflict
+	pos := 0
+	i, j := 0, 0
+	for i < len(oursHunks) || j < len(theirsHunks) {
+		// Start a group with whichever hunk comes first, then absorb every
+		// hunk from either side that overlaps or touches the group
+		var groupOurs, groupTheirs []mergeHunk
+		var start, end int
+		if j == len(theirsHunks) || (i < len(oursHunks) && oursHunks[i].start <= theirsHunks[j].start) {
+			start, end = oursHunks[i].start, oursHunks[i].end
+			groupOurs = append(groupOurs, oursHunks[i])
+			i++
+		} else {
+			start, end = theirsHunks[j].start, theirsHunks[j].end
+			groupTheirs = append(groupTheirs, theirsHunks[j])
+			j++
+		}
+		for {
+			if i < len(oursHunks) && oursHunks[i].start <= end {
+				end = maxInt(end, oursHunks[i].end)
+				groupOurs = append(groupOurs, oursHunks[i])
+				i++
+				continue
+			}
+			if j < len(theirsHunks) && theirsHunks[j].start <= end {
+				end = maxInt(end, theirsHunks[j].end)
+				groupTheirs = append(groupTheirs, theirsHunks[j])
+				j++
+				continue
+			}
+			break
+		}
+
+		out.WriteString(strings.Join(baseLines[pos:start], ""))
+		pos = end
+
+		oursText := applyHunks(baseLines, start, end, groupOurs)
+		theirsText := applyHunks(baseLines, start, end, groupTheirs)
+		switch {
+		case len(groupTheirs) == 0 || oursText == theirsText:
+			out.WriteString(oursText)
+		case len(groupOurs) == 0:
+			out.WriteString(theirsText)
+		default:
+			conflicts = append(conflicts, MergeConflict{Line: start + 1, Ours: oursText, Theirs: theirsText})
+			switch mode {
+			case ConflictTheirs:
+				out.WriteString(theirsText)
+			case ConflictMarkers:
+				writeConflict(&out, oursText, theirsText, oursLabel, theirsLabel)
+			default:
+				out.WriteString(oursText)
+			}
+		}
+	}
+	out.WriteString(strings.Join(baseLines[pos:], ""))
+
+	content := out.String()
+	if mode == ConflictRefuse && len(conflicts) > 0 {
+		content = ours
+	}
+	return MergeResult{Content: content, Conflicts: conflicts}
+}
+
+// diffHunks returns the edits from a to b as base ranges with replacement lines
+func diffHunks(a, b []string) []mergeHunk {
+	var hunks []mergeHunk
+	var current *mergeHunk
+	pos := 0
+	for _, op := range diffLines(a, b) {
+		if op.kind == ' ' {
+			if current != nil {
+				hunks = append(hunks, *current)
+				current = nil
+			}
+			pos++
+			continue
+		}
+		if current == nil {
+			current = &mergeHunk{start: pos, end: pos}
+		}
+		if op.kind == '-' {
+			pos++
+			current.end = pos
+		} else {
+			current.lines = append(current.lines, op.line)
+		}
+	}
+	if current != nil {
+		hunks = append(hunks, *current)
+	}
+	return hunks
+}
+
+// applyHunks renders base lines [start, end) with one side's hunks applied
+func applyHunks(base []string, start, end int, hunks []mergeHunk) string {
+	var out strings.Builder
+	pos := start
+	for _, h := range hunks {
+		out.WriteString(strings.Join(base[pos:h.start], ""))
+		out.WriteString(strings.Join(h.lines, ""))
+		pos = h.end
+	}
+	out.WriteString(strings.Join(base[pos:end], ""))
+	return out.String()
+}
+
+// writeConflict writes both versions of a hunk between conflict markers
+func writeConflict(out *strings.Builder, ours, theirs, oursLabel, theirsLabel string) {
+	out.WriteString(ConflictStartMarker + " " + oursLabel + "\n")
+	writeConflictSide(out, ours)
+	out.WriteString(ConflictSeparator + "\n")
+	writeConflictSide(out, theirs)
+	out.WriteString(ConflictEndMarker + " " + theirsLabel + "\n")
+}
+
+// writeConflictSide writes one side, ending it with a newline so the next marker starts a line
+func writeConflictSide(out *strings.Builder, text string) {
+	out.WriteString(text)
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		out.WriteByte('\n')
+	}
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,76 @@
+package src
+
+import (
+	"strings"
+	"testing"
+)
+
+const mergeBase = "a\nb\nc\nd\ne\n"
+
+func TestMerge3_CombinesSeparateEdits(t *testing.T) {
+	result := Merge3(mergeBase, "A\nb\nc\nd\ne\n", "a\nb\nc\nd\nE\n", ConflictRefuse, "ours", "theirs")
+	if len(result.Conflicts) != 0 {
+		t.Fatalf("Expected no conflicts, got %v", result.Conflicts)
+	}
+	if result.Content != "A\nb\nc\nd\nE\n" {
+		t.Errorf("Unexpected merge: %q", result.Content)
+	}
+}
+
+func TestMerge3_SameEditIsNotAConflict(t *testing.T) {
+	edited := "a\nB\nc\nd\ne\n"
+	result := Merge3(mergeBase, edited, edited, ConflictRefuse, "ours", "theirs")
+	if len(result.Conflicts) != 0 || result.Content != edited {
+		t.Errorf("Expected clean merge to %q, got %q with %v", edited, result.Content, result.Conflicts)
+	}
+}
+
+func TestMerge3_Conflicts(t *testing.T) {
+	ours := "a\nb\nOURS\nd\ne\n"
+	theirs := "a\nb\nTHEIRS\nd\nE\n"
+
+	tests := []struct {
+		mode string
+		want string
+	}{
+		{ConflictRefuse, ours},
+		{ConflictOurs, "a\nb\nOURS\nd\nE\n"},
+		{ConflictTheirs, "a\nb\nTHEIRS\nd\nE\n"},
+		{ConflictMarkers, "a\nb\n<<<<<<< ours\nOURS\n=======\nTHEIRS\n>>>>>>> theirs\nd\nE\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			result := Merge3(mergeBase, ours, theirs, tt.mode, "ours", "theirs")
+			if len(result.Conflicts) != 1 {
+				t.Fatalf("Expected 1 conflict, got %v", result.Conflicts)
+			}
+			if c := result.Conflicts[0]; c.Line != 3 || c.Ours != "OURS\n" || c.Theirs != "THEIRS\n" {
+				t.Errorf("Unexpected conflict: %+v", c)
+			}
+			if result.Content != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, result.Content)
+			}
+		})
+	}
+}
+
+func TestMerge3_AdjacentEditsConflict(t *testing.T) {
+	result := Merge3(mergeBase, "a\nB\nc\nd\ne\n", "a\nb\nC\nd\ne\n", ConflictMarkers, "ours", "theirs")
+	if len(result.Conflicts) != 1 {
+		t.Fatalf("Expected adjacent edits to conflict, got %v", result.Conflicts)
+	}
+	if !strings.Contains(result.Content, "B\nc\n=======\nb\nC\n") {
+		t.Errorf("Expected both versions of the hunk, got %q", result.Content)
+	}
+}
+
+func TestValidateConflictMode(t *testing.T) {
+	for _, mode := range []string{"", ConflictRefuse, ConflictMarkers, ConflictOurs, ConflictTheirs} {
+		if err := ValidateConflictMode(mode); err != nil {
+			t.Errorf("Expected '%s' to be valid: %v", mode, err)
+		}
+	}
+	if err := ValidateConflictMode("mine"); err == nil {
+		t.Error("Expected an error for an unknown mode")
+	}
+}
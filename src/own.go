@@ -2,18 +2,28 @@ package src
 
 import (
 	"fmt"
+	"io"
 	"io/fs"
-	"os"
-	"path/filepath"
 	"strings"
 )
 
 // PartialsOwnCommand handles writing entire files from partials (no markers)
 type PartialsOwnCommand struct {
-	targetFile   string
-	partialsDir  string
-	commentChars string
-	dryRun       bool
+	targetFile     string
+	partialsDir    string
+	commentChars   string
+	partialOptions PartialOptions
+	templateData   *TemplateData
+	validator      *Validator
+	checksum       bool
+	escapeMarkers  bool
+	delimit        bool
+	sourcePaths    SourcePaths
+	force          bool
+	dryRun         bool
+	backups        *BackupStore
+	out            io.Writer
+	fsys           FS
 }
 
 // NewPartialsOwnCommand creates a new own command.
@@ -31,39 +41,133 @@ func (p *PartialsOwnCommand) SetDryRun(dryRun bool) {
 	p.dryRun = dryRun
 }
 
-// Run executes the own command
-func (p PartialsOwnCommand) Run() error {
+// SetPartialOptions controls recursion and include/exclude filtering of the partials directory
+func (p *PartialsOwnCommand) SetPartialOptions(opts PartialOptions) {
+	p.partialOptions = opts
+}
+
+// SetTemplateData renders every partial as a text/template with data before merging.
+// A nil data copies partials verbatim.
+func (p *PartialsOwnCommand) SetTemplateData(data *TemplateData) {
+	p.templateData = data
+}
+
+// SetValidator checks the rendered file before it is written; nil disables validation
+func (p *PartialsOwnCommand) SetValidator(validator *Validator) {
+	p.validator = validator
+}
+
+// Validate checks a planned change with the command's validator
+func (p PartialsOwnCommand) Validate(change *FileChange) error {
+	return ValidateChange(change, p.validator)
+}
+
+// SetChecksum writes a checksum line (content hash, parts version and apply
+// time) as the first line of the file, so later hand edits are detected.
+// It needs a comment style.
+func (p *PartialsOwnCommand) SetChecksum(checksum bool) {
+	p.checksum = checksum
+}
+
+// SetEscapeMarkers escapes partial lines that would be read as marker syntax
+// instead of refusing them; sync reverses the escaping
+func (p *PartialsOwnCommand) SetEscapeMarkers(escape bool) {
+	p.escapeMarkers = escape
+}
+
+// SetDelimitPartials wraps each partial in begin and end lines naming its
+// path relative to the partials directory, so sync restores it byte for byte.
+// It needs a comment style.
+func (p *PartialsOwnCommand) SetDelimitPartials(delimit bool) {
+	p.delimit = delimit
+}
+
+// SetSourcePaths controls how partial paths are written in Source comments and delimiters
+func (p *PartialsOwnCommand) SetSourcePaths(paths SourcePaths) {
+	p.sourcePaths = paths
+}
+
+// SetForce overwrites the file even if it was edited by hand
+func (p *PartialsOwnCommand) SetForce(force bool) {
+	p.force = force
+}
+
+// CheckEdits returns ErrHandEdited if the change would overwrite a file that
+// was edited since its checksum was written, unless forced
+func (p PartialsOwnCommand) CheckEdits(change *FileChange) error {
+	if p.force || !change.Existed {
+		return nil
+	}
+	return checkEdits(p.targetFile, string(change.Original), string(change.Content))
+}
+
+// SetBackupStore enables backups of the target file before it is overwritten
+func (p *PartialsOwnCommand) SetBackupStore(store *BackupStore) {
+	p.backups = store
+}
+
+// SetOutput sends progress messages and dry-run previews to w instead of stdout
+func (p *PartialsOwnCommand) SetOutput(w io.Writer) {
+	p.out = w
+}
+
+// SetFS reads and writes files through fsys instead of the real filesystem.
+// Backups are still stored on disk.
+func (p *PartialsOwnCommand) SetFS(fsys FS) {
+	p.fsys = fsys
+}
+
+// Plan renders the target file from the partials without writing anything
+func (p PartialsOwnCommand) Plan() (*FileChange, error) {
+	fsys := fileSystem(p.fsys)
 	// Get original file permissions if file exists
 	var originalMode fs.FileMode = 0644
-	if info, err := os.Stat(p.targetFile); err == nil {
+	var original []byte
+	existed := false
+	if info, err := fsys.Stat(p.targetFile); err == nil {
 		originalMode = info.Mode()
+		content, readErr := fsys.ReadFile(p.targetFile)
+		if readErr != nil {
+			return nil, fmt.Errorf("failed to read target file '%s': %w", p.targetFile, readErr)
+		}
+		original = content
+		existed = true
 	}
 
-	files, err := os.ReadDir(p.partialsDir)
+	files, err := listPartials(fsys, p.partialsDir, p.partialOptions)
 	if err != nil {
-		return fmt.Errorf("failed to read partials directory '%s': %w", p.partialsDir, err)
+		return nil, err
+	}
+	files, skipped, err := filterPartials(fsys, files, CurrentFacts())
+	if err != nil {
+		return nil, err
 	}
 
 	var output strings.Builder
+	var partials []string
 
 	for _, file := range files {
-		if file.IsDir() {
-			continue
-		}
-
-		partialPath := filepath.Join(p.partialsDir, file.Name())
-		content, readErr := os.ReadFile(partialPath)
+		partialPath := file.Path
+		content, readErr := readPartial(fsys, partialPath, p.templateData)
 		if readErr != nil {
-			return fmt.Errorf("failed to read partial file '%s': %w", partialPath, readErr)
+			return nil, readErr
 		}
+		partials = append(partials, partialPath)
 
 		// Add source comment if comment style is provided
 		if p.commentChars != "" {
 			style := ResolveCommentStyle(p.commentChars, p.targetFile)
-			if style.End != "" {
-				output.WriteString(fmt.Sprintf("%s Source: %s %s\n", style.Start, partialPath, style.End))
-			} else {
-				output.WriteString(fmt.Sprintf("%s Source: %s\n", style.Start, partialPath))
+			content, err = escapePartial(style, partialPath, content, p.escapeMarkers)
+			if err != nil {
+				return nil, err
+			}
+			sourcePath := p.sourcePaths.format(p.partialsDir, partialPath, p.delimit)
+			if p.delimit {
+				writeDelimited(&output, style, sourcePath, content)
+				continue
+			}
+			if !p.sourcePaths.hidden() {
+				output.WriteString(commentLine(style, "Source: "+sourcePath))
 			}
 		}
 
@@ -74,34 +178,60 @@ func (p PartialsOwnCommand) Run() error {
 		}
 	}
 
+	content := output.String()
+	if p.checksum && p.commentChars != "" {
+		previous, _ := splitChecksum(string(original))
+		content = stampChecksum(ResolveCommentStyle(p.commentChars, p.targetFile), content, previous)
+	}
+
+	return &FileChange{
+		Path:         p.targetFile,
+		Content:      []byte(content),
+		Mode:         originalMode,
+		Original:     original,
+		OriginalMode: originalMode,
+		Existed:      existed,
+		Partials:     partials,
+		Skipped:      skipped,
+	}, nil
+}
+
+// Run executes the own command
+func (p PartialsOwnCommand) Run() error {
+	out := messageOutput(p.out)
+	change, err := p.Plan()
+	if err != nil {
+		return err
+	}
+	if err := p.Validate(change); err != nil {
+		return err
+	}
+	editErr := p.CheckEdits(change)
+
 	if p.dryRun {
-		fmt.Printf("DRY RUN: Would write to '%s' (own mode)\n", p.targetFile)
-		fmt.Printf("Content preview:\n")
-		fmt.Printf("--- BEGIN FILE CONTENT ---\n")
-		fmt.Print(output.String())
-		fmt.Printf("--- END FILE CONTENT ---\n")
-		fmt.Printf("Total length: %d characters\n", output.Len())
+		if editErr != nil {
+			fmt.Fprintf(out, "Warning: %v\n", editErr)
+		}
+		printSkippedPartials(out, change.Skipped)
+		fmt.Fprintf(out, "DRY RUN: Would write to '%s' (own mode)\n", p.targetFile)
+		fmt.Fprintf(out, "Content preview:\n")
+		fmt.Fprintf(out, "--- BEGIN FILE CONTENT ---\n")
+		fmt.Fprint(out, string(change.Content))
+		fmt.Fprintf(out, "--- END FILE CONTENT ---\n")
+		fmt.Fprintf(out, "Total length: %d characters\n", len(change.Content))
 		return nil
 	}
 
-	// Create target directory if it doesn't exist
-	targetDir := filepath.Dir(p.targetFile)
-	if err := os.MkdirAll(targetDir, 0755); err != nil {
-		return fmt.Errorf("failed to create target directory '%s': %w", targetDir, err)
+	if editErr != nil {
+		return editErr
 	}
 
-	if err := os.WriteFile(p.targetFile, []byte(output.String()), originalMode); err != nil {
-		return fmt.Errorf("failed to write target file '%s': %w", p.targetFile, err)
+	// The target directory is created if it doesn't exist
+	if err := writeChange(fileSystem(p.fsys), change, p.backups); err != nil {
+		return err
 	}
 
-	// Count partial files for success message
-	partialCount := 0
-	for _, f := range files {
-		if !f.IsDir() {
-			partialCount++
-		}
-	}
-	fmt.Printf("Wrote %d partial(s) to '%s' (own mode)\n", partialCount, p.targetFile)
+	fmt.Fprintf(out, "Wrote %d partial(s) to '%s' (own mode)\n", len(change.Partials), p.targetFile)
 
 	return nil
 }
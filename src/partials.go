@@ -0,0 +1,351 @@
+package src
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Sort orders for partials
+const (
+	SortLexical = "lexical"
+	SortNatural = "natural"
+)
+
+// PartialOptions controls which files of a partials directory are merged, and in what order
+type PartialOptions struct {
+	Recursive bool
+	Include   []string
+	Exclude   []string
+	Order     []string       // relative paths merged first, in this order
+	Sort      string         // "lexical" (default) or "natural" for the remaining partials
+	Priority  map[string]int // relative path -> priority; lower merges earlier, default 0
+}
+
+// Partial is a file selected from a partials directory
+type Partial struct {
+	Path    string // partials directory joined with RelPath
+	RelPath string // slash-separated path relative to the partials directory
+}
+
+// Validate checks that every include/exclude glob can be compiled and the sort order is known
+func (o PartialOptions) Validate() error {
+	for _, pattern := range append(append([]string{}, o.Include...), o.Exclude...) {
+		if _, err := compileGlob(pattern); err != nil {
+			return err
+		}
+	}
+	switch o.Sort {
+	case "", SortLexical, SortNatural:
+	default:
+		return fmt.Errorf("invalid sort '%s' (must be 'lexical' or 'natural')", o.Sort)
+	}
+	return nil
+}
+
+// ListPartials returns the partial files of dir in merge order.
+// Without Recursive only the top level is read and subdirectories are skipped.
+// Include patterns (if any) select files; exclude patterns drop files and,
+// when recursive, whole directories. See sortPartials for the ordering.
+func ListPartials(dir string, opts PartialOptions) ([]Partial, error) {
+	return listPartials(OSFS{}, dir, opts)
+}
+
+// listPartials is ListPartials on fsys
+func listPartials(fsys FS, dir string, opts PartialOptions) ([]Partial, error) {
+	partials, err := collectPartials(fsys, dir, opts)
+	if err != nil {
+		return nil, err
+	}
+	sortPartials(partials, opts)
+	return partials, nil
+}
+
+// collectPartials returns the selected files of dir in directory-walk order
+func collectPartials(fsys FS, dir string, opts PartialOptions) ([]Partial, error) {
+	filter, err := newPartialFilter(opts)
+	if err != nil {
+		return nil, err
+	}
+	selected, exclude := filter.selects, filter.exclude
+
+	var partials []Partial
+
+	if !opts.Recursive {
+		entries, readErr := fsys.ReadDir(dir)
+		if readErr != nil {
+			return nil, fmt.Errorf("failed to read partials directory '%s': %w", dir, readErr)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !selected(entry.Name()) {
+				continue
+			}
+			partials = append(partials, Partial{Path: filepath.Join(dir, entry.Name()), RelPath: entry.Name()})
+		}
+		return partials, nil
+	}
+
+	// Surface a missing directory with the same message as the flat listing
+	if _, statErr := fsys.Stat(dir); statErr != nil {
+		return nil, fmt.Errorf("failed to read partials directory '%s': %w", dir, statErr)
+	}
+
+	// Walk depth-first in lexical order, like filepath.WalkDir
+	var walk func(sub string) error
+	walk = func(sub string) error {
+		p := filepath.Join(dir, filepath.FromSlash(sub))
+		entries, readErr := fsys.ReadDir(p)
+		if readErr != nil {
+			return fmt.Errorf("failed to read partials directory '%s': %w", p, readErr)
+		}
+		for _, entry := range entries {
+			rel := path.Join(sub, entry.Name())
+			if entry.IsDir() {
+				if matchAny(exclude, rel) {
+					continue
+				}
+				if err := walk(rel); err != nil {
+					return err
+				}
+				continue
+			}
+			if selected(rel) {
+				partials = append(partials, Partial{Path: filepath.Join(dir, filepath.FromSlash(rel)), RelPath: rel})
+			}
+		}
+		return nil
+	}
+	if walkErr := walk(""); walkErr != nil {
+		return nil, walkErr
+	}
+	return partials, nil
+}
+
+// Selects reports whether a file at rel (slash-separated, relative to the
+// partials directory) would be read as a partial
+func (o PartialOptions) Selects(rel string) (bool, error) {
+	filter, err := newPartialFilter(o)
+	if err != nil {
+		return false, err
+	}
+	if !o.Recursive && strings.Contains(rel, "/") {
+		return false, nil
+	}
+	// The walk skips excluded directories entirely
+	for dir := path.Dir(rel); dir != "."; dir = path.Dir(dir) {
+		if matchAny(filter.exclude, dir) {
+			return false, nil
+		}
+	}
+	return filter.selects(rel), nil
+}
+
+// partialFilter holds the compiled include and exclude globs of PartialOptions
+type partialFilter struct {
+	include, exclude []compiledGlob
+}
+
+func newPartialFilter(opts PartialOptions) (partialFilter, error) {
+	include, err := compileGlobs(opts.Include)
+	if err != nil {
+		return partialFilter{}, err
+	}
+	exclude, err := compileGlobs(opts.Exclude)
+	if err != nil {
+		return partialFilter{}, err
+	}
+	return partialFilter{include: include, exclude: exclude}, nil
+}
+
+// selects reports whether a file is a partial, not counting excluded parent directories
+func (f partialFilter) selects(rel string) bool {
+	// Condition sidecars belong to the partial they are named after, and
+	// pruned partials are kept only to be renamed back
+	if strings.HasSuffix(rel, WhenSuffix) || strings.HasSuffix(rel, DisabledSuffix) {
+		return false
+	}
+	if len(f.include) > 0 && !matchAny(f.include, rel) {
+		return false
+	}
+	return !matchAny(f.exclude, rel)
+}
+
+// sortPartials orders partials: those named in Order come first, in that
+// order; the rest follow by ascending Priority, then by path. Paths compare
+// directory by directory (so a tree is merged depth-first), each segment
+// lexically or, with natural sort, with digit runs compared as numbers so
+// that "2-base" precedes "10-hosts".
+func sortPartials(partials []Partial, opts PartialOptions) {
+	explicit := make(map[string]int, len(opts.Order))
+	for i, rel := range opts.Order {
+		if _, seen := explicit[rel]; !seen {
+			explicit[rel] = i
+		}
+	}
+	natural := opts.Sort == SortNatural
+
+	sort.SliceStable(partials, func(i, j int) bool {
+		a, b := partials[i].RelPath, partials[j].RelPath
+		posA, orderedA := explicit[a]
+		posB, orderedB := explicit[b]
+		if orderedA || orderedB {
+			if orderedA && orderedB {
+				return posA < posB
+			}
+			return orderedA
+		}
+		if prioA, prioB := opts.Priority[a], opts.Priority[b]; prioA != prioB {
+			return prioA < prioB
+		}
+		return comparePaths(a, b, natural) < 0
+	})
+}
+
+// comparePaths compares slash-separated paths segment by segment
+func comparePaths(a, b string, natural bool) int {
+	segsA, segsB := strings.Split(a, "/"), strings.Split(b, "/")
+	for i := 0; i < len(segsA) && i < len(segsB); i++ {
+		var c int
+		if natural {
+			c = compareNatural(segsA[i], segsB[i])
+		} else {
+			c = strings.Compare(segsA[i], segsB[i])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return len(segsA) - len(segsB)
+}
+
+// compareNatural compares strings treating runs of digits as numbers.
+// Ties (e.g. "01" vs "1") fall back to plain string comparison.
+func compareNatural(a, b string) int {
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if isDigit(a[i]) && isDigit(b[j]) {
+			startA, startB := i, j
+			for i < len(a) && isDigit(a[i]) {
+				i++
+			}
+			for j < len(b) && isDigit(b[j]) {
+				j++
+			}
+			numA := strings.TrimLeft(a[startA:i], "0")
+			numB := strings.TrimLeft(b[startB:j], "0")
+			if len(numA) != len(numB) {
+				return len(numA) - len(numB)
+			}
+			if c := strings.Compare(numA, numB); c != 0 {
+				return c
+			}
+			continue
+		}
+		if a[i] != b[j] {
+			return int(a[i]) - int(b[j])
+		}
+		i++
+		j++
+	}
+	if c := (len(a) - i) - (len(b) - j); c != 0 {
+		return c
+	}
+	return strings.Compare(a, b)
+}
+
+// isDigit reports whether c is an ASCII digit
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// compiledGlob is a glob translated to a regular expression. Patterns
+// without a slash match the base name, like .gitignore entries.
+type compiledGlob struct {
+	re       *regexp.Regexp
+	baseName bool
+}
+
+// compileGlobs compiles every pattern
+func compileGlobs(patterns []string) ([]compiledGlob, error) {
+	globs := make([]compiledGlob, 0, len(patterns))
+	for _, pattern := range patterns {
+		glob, err := compileGlob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		globs = append(globs, glob)
+	}
+	return globs, nil
+}
+
+// compileGlob translates a glob into a regular expression. Supported syntax:
+// '*' (within a path segment), '?', '[...]' classes and '**' (any number of segments).
+func compileGlob(pattern string) (compiledGlob, error) {
+	if pattern == "" {
+		return compiledGlob{}, fmt.Errorf("empty glob pattern")
+	}
+	clean := strings.TrimPrefix(pattern, "/")
+
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(clean); i++ {
+		c := clean[i]
+		switch c {
+		case '*':
+			if i+1 < len(clean) && clean[i+1] == '*' {
+				i++
+				if i+1 < len(clean) && clean[i+1] == '/' {
+					i++
+					b.WriteString("(?:.*/)?")
+				} else {
+					b.WriteString(".*")
+				}
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(clean[i:], ']')
+			if end == -1 {
+				return compiledGlob{}, fmt.Errorf("invalid glob pattern '%s': unterminated '['", pattern)
+			}
+			class := clean[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return compiledGlob{}, fmt.Errorf("invalid glob pattern '%s': %w", pattern, err)
+	}
+	return compiledGlob{re: re, baseName: !strings.Contains(clean, "/")}, nil
+}
+
+// match reports whether the slash-separated relative path matches the glob
+func (g compiledGlob) match(rel string) bool {
+	if g.baseName {
+		return g.re.MatchString(path.Base(rel))
+	}
+	return g.re.MatchString(rel)
+}
+
+// matchAny reports whether rel matches any of the globs
+func matchAny(globs []compiledGlob, rel string) bool {
+	for _, g := range globs {
+		if g.match(rel) {
+			return true
+		}
+	}
+	return false
+}
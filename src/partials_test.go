@@ -0,0 +1,297 @@
+package src
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeTree creates files (slash-separated relative paths) under dir
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	for rel, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed: %v", err)
+		}
+	}
+}
+
+// relPaths returns the RelPath of each partial
+func relPaths(partials []Partial) []string {
+	paths := make([]string, 0, len(partials))
+	for _, p := range partials {
+		paths = append(paths, p.RelPath)
+	}
+	return paths
+}
+
+func TestListPartials(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"base.conf":              "",
+		"notes.txt":              "",
+		"ssh/work/app.conf":      "",
+		"ssh/work/db.conf":       "",
+		"ssh/personal/home.conf": "",
+		"archive/old.conf":       "",
+	})
+
+	tests := []struct {
+		name     string
+		opts     PartialOptions
+		expected []string
+	}{
+		{"flat", PartialOptions{}, []string{"base.conf", "notes.txt"}},
+		{"recursive", PartialOptions{Recursive: true},
+			[]string{"archive/old.conf", "base.conf", "notes.txt", "ssh/personal/home.conf", "ssh/work/app.conf", "ssh/work/db.conf"}},
+		{"include basename", PartialOptions{Recursive: true, Include: []string{"*.conf"}, Exclude: []string{"archive"}},
+			[]string{"base.conf", "ssh/personal/home.conf", "ssh/work/app.conf", "ssh/work/db.conf"}},
+		{"include doublestar", PartialOptions{Recursive: true, Include: []string{"ssh/**"}},
+			[]string{"ssh/personal/home.conf", "ssh/work/app.conf", "ssh/work/db.conf"}},
+		{"exclude path", PartialOptions{Recursive: true, Exclude: []string{"ssh/work/db.*", "**/*.txt"}},
+			[]string{"archive/old.conf", "base.conf", "ssh/personal/home.conf", "ssh/work/app.conf"}},
+		{"class", PartialOptions{Include: []string{"[!n]*"}}, []string{"base.conf"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			partials, err := ListPartials(dir, tt.opts)
+			if err != nil {
+				t.Fatalf("ListPartials failed: %v", err)
+			}
+			if got := relPaths(partials); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestListPartials_Errors(t *testing.T) {
+	if _, err := ListPartials(filepath.Join(t.TempDir(), "missing"), PartialOptions{Recursive: true}); err == nil ||
+		!strings.Contains(err.Error(), "failed to read partials directory") {
+		t.Errorf("Expected missing directory error, got %v", err)
+	}
+	if err := (PartialOptions{Include: []string{"[abc"}}).Validate(); err == nil {
+		t.Error("Expected unterminated class to be rejected")
+	}
+}
+
+func TestPartialsBuildCommand_RecursiveSync(t *testing.T) {
+	dir := t.TempDir()
+	partialsDir := filepath.Join(dir, "ssh")
+	writeTree(t, partialsDir, map[string]string{
+		"work/app.conf":      "Host app\n",
+		"personal/home.conf": "Host home\n",
+		"README.md":          "not a partial\n",
+	})
+	target := filepath.Join(dir, "config")
+	if err := os.WriteFile(target, []byte(""), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	opts := PartialOptions{Recursive: true, Include: []string{"*.conf"}}
+	command, _ := NewPartialsBuildCommand(target, partialsDir, "#")
+	command.SetPartialOptions(opts)
+	if err := command.Run(); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	content, _ := os.ReadFile(target)
+	result := string(content)
+	if strings.Index(result, "Host home") > strings.Index(result, "Host app") {
+		t.Errorf("Expected personal/ before work/:\n%s", result)
+	}
+	if strings.Contains(result, "not a partial") {
+		t.Errorf("README.md should be excluded by include pattern:\n%s", result)
+	}
+
+	modified := strings.Replace(result, "Host app", "Host app-edited", 1)
+	if err := os.WriteFile(target, []byte(modified), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	syncCmd := NewPartialsSyncCommand(target, partialsDir, "#", "merge")
+	syncCmd.SetPartialOptions(opts)
+	syncResult, err := syncCmd.Run()
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if syncResult.UpdatedFiles != 1 {
+		t.Errorf("Expected 1 updated file, got %d", syncResult.UpdatedFiles)
+	}
+	nested, _ := os.ReadFile(filepath.Join(partialsDir, "work", "app.conf"))
+	if string(nested) != "Host app-edited\n" {
+		t.Errorf("Nested partial not synced: %q", nested)
+	}
+}
+
+func TestSyncTarget_SkipsSiblingDirectoryWithSharedPrefix(t *testing.T) {
+	dir := t.TempDir()
+	partialsDir := filepath.Join(dir, "ssh")
+	siblingDir := filepath.Join(dir, "ssh-other")
+	writeTree(t, partialsDir, map[string]string{"work": "Host work\n"})
+	writeTree(t, siblingDir, map[string]string{"evil": "Host evil\n"})
+
+	target := filepath.Join(dir, "config")
+	content := "# Source: " + filepath.Join(siblingDir, "evil") + "\nHost hijacked\n"
+	if err := os.WriteFile(target, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	result, err := SyncTarget(target, partialsDir, "#", "own", false)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if result.SkippedFiles != 1 || result.UpdatedFiles != 0 {
+		t.Errorf("Expected the sibling path to be skipped, got %+v", result)
+	}
+	evil, _ := os.ReadFile(filepath.Join(siblingDir, "evil"))
+	if string(evil) != "Host evil\n" {
+		t.Errorf("File outside the partials directory was modified: %q", evil)
+	}
+}
+
+func TestListPartials_Ordering(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"2-base":          "",
+		"10-hosts":        "",
+		"1-intro":         "",
+		"zz-local":        "",
+		"nested/10-b":     "",
+		"nested/9-a":      "",
+		"nested-file.txt": "",
+	})
+
+	tests := []struct {
+		name     string
+		opts     PartialOptions
+		expected []string
+	}{
+		{"lexical", PartialOptions{}, []string{"1-intro", "10-hosts", "2-base", "nested-file.txt", "zz-local"}},
+		{"natural", PartialOptions{Sort: SortNatural}, []string{"1-intro", "2-base", "10-hosts", "nested-file.txt", "zz-local"}},
+		{"natural recursive", PartialOptions{Recursive: true, Sort: SortNatural},
+			[]string{"1-intro", "2-base", "10-hosts", "nested/9-a", "nested/10-b", "nested-file.txt", "zz-local"}},
+		{"order first", PartialOptions{Order: []string{"zz-local", "2-base", "missing"}, Sort: SortNatural},
+			[]string{"zz-local", "2-base", "1-intro", "10-hosts", "nested-file.txt"}},
+		{"priority", PartialOptions{Sort: SortNatural, Priority: map[string]int{"1-intro": 10, "zz-local": -1}},
+			[]string{"zz-local", "2-base", "10-hosts", "nested-file.txt", "1-intro"}},
+		{"order beats priority", PartialOptions{Order: []string{"1-intro"}, Priority: map[string]int{"1-intro": 10, "2-base": -5}},
+			[]string{"1-intro", "2-base", "10-hosts", "nested-file.txt", "zz-local"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			partials, err := ListPartials(dir, tt.opts)
+			if err != nil {
+				t.Fatalf("ListPartials failed: %v", err)
+			}
+			if got := relPaths(partials); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+
+	if err := (PartialOptions{Sort: "random"}).Validate(); err == nil {
+		t.Error("Expected unknown sort order to be rejected")
+	}
+}
+
+func TestCompareNatural(t *testing.T) {
+	tests := []struct {
+		a, b string
+		less bool
+	}{
+		{"2-base", "10-hosts", true},
+		{"file9", "file10", true},
+		{"a", "b", true},
+		{"001", "1", true}, // equal numbers tie-break lexically
+		{"v1.10", "v1.9", false},
+		{"abc", "abc1", true},
+	}
+	for _, tt := range tests {
+		if got := compareNatural(tt.a, tt.b) < 0; got != tt.less {
+			t.Errorf("compareNatural(%q, %q) < 0 = %v, want %v", tt.a, tt.b, got, tt.less)
+		}
+	}
+}
+
+func TestPartialsBuildCommand_OrderedSync(t *testing.T) {
+	dir := t.TempDir()
+	partialsDir := filepath.Join(dir, "hosts")
+	writeTree(t, partialsDir, map[string]string{
+		"2-base":   "line-base\n",
+		"10-hosts": "line-hosts\n",
+		"local":    "line-local\n",
+	})
+	target := filepath.Join(dir, "config")
+	if err := os.WriteFile(target, []byte(""), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	opts := PartialOptions{Sort: SortNatural, Order: []string{"local"}}
+	command, _ := NewPartialsBuildCommand(target, partialsDir, "#")
+	command.SetPartialOptions(opts)
+	if err := command.Run(); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	content, _ := os.ReadFile(target)
+	result := string(content)
+	if !(strings.Index(result, "line-local") < strings.Index(result, "line-base") && strings.Index(result, "line-base") < strings.Index(result, "line-hosts")) {
+		t.Errorf("Expected local, base, hosts order:\n%s", result)
+	}
+
+	modified := strings.Replace(result, "line-hosts\n", "line-hosts-edited\n", 1)
+	if err := os.WriteFile(target, []byte(modified), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	syncCmd := NewPartialsSyncCommand(target, partialsDir, "#", "merge")
+	syncCmd.SetPartialOptions(opts)
+	if _, err := syncCmd.Run(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	synced, _ := os.ReadFile(filepath.Join(partialsDir, "10-hosts"))
+	if string(synced) != "line-hosts-edited\n" {
+		t.Errorf("Expected 10-hosts to be synced, got %q", synced)
+	}
+
+	// Rebuilding after sync is a no-op
+	if err := command.Run(); err != nil {
+		t.Fatalf("Rebuild failed: %v", err)
+	}
+	rebuilt, _ := os.ReadFile(target)
+	if string(rebuilt) != modified {
+		t.Errorf("Expected rebuild to be stable:\n%s", rebuilt)
+	}
+}
+
+func TestPartialOptions_Selects(t *testing.T) {
+	tests := []struct {
+		opts PartialOptions
+		rel  string
+		want bool
+	}{
+		{PartialOptions{}, "work", true},
+		{PartialOptions{}, "work.when", false},
+		{PartialOptions{}, "nested/work", false},
+		{PartialOptions{Recursive: true}, "nested/work", true},
+		{PartialOptions{Recursive: true, Exclude: []string{"drafts"}}, "drafts/work", false},
+		{PartialOptions{Include: []string{"*.conf"}}, "work", false},
+		{PartialOptions{Include: []string{"*.conf"}}, "work.conf", true},
+	}
+	for _, tt := range tests {
+		got, err := tt.opts.Selects(tt.rel)
+		if err != nil {
+			t.Fatalf("Selects(%q) failed: %v", tt.rel, err)
+		}
+		if got != tt.want {
+			t.Errorf("Selects(%q) with %+v = %v, want %v", tt.rel, tt.opts, got, tt.want)
+		}
+	}
+}
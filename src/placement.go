@@ -0,0 +1,145 @@
+package src
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Placement kinds for a newly inserted PARTIALS section
+const (
+	PlaceBottom = "bottom"
+	PlaceTop    = "top"
+	PlaceBefore = "before"
+	PlaceAfter  = "after"
+)
+
+// Placement controls where a PARTIALS section is inserted the first time it
+// is written. An existing section is always rewritten where it already is.
+// The zero value places the section at the bottom of the file.
+type Placement struct {
+	Kind    string
+	Pattern string
+	re      *regexp.Regexp
+}
+
+// ParsePlacement parses "top", "bottom", "before:<regex>" or "after:<regex>".
+// The empty string leaves the placement unset, which also means bottom but
+// lets a manifest default apply.
+func ParsePlacement(s string) (Placement, error) {
+	s = strings.TrimSpace(s)
+	switch s {
+	case "":
+		return Placement{}, nil
+	case PlaceBottom, PlaceTop:
+		return Placement{Kind: s}, nil
+	}
+
+	for _, kind := range []string{PlaceBefore, PlaceAfter} {
+		if strings.HasPrefix(s, kind+":") {
+			return newAnchoredPlacement(kind, strings.TrimSpace(strings.TrimPrefix(s, kind+":")))
+		}
+	}
+
+	return Placement{}, fmt.Errorf("invalid placement '%s' (must be 'top', 'bottom', 'before: <regex>' or 'after: <regex>')", s)
+}
+
+// newAnchoredPlacement compiles the anchor pattern of a before/after placement
+func newAnchoredPlacement(kind, pattern string) (Placement, error) {
+	if pattern == "" {
+		return Placement{}, fmt.Errorf("placement '%s' requires a pattern", kind)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return Placement{}, fmt.Errorf("invalid placement pattern '%s': %w", pattern, err)
+	}
+	return Placement{Kind: kind, Pattern: pattern, re: re}, nil
+}
+
+// UnmarshalYAML accepts either a scalar ("top", "before: ^Host \*") or a
+// single-key mapping ({before: "^Host \*"})
+func (p *Placement) UnmarshalYAML(value *yaml.Node) error {
+	switch value.Kind {
+	case yaml.ScalarNode:
+		parsed, err := ParsePlacement(value.Value)
+		if err != nil {
+			return err
+		}
+		*p = parsed
+		return nil
+
+	case yaml.MappingNode:
+		if len(value.Content) != 2 {
+			return fmt.Errorf("line %d: placement mapping must have exactly one key ('before' or 'after')", value.Line)
+		}
+		kind, pattern := value.Content[0].Value, value.Content[1].Value
+		if kind != PlaceBefore && kind != PlaceAfter {
+			return fmt.Errorf("line %d: invalid placement key '%s' (must be 'before' or 'after')", value.Line, kind)
+		}
+		parsed, err := newAnchoredPlacement(kind, pattern)
+		if err != nil {
+			return err
+		}
+		*p = parsed
+		return nil
+	}
+
+	return fmt.Errorf("line %d: placement must be a string or a mapping", value.Line)
+}
+
+// IsZero reports whether the placement is unset (default bottom placement).
+// An explicit "bottom" is set, so it overrides a manifest default.
+func (p Placement) IsZero() bool {
+	return p.Kind == ""
+}
+
+// String returns the placement in the form accepted by ParsePlacement
+func (p Placement) String() string {
+	switch p.Kind {
+	case PlaceTop:
+		return PlaceTop
+	case PlaceBefore, PlaceAfter:
+		return p.Kind + ": " + p.Pattern
+	}
+	return PlaceBottom
+}
+
+// insert places section (which ends with a newline) into content. Anchored
+// placements fall back to the bottom when no line matches the pattern.
+func (p Placement) insert(content, section string) string {
+	switch p.Kind {
+	case PlaceTop:
+		return section + content
+
+	case PlaceBefore, PlaceAfter:
+		offset := 0
+		for offset < len(content) {
+			lineEnd := strings.IndexByte(content[offset:], '\n')
+			next := len(content)
+			if lineEnd != -1 {
+				next = offset + lineEnd + 1
+			}
+			line := strings.TrimSuffix(content[offset:next], "\n")
+
+			if p.re.MatchString(line) {
+				if p.Kind == PlaceBefore {
+					return content[:offset] + section + content[offset:]
+				}
+				before := content[:next]
+				if !strings.HasSuffix(before, "\n") {
+					before += "\n"
+				}
+				return before + section + content[next:]
+			}
+			offset = next
+		}
+	}
+
+	// Bottom: add separator newline if content doesn't end with one
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	return content + section
+}
@@ -0,0 +1,189 @@
+package src
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParsePlacement(t *testing.T) {
+	tests := []struct {
+		input   string
+		kind    string
+		pattern string
+	}{
+		{"", "", ""},
+		{"bottom", PlaceBottom, ""},
+		{"top", PlaceTop, ""},
+		{"before:^Host \\*", PlaceBefore, "^Host \\*"},
+		{"after: ^# managed below", PlaceAfter, "^# managed below"},
+	}
+	for _, tt := range tests {
+		placement, err := ParsePlacement(tt.input)
+		if err != nil {
+			t.Errorf("ParsePlacement(%q) failed: %v", tt.input, err)
+			continue
+		}
+		if placement.Kind != tt.kind || placement.Pattern != tt.pattern {
+			t.Errorf("ParsePlacement(%q) = %+v, expected kind %q pattern %q", tt.input, placement, tt.kind, tt.pattern)
+		}
+	}
+
+	for _, invalid := range []string{"middle", "before:", "after:([", "before"} {
+		if _, err := ParsePlacement(invalid); err == nil {
+			t.Errorf("Expected ParsePlacement(%q) to fail", invalid)
+		}
+	}
+}
+
+func TestPlacement_Insert(t *testing.T) {
+	content := "Host a\nHost *\n    User me\n"
+	section := "SECTION\n"
+
+	tests := []struct {
+		placement string
+		expected  string
+	}{
+		{"bottom", content + section},
+		{"top", section + content},
+		{"before:^Host \\*", "Host a\n" + section + "Host *\n    User me\n"},
+		{"after:^Host a$", "Host a\n" + section + "Host *\n    User me\n"},
+		{"before:^NoMatch", content + section},
+	}
+	for _, tt := range tests {
+		placement, err := ParsePlacement(tt.placement)
+		if err != nil {
+			t.Fatalf("ParsePlacement(%q) failed: %v", tt.placement, err)
+		}
+		if got := placement.insert(content, section); got != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.placement, tt.expected, got)
+		}
+	}
+
+	// An anchor on a last line without newline still yields well-formed output
+	after, _ := ParsePlacement("after:^last")
+	if got := after.insert("first\nlast", section); got != "first\nlast\n"+section {
+		t.Errorf("Unexpected insert after unterminated line: %q", got)
+	}
+}
+
+func TestPartialsBuildCommand_RewritesSectionInPlace(t *testing.T) {
+	aggregateFile, partialsDir, command := testSetup(t)
+	if err := command.Run(); err != nil {
+		t.Fatalf("First build failed: %v", err)
+	}
+
+	// The user moves the section above their own hosts
+	built, _ := os.ReadFile(aggregateFile)
+	start := strings.Index(string(built), command.GetStartFlag())
+	userContent := string(built[:start])
+	section := string(built[start:])
+	if err := os.WriteFile(aggregateFile, []byte(section+userContent), 0600); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(partialsDir, "partial1"), []byte("Host server1-renamed"), 0600); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if err := command.Run(); err != nil {
+		t.Fatalf("Rebuild failed: %v", err)
+	}
+
+	result, _ := os.ReadFile(aggregateFile)
+	resultStr := string(result)
+	if !strings.HasPrefix(resultStr, command.GetStartFlag()) {
+		t.Errorf("Section should stay at the top of the file:\n%s", resultStr)
+	}
+	if !strings.HasSuffix(resultStr, userContent) {
+		t.Errorf("User content should stay below the section:\n%s", resultStr)
+	}
+	if !strings.Contains(resultStr, "Host server1-renamed") {
+		t.Errorf("Section content not rebuilt:\n%s", resultStr)
+	}
+}
+
+func TestPartialsBuildCommand_PlacementBeforeAnchor(t *testing.T) {
+	dir := t.TempDir()
+	partialsDir := writePartials(t, dir, "work", "Host work\n")
+	aggregateFile := filepath.Join(dir, "config")
+	original := "Host personal\n    User me\n\nHost *\n    ForwardAgent no\n"
+	if err := os.WriteFile(aggregateFile, []byte(original), 0600); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	command, _ := NewPartialsBuildCommand(aggregateFile, partialsDir, "#")
+	placement, _ := ParsePlacement(`before:^Host \*`)
+	command.SetPlacement(placement)
+
+	// Placement only matters the first time; the rebuild keeps the position
+	for i := 0; i < 2; i++ {
+		if err := command.Run(); err != nil {
+			t.Fatalf("Build failed: %v", err)
+		}
+	}
+
+	result, _ := os.ReadFile(aggregateFile)
+	resultStr := string(result)
+	if strings.Index(resultStr, "Host work") > strings.Index(resultStr, "Host *") {
+		t.Errorf("Section should be placed before 'Host *':\n%s", resultStr)
+	}
+	if strings.Count(resultStr, "Host work") != 1 {
+		t.Errorf("Section duplicated:\n%s", resultStr)
+	}
+}
+
+func TestLoadManifest_Placement(t *testing.T) {
+	dir := t.TempDir()
+	manifestPath := filepath.Join(dir, ".parts.yaml")
+	yaml := `defaults:
+  placement: top
+targets:
+  ssh:
+    target: ~/.ssh/config
+    partials: ./ssh/
+    placement:
+      before: '^Host \*'
+  hosts:
+    target: /etc/hosts
+    partials: ./hosts/
+    placement: "after: ^::1"
+  vim:
+    target: ~/.vimrc
+    partials: ./vim/
+  git:
+    target: ~/.gitconfig
+    partials: ./git/
+    placement: bottom
+`
+	if err := os.WriteFile(manifestPath, []byte(yaml), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	manifest, err := LoadManifest(manifestPath)
+	if err != nil {
+		t.Fatalf("Failed to load manifest: %v", err)
+	}
+
+	if got := manifest.ResolvedTarget("ssh").Placement.String(); got != `before: ^Host \*` {
+		t.Errorf("Unexpected ssh placement %q", got)
+	}
+	if got := manifest.ResolvedTarget("hosts").Placement.String(); got != "after: ^::1" {
+		t.Errorf("Unexpected hosts placement %q", got)
+	}
+	if got := manifest.ResolvedTarget("vim").Placement.String(); got != "top" {
+		t.Errorf("Expected default placement 'top', got %q", got)
+	}
+	// An explicit bottom overrides the top default
+	if got := manifest.ResolvedTarget("git").Placement; got.Kind != PlaceBottom {
+		t.Errorf("Expected explicit placement 'bottom', got %q", got.String())
+	}
+
+	bad := "targets:\n  ssh:\n    target: x\n    partials: y\n    placement: sideways\n"
+	if err := os.WriteFile(manifestPath, []byte(bad), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if _, err := LoadManifest(manifestPath); err == nil {
+		t.Error("Expected invalid placement to be rejected")
+	}
+}
@@ -0,0 +1,61 @@
+package src
+
+import (
+	"fmt"
+)
+
+// Ways sync prunes a partial whose section was deleted from the target
+const (
+	PruneDelete  = "delete"  // remove the partial file
+	PruneDisable = "disable" // rename it with DisabledSuffix
+)
+
+// DisabledSuffix marks a partial that was pruned by renaming. Such files are
+// never read as partials, so renaming one back re-enables it.
+const DisabledSuffix = ".disabled"
+
+// ValidatePruneMode checks a prune mode name; empty only reports deletions
+func ValidatePruneMode(mode string) error {
+	switch mode {
+	case "", PruneDelete, PruneDisable:
+		return nil
+	}
+	return fmt.Errorf("invalid prune mode '%s' (must be '%s' or '%s')", mode, PruneDelete, PruneDisable)
+}
+
+// prunePartial deletes or disables a partial along with its condition
+// sidecar, backing both up first. Returns where a disabled partial went.
+func prunePartial(fsys FS, path, mode string, backups *BackupStore) (string, error) {
+	files := []string{path}
+	if _, err := fsys.Stat(path + WhenSuffix); err == nil {
+		files = append(files, path+WhenSuffix)
+	}
+
+	if mode == PruneDisable {
+		for _, file := range files {
+			if _, err := fsys.Stat(file + DisabledSuffix); err == nil {
+				return "", fmt.Errorf("cannot disable '%s': '%s' already exists", file, file+DisabledSuffix)
+			}
+		}
+	}
+
+	for _, file := range files {
+		if err := backupBeforeWrite(fsys, backups, file); err != nil {
+			return "", err
+		}
+		if mode == PruneDisable {
+			if err := fsys.Rename(file, file+DisabledSuffix); err != nil {
+				return "", fmt.Errorf("failed to disable partial '%s': %w", file, err)
+			}
+			continue
+		}
+		if err := fsys.Remove(file); err != nil {
+			return "", fmt.Errorf("failed to delete partial '%s': %w", file, err)
+		}
+	}
+
+	if mode == PruneDisable {
+		return path + DisabledSuffix, nil
+	}
+	return "", nil
+}
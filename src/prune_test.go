@@ -0,0 +1,146 @@
+package src
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// deletedSection applies two partials, then deletes the 'staging' section
+// from the target
+func deletedSection(t *testing.T) (targetFile, partialsDir string, state TargetState) {
+	t.Helper()
+	targetFile, partialsDir, state = appliedSyncFixture(t, map[string]string{
+		"staging":      "Host staging\n",
+		"staging.when": "os: " + CurrentFacts().OS + "\n",
+		"work":         "Host work\n",
+	})
+	replaceInFile(t, targetFile, "# Source: "+filepath.Join(partialsDir, "staging")+"\nHost staging\n\n", "")
+	return targetFile, partialsDir, state
+}
+
+func TestSyncTarget_ReportsDeletedSections(t *testing.T) {
+	targetFile, partialsDir, state := deletedSection(t)
+
+	command := NewPartialsSyncCommand(targetFile, partialsDir, "#", "merge")
+	command.SetState(&state)
+	result, err := command.Run()
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if len(result.Deleted) != 1 || filepath.Base(result.Deleted[0]) != "staging" {
+		t.Fatalf("Expected staging to be reported, got %v", result.Deleted)
+	}
+	if !result.NeedsApply() {
+		t.Error("An unpruned deletion should keep the target out of sync")
+	}
+	if _, err := os.Stat(filepath.Join(partialsDir, "staging")); err != nil {
+		t.Errorf("Partial should be kept without prune: %v", err)
+	}
+}
+
+func TestSyncTarget_PrunesDeletedSections(t *testing.T) {
+	tests := []struct {
+		mode      string
+		dryRun    bool
+		wantFiles []string
+	}{
+		{PruneDisable, true, []string{"staging", "staging.when", "work"}},
+		{PruneDisable, false, []string{"staging.disabled", "staging.when.disabled", "work"}},
+		{PruneDelete, false, []string{"work"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			targetFile, partialsDir, state := deletedSection(t)
+
+			command := NewPartialsSyncCommand(targetFile, partialsDir, "#", "merge")
+			command.SetState(&state)
+			command.SetDryRun(tt.dryRun)
+			if err := command.SetPrune(tt.mode); err != nil {
+				t.Fatalf("SetPrune failed: %v", err)
+			}
+			result, err := command.Run()
+			if err != nil {
+				t.Fatalf("Sync failed: %v", err)
+			}
+			if !result.Pruned || result.NeedsApply() {
+				t.Errorf("Expected the deletion to be pruned, got %+v", result)
+			}
+
+			entries, _ := os.ReadDir(partialsDir)
+			var names []string
+			for _, entry := range entries {
+				names = append(names, entry.Name())
+			}
+			if strings.Join(names, ",") != strings.Join(tt.wantFiles, ",") {
+				t.Errorf("Expected %v, got %v", tt.wantFiles, names)
+			}
+		})
+	}
+}
+
+func TestSyncTarget_KeepsPartialsEditedSinceApply(t *testing.T) {
+	targetFile, partialsDir, state := deletedSection(t)
+	writeTree(t, partialsDir, map[string]string{"staging": "Host staging-edited\n"})
+
+	command := NewPartialsSyncCommand(targetFile, partialsDir, "#", "merge")
+	command.SetState(&state)
+	if err := command.SetPrune(PruneDelete); err != nil {
+		t.Fatalf("SetPrune failed: %v", err)
+	}
+	result, err := command.Run()
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if len(result.Deleted) != 0 {
+		t.Errorf("An edited partial must not be pruned, got %v", result.Deleted)
+	}
+	if _, err := os.Stat(filepath.Join(partialsDir, "staging")); err != nil {
+		t.Errorf("Partial should be kept: %v", err)
+	}
+}
+
+func TestListPartials_SkipsDisabled(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"work": "a\n", "old.disabled": "b\n"})
+	partials, err := ListPartials(dir, PartialOptions{})
+	if err != nil {
+		t.Fatalf("ListPartials failed: %v", err)
+	}
+	if len(partials) != 1 || partials[0].RelPath != "work" {
+		t.Errorf("Expected only 'work', got %+v", partials)
+	}
+}
+
+func TestSyncTarget_DeletedSectionsWithoutState(t *testing.T) {
+	targetFile, partialsDir, _ := deletedSection(t)
+	staging := filepath.Join(partialsDir, "staging")
+	info, _ := os.Stat(targetFile)
+
+	// A partial newer than the target may simply not be applied yet
+	later := info.ModTime().Add(time.Minute)
+	if err := os.Chtimes(staging, later, later); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	result, err := SyncTarget(targetFile, partialsDir, "#", "merge", true)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if len(result.Deleted) != 0 {
+		t.Errorf("A partial newer than the target must not be reported, got %v", result.Deleted)
+	}
+
+	earlier := info.ModTime().Add(-time.Minute)
+	if err := os.Chtimes(staging, earlier, earlier); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	result, err = SyncTarget(targetFile, partialsDir, "#", "merge", true)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if len(result.Deleted) != 1 {
+		t.Errorf("Expected the older partial to be reported, got %v", result.Deleted)
+	}
+}
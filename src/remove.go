@@ -1,9 +1,10 @@
 package src
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
-	"os"
 	"path/filepath"
 	"strings"
 )
@@ -12,7 +13,11 @@ import (
 type PartialsRemoveCommand struct {
 	aggregateFile string
 	commentChars  string
+	sectionID     string
 	dryRun        bool
+	backups       *BackupStore
+	out           io.Writer
+	fsys          FS
 }
 
 // NewPartialsRemoveCommand creates a new remove command.
@@ -22,7 +27,10 @@ func NewPartialsRemoveCommand(aggregateFile, commentChars string) (PartialsRemov
 	if err != nil {
 		return PartialsRemoveCommand{}, fmt.Errorf("failed to expand aggregate file path: %w", err)
 	}
-	return PartialsRemoveCommand{expandedAgg, commentChars, false}, nil
+	return PartialsRemoveCommand{
+		aggregateFile: expandedAgg,
+		commentChars:  commentChars,
+	}, nil
 }
 
 // SetDryRun sets the dry-run mode for the remove command
@@ -30,6 +38,32 @@ func (p *PartialsRemoveCommand) SetDryRun(dryRun bool) {
 	p.dryRun = dryRun
 }
 
+// SetSectionID selects a named PARTIALS section, so several sections can share one file.
+// Returns an error if the ID cannot be embedded in a marker line.
+func (p *PartialsRemoveCommand) SetSectionID(id string) error {
+	if err := ValidateSectionID(id); err != nil {
+		return err
+	}
+	p.sectionID = id
+	return nil
+}
+
+// SetBackupStore enables backups of the aggregate file before it is modified
+func (p *PartialsRemoveCommand) SetBackupStore(store *BackupStore) {
+	p.backups = store
+}
+
+// SetOutput sends progress messages and dry-run previews to w instead of stdout
+func (p *PartialsRemoveCommand) SetOutput(w io.Writer) {
+	p.out = w
+}
+
+// SetFS reads and writes files through fsys instead of the real filesystem.
+// Backups are still stored on disk.
+func (p *PartialsRemoveCommand) SetFS(fsys FS) {
+	p.fsys = fsys
+}
+
 // getCommentStyle returns the resolved comment style for this remove command
 func (p PartialsRemoveCommand) getCommentStyle() CommentStyle {
 	return ResolveCommentStyle(p.commentChars, p.aggregateFile)
@@ -37,89 +71,96 @@ func (p PartialsRemoveCommand) getCommentStyle() CommentStyle {
 
 // GetStartFlag returns the start marker for this remove command
 func (p PartialsRemoveCommand) GetStartFlag() string {
-	style := p.getCommentStyle()
-	if style.End != "" {
-		// Multi-character comment style with proper header block
-		return fmt.Sprintf("%s\n%s %s\n%s", style.Start, style.Start, PartialStartMarker, style.End)
-	}
-	// Single-character comment style with header block
-	return fmt.Sprintf("%s %s\n%s %s\n%s %s",
-		style.Start, MarkerSeparator, style.Start, PartialStartMarker, style.Start, MarkerSeparator)
+	return buildStartFlag(p.getCommentStyle(), p.sectionID)
 }
 
 // GetEndFlag returns the end marker for this remove command
 func (p PartialsRemoveCommand) GetEndFlag() string {
-	style := p.getCommentStyle()
-	if style.End != "" {
-		// Multi-character comment style with proper footer block
-		return fmt.Sprintf("%s\n%s %s\n%s", style.Start, style.Start, PartialEndMarker, style.End)
-	}
-	// Single-character comment style with footer block
-	return fmt.Sprintf("%s %s\n%s %s\n%s %s",
-		style.Start, MarkerSeparator, style.Start, PartialEndMarker, style.Start, MarkerSeparator)
+	return buildEndFlag(p.getCommentStyle(), p.sectionID)
 }
 
-// Run executes the remove command
-func (p PartialsRemoveCommand) Run() error {
+// Plan renders the aggregate file with the partials section removed,
+// without writing anything
+func (p PartialsRemoveCommand) Plan() (*FileChange, error) {
+	fsys := fileSystem(p.fsys)
 	path, err := filepath.Abs(p.aggregateFile)
 	if err != nil {
-		return fmt.Errorf("failed to get absolute path for aggregate file '%s': %w", p.aggregateFile, err)
+		return nil, fmt.Errorf("failed to get absolute path for aggregate file '%s': %w", p.aggregateFile, err)
 	}
 
 	// Get original file permissions before reading
 	var originalMode fs.FileMode = 0600 // default if file doesn't exist
-	if info, statErr := os.Stat(path); statErr == nil {
+	if info, statErr := fsys.Stat(path); statErr == nil {
 		originalMode = info.Mode()
 	}
 
-	content, err := os.ReadFile(path)
+	content, err := fsys.ReadFile(path)
 	if err != nil {
-		return fmt.Errorf("failed to read aggregate file '%s': %w", path, err)
+		return nil, fmt.Errorf("failed to read aggregate file '%s': %w", path, err)
 	}
 
 	output := string(content)
-	startIndex := strings.Index(output, p.GetStartFlag())
-	endIndex := strings.Index(output, p.GetEndFlag())
-
-	if startIndex == -1 || endIndex == -1 {
-		if p.dryRun {
-			fmt.Printf("DRY RUN: No partials section found in '%s' to remove\n", p.aggregateFile)
-			return nil
-		}
-		return fmt.Errorf("no partials section found in file '%s' (looking for comment style '%s')", p.aggregateFile, p.commentChars)
+	section, err := parseSection(output, p.getCommentStyle(), p.sectionID)
+	if err != nil {
+		return nil, malformedSection(p.aggregateFile, err)
+	}
+	if section == nil {
+		return nil, fmt.Errorf("%w in file '%s' (looking for comment style '%s')", ErrNoPartialsSection, p.aggregateFile, p.commentChars)
 	}
 
 	// Remove the entire partials section
-	before := output[:startIndex]
-	afterStart := endIndex + len(p.GetEndFlag())
+	before := output[:section.Start]
 	// Skip the trailing newline after the end flag if present
-	if afterStart < len(output) && output[afterStart] == '\n' {
-		afterStart++
+	afterEnd := section.End
+	if afterEnd < len(output) && output[afterEnd] == '\n' {
+		afterEnd++
 	}
-	after := output[afterStart:]
+	after := output[afterEnd:]
 
 	// Clean up any extra newlines at the end of before section
 	before = strings.TrimRight(before, "\n") + "\n"
 
-	result := before + after
+	return &FileChange{
+		Path:         p.aggregateFile,
+		Content:      []byte(before + after),
+		Mode:         originalMode,
+		Original:     content,
+		OriginalMode: originalMode,
+		Existed:      true,
+	}, nil
+}
+
+// Run executes the remove command
+func (p PartialsRemoveCommand) Run() error {
+	out := messageOutput(p.out)
+	change, err := p.Plan()
+	if errors.Is(err, ErrNoPartialsSection) && p.dryRun {
+		fmt.Fprintf(out, "DRY RUN: No partials section found in '%s' to remove\n", p.aggregateFile)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	output := string(change.Original)
+	result := string(change.Content)
 
 	if p.dryRun {
-		fmt.Printf("DRY RUN: Would remove partials section from '%s'\n", p.aggregateFile)
-		fmt.Printf("Original length: %d characters\n", len(output))
-		fmt.Printf("New length: %d characters\n", len(result))
-		fmt.Printf("Removed %d characters\n", len(output)-len(result))
-		fmt.Printf("Content preview:\n")
-		fmt.Printf("--- BEGIN FILE CONTENT ---\n")
-		fmt.Print(result)
-		fmt.Printf("--- END FILE CONTENT ---\n")
+		fmt.Fprintf(out, "DRY RUN: Would remove partials section from '%s'\n", p.aggregateFile)
+		fmt.Fprintf(out, "Original length: %d characters\n", len(output))
+		fmt.Fprintf(out, "New length: %d characters\n", len(result))
+		fmt.Fprintf(out, "Removed %d characters\n", len(output)-len(result))
+		fmt.Fprintf(out, "Content preview:\n")
+		fmt.Fprintf(out, "--- BEGIN FILE CONTENT ---\n")
+		fmt.Fprint(out, result)
+		fmt.Fprintf(out, "--- END FILE CONTENT ---\n")
 		return nil
 	}
 
-	err = os.WriteFile(p.aggregateFile, []byte(result), originalMode)
-	if err != nil {
-		return fmt.Errorf("failed to write aggregate file '%s': %w", p.aggregateFile, err)
+	if err := writeChange(fileSystem(p.fsys), change, p.backups); err != nil {
+		return err
 	}
 
-	fmt.Printf("Removed partials section from '%s'\n", p.aggregateFile)
+	fmt.Fprintf(out, "Removed partials section from '%s'\n", p.aggregateFile)
 	return nil
 }
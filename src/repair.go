@@ -0,0 +1,242 @@
+package src
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// ErrRepairDeclined is returned when a repair fix is not confirmed
+var ErrRepairDeclined = errors.New("repair declined")
+
+// SectionFix is one step of repairing a section: the problem it fixes, what
+// it does and the content with the fix applied
+type SectionFix struct {
+	Problem *MarkerError
+	Action  string
+	Content string
+}
+
+// NextSectionFix returns the fix for the first problem parseSection finds in
+// the section with the given ID, or nil if it is well-formed or absent. A
+// section starting inside another one can't be fixed safely and is an error.
+func NextSectionFix(content string, style CommentStyle, id string) (*SectionFix, error) {
+	_, err := parseSection(content, style, id)
+	var problem *MarkerError
+	if !errors.As(err, &problem) {
+		return nil, err
+	}
+
+	var ours []markerLine
+	for _, m := range scanMarkers(content, style) {
+		if m.id == id {
+			ours = append(ours, m)
+		}
+	}
+	at := func(line int) markerLine {
+		for _, m := range ours {
+			if m.line == line {
+				return m
+			}
+		}
+		return markerLine{}
+	}
+
+	fix := &SectionFix{Problem: problem}
+	switch problem.Kind {
+	case MarkerMissingEnd:
+		// Everything after the start marker becomes the section body
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		fix.Action = "add an end marker at the end of the file"
+		fix.Content = content + buildEndFlag(style, id) + "\n"
+	case MarkerMissingStart, MarkerReversed, MarkerExtraEnd:
+		fix.Action = fmt.Sprintf("remove the end marker at line %d", problem.Line)
+		fix.Content = removeBlock(content, style, at(problem.Line), at(problem.Line))
+	case MarkerNested:
+		if problem.Section != "" {
+			return nil, fmt.Errorf("%w; move or remove section '%s' by hand", problem, problem.Section)
+		}
+		fix.Action = fmt.Sprintf("remove the start marker at line %d", problem.Line)
+		fix.Content = removeBlock(content, style, at(problem.Line), at(problem.Line))
+	case MarkerDuplicate:
+		// Drop the second copy through its own end marker, if it has one
+		last := at(problem.Line)
+		for _, m := range ours {
+			if !m.start && m.line > problem.Line {
+				last = m
+				break
+			}
+		}
+		if last.line == problem.Line {
+			fix.Action = fmt.Sprintf("remove the start marker at line %d", problem.Line)
+		} else {
+			fix.Action = fmt.Sprintf("remove the second section at lines %d-%d", problem.Line, last.line)
+		}
+		fix.Content = removeBlock(content, style, at(problem.Line), last)
+	default:
+		return nil, problem
+	}
+	return fix, nil
+}
+
+// removeBlock cuts content from the marker block around first through the one
+// around last, together with the newline that follows
+func removeBlock(content string, style CommentStyle, first, last markerLine) string {
+	start, _ := blockBounds(content, style, first)
+	_, end := blockBounds(content, style, last)
+	if end < len(content) && content[end] == '\n' {
+		end++
+	}
+	return content[:start] + content[end:]
+}
+
+// PartialsRepairCommand restores a well-formed PARTIALS section in a file
+// whose markers were broken by hand edits
+type PartialsRepairCommand struct {
+	file         string
+	commentChars string
+	sectionID    string
+	confirm      func(SectionFix) bool
+	dryRun       bool
+	backups      *BackupStore
+	out          io.Writer
+	fsys         FS
+}
+
+// NewPartialsRepairCommand creates a new repair command.
+// Returns an error if path expansion fails.
+func NewPartialsRepairCommand(file, commentChars string) (PartialsRepairCommand, error) {
+	expanded, err := ExpandTildePrefix(file)
+	if err != nil {
+		return PartialsRepairCommand{}, fmt.Errorf("failed to expand file path: %w", err)
+	}
+	return PartialsRepairCommand{
+		file:         expanded,
+		commentChars: commentChars,
+	}, nil
+}
+
+// SetSectionID selects a named PARTIALS section, so several sections can share one file.
+// Returns an error if the ID cannot be embedded in a marker line.
+func (p *PartialsRepairCommand) SetSectionID(id string) error {
+	if err := ValidateSectionID(id); err != nil {
+		return err
+	}
+	p.sectionID = id
+	return nil
+}
+
+// SetConfirm asks confirm before each fix; a declined fix cancels the repair.
+// A nil confirm applies every fix.
+func (p *PartialsRepairCommand) SetConfirm(confirm func(SectionFix) bool) {
+	p.confirm = confirm
+}
+
+// SetDryRun sets the dry-run mode for the repair command
+func (p *PartialsRepairCommand) SetDryRun(dryRun bool) {
+	p.dryRun = dryRun
+}
+
+// SetBackupStore enables backups of the file before it is modified
+func (p *PartialsRepairCommand) SetBackupStore(store *BackupStore) {
+	p.backups = store
+}
+
+// SetOutput sends progress messages and dry-run previews to w instead of stdout
+func (p *PartialsRepairCommand) SetOutput(w io.Writer) {
+	p.out = w
+}
+
+// SetFS reads and writes files through fsys instead of the real filesystem.
+// Backups are still stored on disk.
+func (p *PartialsRepairCommand) SetFS(fsys FS) {
+	p.fsys = fsys
+}
+
+// Plan applies fixes until the section parses, without writing anything.
+// It returns the repaired file and the fixes in the order they were applied.
+func (p PartialsRepairCommand) Plan() (*FileChange, []SectionFix, error) {
+	fsys := fileSystem(p.fsys)
+	path, err := filepath.Abs(p.file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get absolute path for file '%s': %w", p.file, err)
+	}
+
+	var originalMode fs.FileMode = 0600
+	if info, statErr := fsys.Stat(path); statErr == nil {
+		originalMode = info.Mode()
+	}
+	original, err := fsys.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read file '%s': %w", path, err)
+	}
+
+	style := ResolveCommentStyle(p.commentChars, p.file)
+	content := string(original)
+	var fixes []SectionFix
+	// Every fix removes a marker or adds the only missing one, so this bounds the loop
+	for limit := len(scanMarkers(content, style)) + 1; ; limit-- {
+		fix, fixErr := NextSectionFix(content, style, p.sectionID)
+		if fixErr != nil {
+			return nil, nil, fmt.Errorf("cannot repair '%s': %w", p.file, fixErr)
+		}
+		if fix == nil {
+			break
+		}
+		if limit == 0 {
+			return nil, nil, fmt.Errorf("cannot repair '%s': %w", p.file, fix.Problem)
+		}
+		if p.confirm != nil && !p.confirm(*fix) {
+			return nil, nil, fmt.Errorf("'%s' is unchanged: %w", p.file, ErrRepairDeclined)
+		}
+		fixes = append(fixes, *fix)
+		content = fix.Content
+	}
+
+	return &FileChange{
+		Path:         p.file,
+		Content:      []byte(content),
+		Mode:         originalMode,
+		Original:     original,
+		OriginalMode: originalMode,
+		Existed:      true,
+	}, fixes, nil
+}
+
+// Run executes the repair command
+func (p PartialsRepairCommand) Run() error {
+	out := messageOutput(p.out)
+	change, fixes, err := p.Plan()
+	if err != nil {
+		return err
+	}
+	if len(fixes) == 0 {
+		fmt.Fprintf(out, "Partials section in '%s' needs no repair\n", p.file)
+		return nil
+	}
+
+	if p.dryRun {
+		for _, fix := range fixes {
+			fmt.Fprintf(out, "DRY RUN: Would fix %v: %s\n", fix.Problem, fix.Action)
+		}
+		fmt.Fprintf(out, "Content preview:\n")
+		fmt.Fprintf(out, "--- BEGIN FILE CONTENT ---\n")
+		fmt.Fprint(out, string(change.Content))
+		fmt.Fprintf(out, "--- END FILE CONTENT ---\n")
+		return nil
+	}
+
+	if err := writeChange(fileSystem(p.fsys), change, p.backups); err != nil {
+		return err
+	}
+	for _, fix := range fixes {
+		fmt.Fprintf(out, "Fixed %v: %s\n", fix.Problem, fix.Action)
+	}
+	fmt.Fprintf(out, "Repaired partials section in '%s'\n", p.file)
+	return nil
+}
@@ -0,0 +1,86 @@
+package src
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRepair(t *testing.T) {
+	style := CommentStyle{Start: "#"}
+	start, end := buildStartFlag(style, ""), buildEndFlag(style, "")
+	team := buildStartFlag(style, "team") + "\n" + buildEndFlag(style, "team") + "\n"
+
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{"missing end", "a\n" + start + "\nHost x", "a\n" + start + "\nHost x\n" + end + "\n"},
+		{"missing start", "a\nHost x\n" + end + "\nb\n", "a\nHost x\nb\n"},
+		{"reversed", end + "\nHost x\n" + start + "\nHost y\n", "Host x\n" + start + "\nHost y\n" + end + "\n"},
+		{"nested", start + "\n" + start + "\nHost x\n" + end + "\n", start + "\nHost x\n" + end + "\n"},
+		{"duplicate", "a\n" + start + "\nHost x\n" + end + "\nb\n" + start + "\nHost y\n" + end + "\nc\n",
+			"a\n" + start + "\nHost x\n" + end + "\nb\nc\n"},
+		{"extra end", start + "\nHost x\n" + end + "\nb\n" + end + "\n", start + "\nHost x\n" + end + "\nb\n"},
+		{"several problems", end + "\n" + start + "\nHost x\n" + start + "\n", start + "\nHost x\n" + end + "\n"},
+		{"other sections kept", team + start + "\nHost x\n", team + start + "\nHost x\n" + end + "\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fsys := NewMemFS()
+			memTree(t, fsys, map[string]string{"/mem/config": test.content})
+			repair, err := NewPartialsRepairCommand("/mem/config", "#")
+			if err != nil {
+				t.Fatalf("Failed to create repair command: %v", err)
+			}
+			repair.SetFS(fsys)
+			repair.SetOutput(io.Discard)
+			if err := repair.Run(); err != nil {
+				t.Fatalf("Repair failed: %v", err)
+			}
+			got := readMem(t, fsys, "/mem/config")
+			if got != test.expected {
+				t.Errorf("Expected:\n%s\ngot:\n%s", test.expected, got)
+			}
+			if _, err := parseSection(got, style, ""); err != nil {
+				t.Errorf("Repaired section still malformed: %v", err)
+			}
+		})
+	}
+}
+
+func TestRepair_ConfirmAndRefuse(t *testing.T) {
+	style := CommentStyle{Start: "#"}
+	broken := buildStartFlag(style, "") + "\nHost x\n"
+	fsys := NewMemFS()
+	memTree(t, fsys, map[string]string{"/mem/config": broken})
+
+	repair, _ := NewPartialsRepairCommand("/mem/config", "#")
+	repair.SetFS(fsys)
+	repair.SetOutput(io.Discard)
+	var asked []SectionFix
+	repair.SetConfirm(func(fix SectionFix) bool {
+		asked = append(asked, fix)
+		return false
+	})
+	if err := repair.Run(); !errors.Is(err, ErrRepairDeclined) {
+		t.Fatalf("Expected ErrRepairDeclined, got %v", err)
+	}
+	if len(asked) != 1 || asked[0].Problem.Kind != MarkerMissingEnd {
+		t.Errorf("Expected to be asked about the missing end marker, got %+v", asked)
+	}
+	if readMem(t, fsys, "/mem/config") != broken {
+		t.Error("A declined repair must not write the file")
+	}
+
+	// A section nested in another can't be repaired automatically
+	nested := buildStartFlag(style, "") + "\n" + buildStartFlag(style, "team") + "\n" +
+		buildEndFlag(style, "team") + "\n" + buildEndFlag(style, "") + "\n"
+	memTree(t, fsys, map[string]string{"/mem/config": nested})
+	repair.SetConfirm(nil)
+	if err := repair.Run(); err == nil || !strings.Contains(err.Error(), "by hand") {
+		t.Errorf("Expected a nested section to need a manual fix, got %v", err)
+	}
+}
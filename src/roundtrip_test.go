@@ -0,0 +1,189 @@
+package src
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// roundTripCase is one generated set of partials with their modes
+type roundTripCase struct {
+	names    []string
+	contents map[string]string
+	modes    map[string]fs.FileMode
+}
+
+// randomPartials generates partials whose lines mix ordinary content,
+// whitespace, carriage returns and lines that look like parts syntax, ending
+// with no, one or several newlines. Each starts with a unique "Host <name>".
+func randomPartials(rng *rand.Rand, style CommentStyle) roundTripCase {
+	pool := []string{
+		"", " ", "\t", "value = 1", "  indented  ", "crlf\r", "mid PARTIALS>>>>> line", "*/", "/*", "-->",
+		style.Start + " a comment",
+		style.Start + " Source: elsewhere",
+		style.Start + " " + MarkerSeparator,
+		style.Start + " " + PartialEndMarker,
+		style.Start + " " + DelimiterEnd + " p0",
+		style.Start + " " + EscapeMarker + " x",
+	}
+	endings := []string{"", "\n", "\n\n", "\n\n\n"}
+	modes := []fs.FileMode{0600, 0640, 0644, 0755}
+
+	c := roundTripCase{contents: make(map[string]string), modes: make(map[string]fs.FileMode)}
+	for i := 0; i < 1+rng.Intn(4); i++ {
+		name := fmt.Sprintf("p%d", i)
+		lines := []string{"Host " + name}
+		for j := rng.Intn(6); j > 0; j-- {
+			lines = append(lines, pool[rng.Intn(len(pool))])
+		}
+		c.names = append(c.names, name)
+		c.contents[name] = strings.Join(lines, "\n") + endings[rng.Intn(len(endings))]
+		c.modes[name] = modes[rng.Intn(len(modes))]
+	}
+	return c
+}
+
+// roundTrip applies the partials to a target and syncs it back unchanged,
+// then with one edit, then with p0's trailing blank lines edited, checking
+// the partials after each sync
+func roundTrip(t *testing.T, commentChars, mode string, delimit bool, c roundTripCase) {
+	t.Helper()
+	fsys := NewMemFS()
+	target, partialsDir := "/mem/target", "/mem/partials"
+	memTree(t, fsys, map[string]string{target: "head\n"})
+	if err := fsys.MkdirAll(partialsDir, 0755); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	for _, name := range c.names {
+		if err := fsys.WriteFile(partialsDir+"/"+name, []byte(c.contents[name]), c.modes[name]); err != nil {
+			t.Fatalf("Failed: %v", err)
+		}
+	}
+
+	var plan func() (*FileChange, error)
+	var run func() error
+	if mode == "merge" {
+		build, err := NewPartialsBuildCommand(target, partialsDir, commentChars)
+		if err != nil {
+			t.Fatalf("Failed to create build command: %v", err)
+		}
+		build.SetFS(fsys)
+		build.SetOutput(io.Discard)
+		build.SetEscapeMarkers(true)
+		build.SetDelimitPartials(delimit)
+		plan, run = build.Plan, build.Run
+	} else {
+		own := NewPartialsOwnCommand(target, partialsDir, commentChars)
+		own.SetFS(fsys)
+		own.SetOutput(io.Discard)
+		own.SetEscapeMarkers(true)
+		own.SetDelimitPartials(delimit)
+		plan, run = own.Plan, own.Run
+	}
+	if err := run(); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	built := readMem(t, fsys, target)
+
+	sync := NewPartialsSyncCommand(target, partialsDir, commentChars, mode)
+	sync.SetFS(fsys)
+	sync.SetOutput(io.Discard)
+	expectPartials := func(step string, expected map[string]string) {
+		t.Helper()
+		for _, name := range c.names {
+			path := partialsDir + "/" + name
+			if got := readMem(t, fsys, path); got != expected[name] {
+				t.Fatalf("%s: partial '%s' changed from %q to %q; target:\n%s", step, name, expected[name], got, readMem(t, fsys, target))
+			}
+			if info, err := fsys.Stat(path); err != nil || info.Mode().Perm() != c.modes[name] {
+				t.Fatalf("%s: partial '%s' lost its mode %v: %v (%v)", step, name, c.modes[name], info, err)
+			}
+		}
+	}
+
+	result, err := sync.Run()
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if result.UpdatedFiles != 0 || len(result.Orphaned) != 0 || len(result.Conflicts) != 0 {
+		t.Fatalf("Sync of an unedited target changed something: %+v; target:\n%s", result, built)
+	}
+	expectPartials("unedited sync", c.contents)
+	if change, err := plan(); err != nil || change.Changed() {
+		t.Fatalf("Reapplying after sync should change nothing (%v)", err)
+	}
+
+	// An edit in the target comes back; everything else, trailing newlines included, stays
+	edited := strings.Replace(built, "Host p0", "Host p0 edited", 1)
+	if err := fsys.WriteFile(target, []byte(edited), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if _, err := sync.Run(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	expected := make(map[string]string, len(c.contents))
+	for name, content := range c.contents {
+		expected[name] = content
+	}
+	expected["p0"] = strings.Replace(c.contents["p0"], "Host p0", "Host p0 edited", 1)
+	expectPartials("edited sync", expected)
+
+	// Trailing blank lines added or removed in the target: the target is what
+	// apply writes for p0 with that ending. Only delimiters carry the change
+	// back; a Source section can't tell them from the newline build adds, so
+	// the partial keeps its own ending.
+	p0 := partialsDir + "/p0"
+	for _, add := range []bool{true, false} {
+		retyped := strings.TrimRight(expected["p0"], "\n")
+		if add {
+			retyped = expected["p0"] + "\n\n"
+		}
+		if err := fsys.WriteFile(p0, []byte(retyped), c.modes["p0"]); err != nil {
+			t.Fatalf("Failed: %v", err)
+		}
+		change, err := plan()
+		if err != nil {
+			t.Fatalf("Plan failed: %v", err)
+		}
+		if err := fsys.WriteFile(p0, []byte(expected["p0"]), c.modes["p0"]); err != nil {
+			t.Fatalf("Failed: %v", err)
+		}
+		if err := fsys.WriteFile(target, change.Content, 0644); err != nil {
+			t.Fatalf("Failed: %v", err)
+		}
+		if _, err := sync.Run(); err != nil {
+			t.Fatalf("Sync failed: %v", err)
+		}
+		if delimit {
+			expected["p0"] = retyped
+		}
+		expectPartials(fmt.Sprintf("sync with p0 retyped as %q", retyped), expected)
+	}
+}
+
+func TestRoundTrip_Generated(t *testing.T) {
+	var styles []string
+	for commentChars := range commentStyles {
+		styles = append(styles, commentChars)
+	}
+	sort.Strings(styles)
+
+	rng := rand.New(rand.NewSource(2024))
+	for _, commentChars := range styles {
+		style := commentStyles[commentChars]
+		for _, mode := range []string{"merge", "own"} {
+			for _, delimit := range []bool{false, true} {
+				name := fmt.Sprintf("%s/%s/delimit=%v", commentChars, mode, delimit)
+				t.Run(name, func(t *testing.T) {
+					for i := 0; i < 30; i++ {
+						roundTrip(t, commentChars, mode, delimit, randomPartials(rng, style))
+					}
+				})
+			}
+		}
+	}
+}
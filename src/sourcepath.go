@@ -0,0 +1,97 @@
+package src
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Styles of the partial paths written in Source comments and delimiters
+const (
+	SourcePathAsGiven  = ""         // the partials directory as given, joined with the partial's path
+	SourcePathAbsolute = "absolute" // absolute path
+	SourcePathPartials = "partials" // relative to the partials directory
+	SourcePathManifest = "manifest" // relative to the directory of the manifest
+	SourcePathHidden   = "hidden"   // no Source comments
+)
+
+// ErrSourcesHidden is returned by sync when a target has no Source comments
+// or delimiters to map its content back to partials
+var ErrSourcesHidden = errors.New("source paths are hidden")
+
+// ValidateSourcePathStyle checks a source_path setting
+func ValidateSourcePathStyle(style string) error {
+	switch style {
+	case SourcePathAsGiven, SourcePathAbsolute, SourcePathPartials, SourcePathManifest, SourcePathHidden:
+		return nil
+	}
+	return fmt.Errorf("invalid source path style '%s' (must be 'absolute', 'partials', 'manifest' or 'hidden')", style)
+}
+
+// SourcePaths says how partial paths are written in the target and how
+// sync and status resolve them again
+type SourcePaths struct {
+	Style       string // one of the SourcePath* styles
+	ManifestDir string // base of SourcePathManifest
+}
+
+// hidden reports whether Source comments are left out
+func (s SourcePaths) hidden() bool {
+	return s.Style == SourcePathHidden
+}
+
+// format returns the path to write for the partial at path. Delimiters
+// need a path to map back, so hidden and as-given paths are written
+// relative to the partials directory there.
+func (s SourcePaths) format(partialsDir, path string, delimited bool) string {
+	switch s.Style {
+	case SourcePathAbsolute:
+		if abs, err := filepath.Abs(path); err == nil {
+			return abs
+		}
+	case SourcePathPartials:
+		return relativePath(partialsDir, path)
+	case SourcePathManifest:
+		return relativePath(s.ManifestDir, path)
+	default:
+		if delimited {
+			return relativePath(partialsDir, path)
+		}
+	}
+	return path
+}
+
+// resolve returns the absolute path of the partial a written path names.
+// Relative paths are taken from the base format wrote them against.
+func (s SourcePaths) resolve(partialsDir, written string, delimited bool) (string, error) {
+	path := filepath.FromSlash(written)
+	if !filepath.IsAbs(path) {
+		switch {
+		case s.Style == SourcePathManifest:
+			path = filepath.Join(s.ManifestDir, path)
+		case s.Style == SourcePathPartials || delimited:
+			path = filepath.Join(partialsDir, path)
+		}
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path for '%s': %w", written, err)
+	}
+	return abs, nil
+}
+
+// relativePath returns path relative to base with forward slashes, or its
+// absolute path when it is outside base
+func relativePath(base, path string) string {
+	absBase, baseErr := filepath.Abs(base)
+	absPath, pathErr := filepath.Abs(path)
+	if baseErr != nil || pathErr != nil {
+		return filepath.ToSlash(path)
+	}
+	rel, err := filepath.Rel(absBase, absPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return absPath
+	}
+	return filepath.ToSlash(rel)
+}
@@ -0,0 +1,124 @@
+package src
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestSourcePaths_FormatAndResolve(t *testing.T) {
+	partialsDir, manifestDir := "/mem/dotfiles/ssh", "/mem/dotfiles"
+	tests := []struct {
+		style     string
+		delimited bool
+		path      string
+		want      string
+	}{
+		{SourcePathAsGiven, false, "/mem/dotfiles/ssh/work", "/mem/dotfiles/ssh/work"},
+		{SourcePathAsGiven, true, "/mem/dotfiles/ssh/work", "work"},
+		{SourcePathAbsolute, false, "/mem/dotfiles/ssh/work", "/mem/dotfiles/ssh/work"},
+		{SourcePathPartials, false, "/mem/dotfiles/ssh/nested/work", "nested/work"},
+		{SourcePathManifest, false, "/mem/dotfiles/ssh/work", "ssh/work"},
+		{SourcePathManifest, true, "/mem/dotfiles/ssh/work", "ssh/work"},
+		{SourcePathHidden, true, "/mem/dotfiles/ssh/work", "work"},
+		// Partials outside the base keep an absolute path
+		{SourcePathPartials, false, "/mem/other/work", "/mem/other/work"},
+	}
+
+	for _, tt := range tests {
+		paths := SourcePaths{Style: tt.style, ManifestDir: manifestDir}
+		got := paths.format(partialsDir, tt.path, tt.delimited)
+		if got != tt.want {
+			t.Errorf("format(%q, delimited=%v) = %q, want %q", tt.style, tt.delimited, got, tt.want)
+		}
+		resolved, err := paths.resolve(partialsDir, got, tt.delimited)
+		if err != nil || resolved != tt.path {
+			t.Errorf("resolve(%q, %q) = %q, %v, want %q", tt.style, got, resolved, err, tt.path)
+		}
+	}
+}
+
+func TestSourcePaths_RoundTrip(t *testing.T) {
+	for _, style := range []string{SourcePathPartials, SourcePathManifest} {
+		t.Run(style, func(t *testing.T) {
+			fsys := NewMemFS()
+			target, partialsDir := "/mem/config", "/mem/dotfiles/ssh"
+			memTree(t, fsys, map[string]string{
+				target:                 "before\n",
+				partialsDir + "/base":  "Host base\n",
+				partialsDir + "/d/web": "Host web\n",
+			})
+			paths := SourcePaths{Style: style, ManifestDir: "/mem/dotfiles"}
+
+			build, err := NewPartialsBuildCommand(target, partialsDir, "#")
+			if err != nil {
+				t.Fatalf("Failed to create build command: %v", err)
+			}
+			build.SetFS(fsys)
+			build.SetOutput(io.Discard)
+			build.SetPartialOptions(PartialOptions{Recursive: true})
+			build.SetSourcePaths(paths)
+			if err := build.Run(); err != nil {
+				t.Fatalf("Build failed: %v", err)
+			}
+			built := readMem(t, fsys, target)
+			if strings.Contains(built, "Source: /") {
+				t.Fatalf("Expected relative Source paths, got:\n%s", built)
+			}
+
+			// Edit the target and sync it back through the relative paths
+			edited := strings.Replace(built, "Host web\n", "Host web\n  Port 2222\n", 1)
+			if err := fsys.WriteFile(target, []byte(edited), 0644); err != nil {
+				t.Fatalf("Failed to edit target: %v", err)
+			}
+			sync := NewPartialsSyncCommand(target, partialsDir, "#", "merge")
+			sync.SetFS(fsys)
+			sync.SetOutput(io.Discard)
+			sync.SetPartialOptions(PartialOptions{Recursive: true})
+			sync.SetSourcePaths(paths)
+			result, err := sync.Run()
+			if err != nil {
+				t.Fatalf("Sync failed: %v", err)
+			}
+			if result.UpdatedFiles != 1 || len(result.Orphaned) != 0 {
+				t.Fatalf("Expected one updated partial and no orphans, got %+v", result)
+			}
+			if got := readMem(t, fsys, partialsDir+"/d/web"); got != "Host web\n  Port 2222\n" {
+				t.Errorf("Unexpected synced partial: %q", got)
+			}
+		})
+	}
+}
+
+func TestSourcePaths_Hidden(t *testing.T) {
+	fsys := NewMemFS()
+	target, partialsDir := "/mem/config", "/mem/partials"
+	memTree(t, fsys, map[string]string{
+		target:               "before\n",
+		partialsDir + "/one": "Host one\n",
+	})
+	hidden := SourcePaths{Style: SourcePathHidden}
+
+	build, err := NewPartialsBuildCommand(target, partialsDir, "#")
+	if err != nil {
+		t.Fatalf("Failed to create build command: %v", err)
+	}
+	build.SetFS(fsys)
+	build.SetOutput(io.Discard)
+	build.SetSourcePaths(hidden)
+	if err := build.Run(); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if built := readMem(t, fsys, target); strings.Contains(built, "Source:") || !strings.Contains(built, "Host one\n") {
+		t.Fatalf("Expected content without Source comments, got:\n%s", built)
+	}
+
+	sync := NewPartialsSyncCommand(target, partialsDir, "#", "merge")
+	sync.SetFS(fsys)
+	sync.SetOutput(io.Discard)
+	sync.SetSourcePaths(hidden)
+	if _, err := sync.Run(); !errors.Is(err, ErrSourcesHidden) {
+		t.Fatalf("Expected ErrSourcesHidden, got %v", err)
+	}
+}
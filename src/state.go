@@ -0,0 +1,236 @@
+package src
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// stateVersion is the format version written to state files
+const stateVersion = 1
+
+// State records what apply and sync last wrote for each target of a
+// manifest, so later commands can tell a hand-edited target from a stale one.
+// Like backups, the state file is Parts' own bookkeeping and always lives on
+// the real filesystem, whatever FS the targets are written to.
+type State struct {
+	Version  int                    `json:"version"`
+	Manifest string                 `json:"manifest"`
+	Targets  map[string]TargetState `json:"targets"`
+	path     string
+}
+
+// TargetState is the recorded result of the last apply or sync of a target
+type TargetState struct {
+	Path        string         `json:"path"`
+	Mode        string         `json:"mode"`
+	Comment     string         `json:"comment"`
+	Section     string         `json:"section,omitempty"`
+	Partials    []PartialState `json:"partials"`
+	SectionHash string         `json:"section_hash"`
+	Action      string         `json:"action"`
+	Updated     time.Time      `json:"updated"`
+}
+
+// PartialState is a partial as last merged. Content is kept as the merge
+// base for sync.
+type PartialState struct {
+	Path    string `json:"path"`
+	Hash    string `json:"hash"`
+	Content string `json:"content,omitempty"`
+}
+
+// HashContent returns the "sha256:<hex>" digest of data
+func HashContent(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// DefaultStatePath returns the state file of a manifest:
+// $XDG_STATE_HOME/parts/state/<escaped manifest path>.json
+func DefaultStatePath(manifestPath string) (string, error) {
+	abs, err := filepath.Abs(manifestPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path for '%s': %w", manifestPath, err)
+	}
+	home, err := stateHome()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve state directory: %w", err)
+	}
+	return filepath.Join(home, "state", url.PathEscape(abs)+".json"), nil
+}
+
+// LoadState reads the state file at path; a missing file yields an empty state
+func LoadState(path string) (*State, error) {
+	state := &State{Version: stateVersion, Targets: map[string]TargetState{}, path: path}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file '%s': %w", path, err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse state file '%s': %w", path, err)
+	}
+	if state.Targets == nil {
+		state.Targets = map[string]TargetState{}
+	}
+	return state, nil
+}
+
+// Path returns the file the state is loaded from and saved to
+func (s *State) Path() string {
+	return s.path
+}
+
+// Target returns the recorded state of a target
+func (s *State) Target(name string) (TargetState, bool) {
+	target, ok := s.Targets[name]
+	return target, ok
+}
+
+// Record stores the state of a target
+func (s *State) Record(name string, target TargetState) {
+	s.Targets[name] = target
+}
+
+// Forget drops a target from the state
+func (s *State) Forget(name string) {
+	delete(s.Targets, name)
+}
+
+// Names returns the recorded target names in sorted order
+func (s *State) Names() []string {
+	names := make([]string, 0, len(s.Targets))
+	for name := range s.Targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Orphans returns recorded targets that are no longer in the manifest
+func (s *State) Orphans(manifest *Manifest) []string {
+	var orphans []string
+	for _, name := range s.Names() {
+		if _, exists := manifest.Targets[name]; !exists {
+			orphans = append(orphans, name)
+		}
+	}
+	return orphans
+}
+
+// Save writes the state file atomically, creating its directory if needed.
+// It holds copies of partials as merge bases, so like backups it is kept
+// private to the user.
+func (s *State) Save() error {
+	s.Version = stateVersion
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create state directory '%s': %w", dir, err)
+	}
+	if err := WriteFileAtomic(s.path, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write state file '%s': %w", s.path, err)
+	}
+	return nil
+}
+
+// CaptureTargetState hashes a resolved target as it is on disk right after
+// action ("apply" or "sync") wrote it
+func CaptureTargetState(target TargetConfig, action string) (TargetState, error) {
+	return captureTargetState(OSFS{}, target, action)
+}
+
+// captureTargetState is CaptureTargetState reading the target and partials from fsys
+func captureTargetState(fsys FS, target TargetConfig, action string) (TargetState, error) {
+	targetPath, err := ExpandTildePrefix(target.Target)
+	if err != nil {
+		return TargetState{}, err
+	}
+	partialsDir, err := ExpandTildePrefix(target.Partials)
+	if err != nil {
+		return TargetState{}, err
+	}
+	absTarget, err := filepath.Abs(targetPath)
+	if err != nil {
+		return TargetState{}, fmt.Errorf("failed to get absolute path for '%s': %w", targetPath, err)
+	}
+
+	content, err := fsys.ReadFile(absTarget)
+	if err != nil {
+		return TargetState{}, fmt.Errorf("failed to read target file '%s': %w", absTarget, err)
+	}
+	style := ResolveCommentStyle(target.Comment, absTarget)
+	managed, found, err := managedContent(string(content), style, target.Mode, target.Section)
+	if err != nil {
+		return TargetState{}, malformedSection(absTarget, err)
+	}
+	if !found {
+		return TargetState{}, fmt.Errorf("%w in file '%s'", ErrNoPartialsSection, absTarget)
+	}
+
+	files, err := listPartials(fsys, partialsDir, target.PartialOptions())
+	if err != nil {
+		return TargetState{}, err
+	}
+	files, _, err = filterPartials(fsys, files, CurrentFacts())
+	if err != nil {
+		return TargetState{}, err
+	}
+
+	partials := make([]PartialState, 0, len(files))
+	for _, file := range files {
+		data, readErr := fsys.ReadFile(file.Path)
+		if readErr != nil {
+			return TargetState{}, fmt.Errorf("failed to read partial file '%s': %w", file.Path, readErr)
+		}
+		abs, absErr := filepath.Abs(file.Path)
+		if absErr != nil {
+			return TargetState{}, fmt.Errorf("failed to get absolute path for '%s': %w", file.Path, absErr)
+		}
+		partials = append(partials, PartialState{Path: abs, Hash: HashContent(data), Content: string(data)})
+	}
+
+	return TargetState{
+		Path:        absTarget,
+		Mode:        target.Mode,
+		Comment:     style.Start,
+		Section:     target.Section,
+		Partials:    partials,
+		SectionHash: HashContent([]byte(managed)),
+		Action:      action,
+		Updated:     time.Now().UTC(),
+	}, nil
+}
+
+// partialHashes indexes the recorded partial hashes by path
+func (t TargetState) partialHashes() map[string]string {
+	hashes := make(map[string]string, len(t.Partials))
+	for _, partial := range t.Partials {
+		hashes[partial.Path] = partial.Hash
+	}
+	return hashes
+}
+
+// baseContent returns the recorded content of a partial, if the state has it
+func (t TargetState) baseContent(path string) (string, bool) {
+	for _, partial := range t.Partials {
+		// Older state files recorded only the hash
+		if partial.Path == path && HashContent([]byte(partial.Content)) == partial.Hash {
+			return partial.Content, true
+		}
+	}
+	return "", false
+}
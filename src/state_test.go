@@ -0,0 +1,165 @@
+package src
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestState_SaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "state.json")
+
+	state, err := LoadState(path)
+	if err != nil {
+		t.Fatalf("Loading a missing state failed: %v", err)
+	}
+	if len(state.Targets) != 0 || state.Path() != path {
+		t.Fatalf("Expected an empty state, got %+v", state)
+	}
+
+	state.Record("ssh", TargetState{Path: "/home/me/.ssh/config", Mode: "merge", SectionHash: HashContent([]byte("x"))})
+	state.Record("git", TargetState{Path: "/home/me/.gitconfig", Mode: "own"})
+	if err := state.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	// The state holds partial content, so it must not be readable by others
+	for file, want := range map[string]os.FileMode{path: 0600, filepath.Dir(path): 0700} {
+		info, err := os.Stat(file)
+		if err != nil {
+			t.Fatalf("Stat failed: %v", err)
+		}
+		if info.Mode().Perm() != want {
+			t.Errorf("Expected '%s' to have mode %v, got %v", file, want, info.Mode().Perm())
+		}
+	}
+
+	loaded, err := LoadState(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if names := loaded.Names(); len(names) != 2 || names[0] != "git" || names[1] != "ssh" {
+		t.Errorf("Unexpected names: %v", names)
+	}
+	if ssh, ok := loaded.Target("ssh"); !ok || ssh.SectionHash != HashContent([]byte("x")) {
+		t.Errorf("Unexpected ssh state: %+v", ssh)
+	}
+
+	loaded.Forget("git")
+	manifest := &Manifest{Targets: map[string]TargetConfig{"git": {}}}
+	if orphans := loaded.Orphans(manifest); len(orphans) != 1 || orphans[0] != "ssh" {
+		t.Errorf("Expected ssh to be orphaned, got %v", orphans)
+	}
+
+	if err := os.WriteFile(path, []byte("{not json"), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if _, err := LoadState(path); err == nil || !strings.Contains(err.Error(), "failed to parse state file") {
+		t.Errorf("Expected parse error, got %v", err)
+	}
+}
+
+func TestDefaultStatePath(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", "/tmp/state")
+	path, err := DefaultStatePath("/home/me/dotfiles/.parts.yaml")
+	if err != nil {
+		t.Fatalf("DefaultStatePath failed: %v", err)
+	}
+	if want := "/tmp/state/parts/state/%2Fhome%2Fme%2Fdotfiles%2F.parts.yaml.json"; path != want {
+		t.Errorf("Expected %s, got %s", want, path)
+	}
+}
+
+func TestCaptureTargetState(t *testing.T) {
+	dir := t.TempDir()
+	partialsDir := filepath.Join(dir, "ssh")
+	writeTree(t, partialsDir, map[string]string{"work": "Host work\n"})
+	target := filepath.Join(dir, "config")
+	if err := os.WriteFile(target, []byte("# mine\n"), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	config := TargetConfig{Target: target, Partials: partialsDir, Comment: "#", Mode: "merge"}
+
+	if _, err := CaptureTargetState(config, "apply"); err == nil {
+		t.Error("Expected an error before the target has a PARTIALS section")
+	}
+
+	build, _ := NewPartialsBuildCommand(target, partialsDir, "#")
+	if err := build.Run(); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	state, err := CaptureTargetState(config, "apply")
+	if err != nil {
+		t.Fatalf("Capture failed: %v", err)
+	}
+	if state.Path != target || state.Mode != "merge" || state.Comment != "#" || state.Action != "apply" {
+		t.Errorf("Unexpected state: %+v", state)
+	}
+	if len(state.Partials) != 1 || state.Partials[0].Hash != HashContent([]byte("Host work\n")) {
+		t.Errorf("Unexpected partial hashes: %+v", state.Partials)
+	}
+	if !strings.HasPrefix(state.SectionHash, "sha256:") {
+		t.Errorf("Unexpected section hash: %s", state.SectionHash)
+	}
+
+	// Content outside the section doesn't change its hash
+	content, _ := os.ReadFile(target)
+	if err := os.WriteFile(target, append(content, "# trailing\n"...), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	again, err := CaptureTargetState(config, "apply")
+	if err != nil {
+		t.Fatalf("Capture failed: %v", err)
+	}
+	if again.SectionHash != state.SectionHash {
+		t.Error("Expected edits outside the section to keep its hash")
+	}
+}
+
+func TestPartialsStatusCommand_CheckWithState(t *testing.T) {
+	dir := t.TempDir()
+	partialsDir := filepath.Join(dir, "ssh")
+	writeTree(t, partialsDir, map[string]string{"work": "Host work\n"})
+	target := filepath.Join(dir, "config")
+	if err := os.WriteFile(target, []byte("# mine\n"), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	build, _ := NewPartialsBuildCommand(target, partialsDir, "#")
+	if err := build.Run(); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	recorded, err := CaptureTargetState(TargetConfig{Target: target, Partials: partialsDir, Comment: "#", Mode: "merge"}, "apply")
+	if err != nil {
+		t.Fatalf("Capture failed: %v", err)
+	}
+
+	check := func() *TargetStatus {
+		command := NewPartialsStatusCommand(target, partialsDir, "#", "merge")
+		command.SetState(&recorded)
+		result, err := command.Check()
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		return result
+	}
+
+	// Edit the section, then make the partial look newer: hashes still say edited
+	content, _ := os.ReadFile(target)
+	edited := strings.Replace(string(content), "Host work\n", "Host work-edited\n", 1)
+	if err := os.WriteFile(target, []byte(edited), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	touch(t, target, -time.Hour)
+	touch(t, filepath.Join(partialsDir, "work"), 0)
+	if result := check(); result.State != StatusEdited || partialStates(result)["work"] != PartialEdited {
+		t.Errorf("Expected edited, got %+v", result)
+	}
+
+	// Changing the partial as well makes the target diverge
+	writeTree(t, partialsDir, map[string]string{"work": "Host work2\n"})
+	if result := check(); result.State != StatusDiverged || partialStates(result)["work"] != PartialChanged {
+		t.Errorf("Expected diverged, got %+v", result)
+	}
+}
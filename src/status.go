@@ -0,0 +1,365 @@
+package src
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Target states reported by PartialsStatusCommand
+const (
+	StatusInSync          = "in sync"
+	StatusStale           = "partials changed"
+	StatusEdited          = "edited"
+	StatusDiverged        = "diverged"
+	StatusMarkersMissing  = "markers missing"
+	StatusMarkersBroken   = "markers malformed"
+	StatusTargetMissing   = "target missing"
+	StatusPartialsMissing = "partials dir missing"
+)
+
+// Partial states in a status breakdown
+const (
+	PartialOK      = "ok"
+	PartialChanged = "changed" // partial edited since the target was written
+	PartialEdited  = "edited"  // section edited in the target
+	PartialNew     = "new"     // partial not merged yet
+	PartialRemoved = "removed" // section left over from a deleted partial
+	PartialSkipped = "skipped" // when condition doesn't hold
+)
+
+// TargetStatus describes how a target compares with its partials
+type TargetStatus struct {
+	Path     string
+	State    string
+	Partials []PartialStatus
+}
+
+// PartialStatus describes one partial (or leftover section) of a target
+type PartialStatus struct {
+	Path   string // path relative to the partials directory, or as written in the target
+	State  string
+	Reason string // why a partial was skipped
+}
+
+// UpToDate reports whether the target needs no apply or sync
+func (s *TargetStatus) UpToDate() bool {
+	return s.State == StatusInSync
+}
+
+// Hint suggests the command that brings the target up to date
+func (s *TargetStatus) Hint() string {
+	switch s.State {
+	case StatusStale, StatusMarkersMissing, StatusTargetMissing:
+		return "run 'parts apply'"
+	case StatusEdited:
+		return "run 'parts sync'"
+	case StatusDiverged:
+		return "review with 'parts diff', then sync or apply"
+	case StatusPartialsMissing:
+		return "check the partials path"
+	case StatusMarkersBroken:
+		return fmt.Sprintf("run 'parts repair %s'", s.Path)
+	}
+	return ""
+}
+
+// PartialsStatusCommand compares a target file with what apply would write
+type PartialsStatusCommand struct {
+	targetFile     string
+	partialsDir    string
+	commentChars   string
+	mode           string
+	sectionID      string
+	partialOptions PartialOptions
+	templateData   *TemplateData
+	checksum       bool
+	escapeMarkers  bool
+	delimit        bool
+	sourcePaths    SourcePaths
+	state          *TargetState
+	fsys           FS
+}
+
+// NewPartialsStatusCommand creates a new status command.
+// mode is "merge" or "own", as for NewPartialsSyncCommand.
+func NewPartialsStatusCommand(targetFile, partialsDir, commentChars, mode string) PartialsStatusCommand {
+	return PartialsStatusCommand{
+		targetFile:   targetFile,
+		partialsDir:  partialsDir,
+		commentChars: commentChars,
+		mode:         mode,
+	}
+}
+
+// SetSectionID selects the named PARTIALS section to inspect in merge mode.
+// Returns an error if the ID cannot be embedded in a marker line.
+func (p *PartialsStatusCommand) SetSectionID(id string) error {
+	if err := ValidateSectionID(id); err != nil {
+		return err
+	}
+	p.sectionID = id
+	return nil
+}
+
+// SetPartialOptions must match the options the target is built with
+func (p *PartialsStatusCommand) SetPartialOptions(opts PartialOptions) {
+	p.partialOptions = opts
+}
+
+// SetTemplateData renders partials before comparing them, as apply would
+func (p *PartialsStatusCommand) SetTemplateData(data *TemplateData) {
+	p.templateData = data
+}
+
+// SetEscapeMarkers must match the target's escape_markers setting, as apply would write it
+func (p *PartialsStatusCommand) SetEscapeMarkers(escape bool) {
+	p.escapeMarkers = escape
+}
+
+// SetDelimitPartials must match the target's delimit_partials setting, as apply would write it
+func (p *PartialsStatusCommand) SetDelimitPartials(delimit bool) {
+	p.delimit = delimit
+}
+
+// SetSourcePaths must match the target's source_path setting, so Source comments resolve as apply wrote them
+func (p *PartialsStatusCommand) SetSourcePaths(paths SourcePaths) {
+	p.sourcePaths = paths
+}
+
+// SetChecksum must match the target's checksum setting, as apply would write it
+func (p *PartialsStatusCommand) SetChecksum(checksum bool) {
+	p.checksum = checksum
+}
+
+// SetState supplies what apply or sync last recorded for the target, so
+// differences are attributed by content hash instead of modification time
+func (p *PartialsStatusCommand) SetState(state *TargetState) {
+	p.state = state
+}
+
+// SetFS reads the target and partials from fsys instead of the real filesystem
+func (p *PartialsStatusCommand) SetFS(fsys FS) {
+	p.fsys = fsys
+}
+
+// Check classifies the target. With a recorded state, a partial whose hash
+// changed needs apply and a section whose hash changed was edited in the
+// target. Without one, modification time decides: a partial newer than the
+// target needs apply, an older one means the section was edited.
+func (p PartialsStatusCommand) Check() (*TargetStatus, error) {
+	status := &TargetStatus{Path: p.targetFile}
+	fsys := fileSystem(p.fsys)
+
+	if _, err := fsys.Stat(p.partialsDir); err != nil {
+		status.State = StatusPartialsMissing
+		return status, nil
+	}
+	targetInfo, err := fsys.Stat(p.targetFile)
+	if err != nil {
+		status.State = StatusTargetMissing
+		return status, nil
+	}
+	content, err := fsys.ReadFile(p.targetFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read target file '%s': %w", p.targetFile, err)
+	}
+
+	style := ResolveCommentStyle(p.commentChars, p.targetFile)
+	managed, found, err := managedContent(string(content), style, p.mode, p.sectionID)
+	if err != nil {
+		status.State = StatusMarkersBroken
+		return status, nil
+	}
+	if !found {
+		status.State = StatusMarkersMissing
+		return status, nil
+	}
+
+	files, err := listPartials(fsys, p.partialsDir, p.partialOptions)
+	if err != nil {
+		return nil, err
+	}
+	files, skipped, err := filterPartials(fsys, files, CurrentFacts())
+	if err != nil {
+		return nil, err
+	}
+
+	// Own mode without a comment style, or hidden source paths without
+	// delimiters, leave no Source headers to map back
+	var sections map[string]sourceSection
+	if (p.mode == "merge" || p.commentChars != "") && (p.delimit || !p.sourcePaths.hidden()) {
+		sections = make(map[string]sourceSection)
+		for _, section := range scanSections(managed, style.Start) {
+			if section.Source == "" {
+				continue
+			}
+			abs, absErr := p.sourcePaths.resolve(p.partialsDir, section.Source, section.Delimited)
+			if absErr != nil {
+				return nil, absErr
+			}
+			sections[abs] = section
+		}
+	}
+
+	var changed, edited bool
+	var recorded map[string]string
+	if p.state != nil {
+		recorded = p.state.partialHashes()
+		// Any edit inside the managed content since it was last written
+		edited = HashContent([]byte(managed)) != p.state.SectionHash
+	} else {
+		// Without state, a checksum line still shows edits to the managed content
+		body := managed
+		if p.mode == "merge" {
+			body = strings.TrimPrefix(managed, buildStartFlag(style, p.sectionID)+"\n")
+		}
+		if written, rest := splitChecksum(body); written != nil && written.Hash != HashContent([]byte(rest)) {
+			edited = true
+		}
+	}
+	var newest time.Time
+	for _, file := range files {
+		info, statErr := fsys.Stat(file.Path)
+		if statErr != nil {
+			return nil, fmt.Errorf("failed to stat partial file '%s': %w", file.Path, statErr)
+		}
+		if info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+		abs, _ := filepath.Abs(file.Path)
+		if sections == nil {
+			// No sections to compare; the recorded hashes still show partial edits
+			if recorded != nil {
+				raw, rawErr := fsys.ReadFile(file.Path)
+				if rawErr != nil {
+					return nil, fmt.Errorf("failed to read partial file '%s': %w", file.Path, rawErr)
+				}
+				if recorded[abs] != HashContent(raw) || len(files) != len(recorded) {
+					changed = true
+				}
+			}
+			continue
+		}
+
+		section, inTarget := sections[abs]
+		delete(sections, abs)
+
+		state := PartialOK
+		if !inTarget {
+			state = PartialNew
+		} else {
+			rendered, readErr := readPartial(fsys, file.Path, p.templateData)
+			if readErr != nil {
+				return nil, readErr
+			}
+			if section.partialContent(string(rendered)) != string(rendered) {
+				state = PartialEdited
+				if recorded != nil {
+					raw, rawErr := fsys.ReadFile(file.Path)
+					if rawErr != nil {
+						return nil, fmt.Errorf("failed to read partial file '%s': %w", file.Path, rawErr)
+					}
+					if recorded[abs] != HashContent(raw) {
+						state = PartialChanged
+					}
+				} else if info.ModTime().After(targetInfo.ModTime()) {
+					state = PartialChanged
+				}
+			}
+		}
+		switch state {
+		case PartialEdited:
+			edited = true
+		case PartialChanged, PartialNew:
+			changed = true
+		}
+		status.Partials = append(status.Partials, PartialStatus{Path: file.RelPath, State: state})
+	}
+
+	// Sections whose partial is gone (or no longer selected)
+	var leftover []string
+	for sourcePath := range sections {
+		leftover = append(leftover, sourcePath)
+	}
+	sort.Strings(leftover)
+	for _, sourcePath := range leftover {
+		changed = true
+		status.Partials = append(status.Partials, PartialStatus{Path: p.displayPath(sourcePath), State: PartialRemoved})
+	}
+	for _, partial := range skipped {
+		status.Partials = append(status.Partials, PartialStatus{Path: partial.RelPath, State: PartialSkipped, Reason: partial.Reason})
+	}
+
+	// Anything the breakdown can't explain (order, comment style, mode) still needs apply
+	if !changed && !edited {
+		rendered, planErr := p.plan()
+		if planErr != nil {
+			return nil, planErr
+		}
+		if rendered.Changed() {
+			if sections == nil && p.state == nil && !newest.After(targetInfo.ModTime()) {
+				edited = true
+			} else {
+				changed = true
+			}
+		}
+	}
+
+	switch {
+	case changed && edited:
+		status.State = StatusDiverged
+	case edited:
+		status.State = StatusEdited
+	case changed:
+		status.State = StatusStale
+	default:
+		status.State = StatusInSync
+	}
+	return status, nil
+}
+
+// plan renders the target the way apply would
+func (p PartialsStatusCommand) plan() (*FileChange, error) {
+	if p.mode == "merge" {
+		buildCmd, err := NewPartialsBuildCommand(p.targetFile, p.partialsDir, p.commentChars)
+		if err != nil {
+			return nil, err
+		}
+		if err := buildCmd.SetSectionID(p.sectionID); err != nil {
+			return nil, err
+		}
+		buildCmd.SetPartialOptions(p.partialOptions)
+		buildCmd.SetTemplateData(p.templateData)
+		buildCmd.SetChecksum(p.checksum)
+		buildCmd.SetEscapeMarkers(p.escapeMarkers)
+		buildCmd.SetDelimitPartials(p.delimit)
+		buildCmd.SetSourcePaths(p.sourcePaths)
+		buildCmd.SetFS(p.fsys)
+		return buildCmd.Plan()
+	}
+	ownCmd := NewPartialsOwnCommand(p.targetFile, p.partialsDir, p.commentChars)
+	ownCmd.SetPartialOptions(p.partialOptions)
+	ownCmd.SetTemplateData(p.templateData)
+	ownCmd.SetChecksum(p.checksum)
+	ownCmd.SetEscapeMarkers(p.escapeMarkers)
+	ownCmd.SetDelimitPartials(p.delimit)
+	ownCmd.SetSourcePaths(p.sourcePaths)
+	ownCmd.SetFS(p.fsys)
+	return ownCmd.Plan()
+}
+
+// displayPath shows a section's source relative to the partials directory when it is inside it
+func (p PartialsStatusCommand) displayPath(sourcePath string) string {
+	dir, err := filepath.Abs(p.partialsDir)
+	if err != nil {
+		return sourcePath
+	}
+	rel, err := filepath.Rel(dir, sourcePath)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return sourcePath
+	}
+	return filepath.ToSlash(rel)
+}
@@ -0,0 +1,236 @@
+package src
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// touch sets a file's modification time relative to now
+func touch(t *testing.T, path string, offset time.Duration) {
+	when := time.Now().Add(offset)
+	if err := os.Chtimes(path, when, when); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+}
+
+// partialStates maps each partial path in a status to its state
+func partialStates(status *TargetStatus) map[string]string {
+	states := make(map[string]string)
+	for _, p := range status.Partials {
+		states[p.Path] = p.State
+	}
+	return states
+}
+
+func TestPartialsStatusCommand_Check(t *testing.T) {
+	setup := func(t *testing.T) (target, partialsDir string, status func() *TargetStatus) {
+		dir := t.TempDir()
+		partialsDir = filepath.Join(dir, "ssh")
+		writeTree(t, partialsDir, map[string]string{"work": "Host work\n", "home": "Host home\n"})
+		target = filepath.Join(dir, "config")
+		if err := os.WriteFile(target, []byte("# mine\n"), 0644); err != nil {
+			t.Fatalf("Failed: %v", err)
+		}
+		build, _ := NewPartialsBuildCommand(target, partialsDir, "#")
+		if err := build.Run(); err != nil {
+			t.Fatalf("Build failed: %v", err)
+		}
+		touch(t, filepath.Join(partialsDir, "work"), -time.Hour)
+		touch(t, filepath.Join(partialsDir, "home"), -time.Hour)
+		// Filesystem timestamps can be coarse; keep later edits clearly newer
+		touch(t, target, -30*time.Minute)
+
+		status = func() *TargetStatus {
+			command := NewPartialsStatusCommand(target, partialsDir, "#", "merge")
+			result, err := command.Check()
+			if err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+			return result
+		}
+		return target, partialsDir, status
+	}
+
+	t.Run("in sync", func(t *testing.T) {
+		_, _, status := setup(t)
+		result := status()
+		if result.State != StatusInSync || !result.UpToDate() {
+			t.Errorf("Expected in sync, got %+v", result)
+		}
+		if states := partialStates(result); states["work"] != PartialOK || states["home"] != PartialOK {
+			t.Errorf("Unexpected breakdown: %v", states)
+		}
+	})
+
+	t.Run("partials changed", func(t *testing.T) {
+		_, partialsDir, status := setup(t)
+		writeTree(t, partialsDir, map[string]string{"work": "Host work2\n", "new": "Host new\n"})
+		if err := os.Remove(filepath.Join(partialsDir, "home")); err != nil {
+			t.Fatalf("Failed: %v", err)
+		}
+		result := status()
+		if result.State != StatusStale || result.Hint() != "run 'parts apply'" {
+			t.Errorf("Expected partials changed, got %+v", result)
+		}
+		states := partialStates(result)
+		if states["work"] != PartialChanged || states["new"] != PartialNew || states["home"] != PartialRemoved {
+			t.Errorf("Unexpected breakdown: %v", states)
+		}
+	})
+
+	t.Run("edited in target", func(t *testing.T) {
+		target, _, status := setup(t)
+		content, _ := os.ReadFile(target)
+		if err := os.WriteFile(target, []byte(strings.Replace(string(content), "Host work", "Host work-edited", 1)), 0644); err != nil {
+			t.Fatalf("Failed: %v", err)
+		}
+		result := status()
+		if result.State != StatusEdited || partialStates(result)["work"] != PartialEdited {
+			t.Errorf("Expected edited, got %+v", result)
+		}
+	})
+
+	t.Run("diverged", func(t *testing.T) {
+		target, partialsDir, status := setup(t)
+		content, _ := os.ReadFile(target)
+		if err := os.WriteFile(target, []byte(strings.Replace(string(content), "Host work", "Host work-edited", 1)), 0644); err != nil {
+			t.Fatalf("Failed: %v", err)
+		}
+		writeTree(t, partialsDir, map[string]string{"home": "Host home2\n"})
+		touch(t, filepath.Join(partialsDir, "home"), time.Hour)
+		if result := status(); result.State != StatusDiverged {
+			t.Errorf("Expected diverged, got %+v", result)
+		}
+	})
+
+	t.Run("markers missing", func(t *testing.T) {
+		target, _, status := setup(t)
+		if err := os.WriteFile(target, []byte("# mine\n"), 0644); err != nil {
+			t.Fatalf("Failed: %v", err)
+		}
+		if result := status(); result.State != StatusMarkersMissing {
+			t.Errorf("Expected markers missing, got %+v", result)
+		}
+	})
+
+	t.Run("target missing", func(t *testing.T) {
+		target, _, status := setup(t)
+		if err := os.Remove(target); err != nil {
+			t.Fatalf("Failed: %v", err)
+		}
+		if result := status(); result.State != StatusTargetMissing {
+			t.Errorf("Expected target missing, got %+v", result)
+		}
+	})
+
+	t.Run("partials missing", func(t *testing.T) {
+		_, partialsDir, status := setup(t)
+		if err := os.RemoveAll(partialsDir); err != nil {
+			t.Fatalf("Failed: %v", err)
+		}
+		if result := status(); result.State != StatusPartialsMissing {
+			t.Errorf("Expected partials dir missing, got %+v", result)
+		}
+	})
+}
+
+func TestPartialsStatusCommand_OwnModeWithoutHeaders(t *testing.T) {
+	dir := t.TempDir()
+	partialsDir := filepath.Join(dir, "vim")
+	writeTree(t, partialsDir, map[string]string{"base": "set number\n"})
+	target := filepath.Join(dir, "vimrc")
+
+	own := NewPartialsOwnCommand(target, partialsDir, "")
+	if err := own.Run(); err != nil {
+		t.Fatalf("Own failed: %v", err)
+	}
+	touch(t, filepath.Join(partialsDir, "base"), -time.Hour)
+	touch(t, target, -30*time.Minute)
+
+	command := NewPartialsStatusCommand(target, partialsDir, "", "own")
+	if result, err := command.Check(); err != nil || result.State != StatusInSync {
+		t.Fatalf("Expected in sync, got %+v, %v", result, err)
+	}
+
+	if err := os.WriteFile(target, []byte("set nonumber\n"), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if result, err := command.Check(); err != nil || result.State != StatusEdited {
+		t.Errorf("Expected edited, got %+v, %v", result, err)
+	}
+}
+
+func TestPartialsStatusCommand_Delimited(t *testing.T) {
+	dir := t.TempDir()
+	partialsDir := filepath.Join(dir, "ssh")
+	writeTree(t, partialsDir, map[string]string{"work": "Host work", "home": "Host home\n\n"})
+	target := filepath.Join(dir, "config")
+	if err := os.WriteFile(target, []byte("# mine\n"), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	build, _ := NewPartialsBuildCommand(target, partialsDir, "#")
+	build.SetDelimitPartials(true)
+	if err := build.Run(); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	touch(t, filepath.Join(partialsDir, "work"), -time.Hour)
+	touch(t, filepath.Join(partialsDir, "home"), -time.Hour)
+
+	command := NewPartialsStatusCommand(target, partialsDir, "#", "merge")
+	command.SetDelimitPartials(true)
+	result, err := command.Check()
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if result.State != StatusInSync {
+		t.Fatalf("Expected in sync, got %s (%v)", result.State, result.Partials)
+	}
+
+	// Dropping a trailing blank line is an edit once partials are delimited
+	content, _ := os.ReadFile(target)
+	if err := os.WriteFile(target, []byte(strings.Replace(string(content), "Host home\n\n", "Host home\n", 1)), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	result, err = command.Check()
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if result.State != StatusEdited || partialStates(result)["home"] != PartialEdited {
+		t.Errorf("Expected 'home' to be edited, got %s (%v)", result.State, result.Partials)
+	}
+}
+
+func TestPartialsStatusCommand_FS(t *testing.T) {
+	fsys := NewMemFS()
+	target, partialsDir := targetFixture(t, fsys, "/mem", map[string]string{"work": "Host work\n"})
+	build, _ := NewPartialsBuildCommand(target, partialsDir, "#")
+	build.SetFS(fsys)
+	build.SetOutput(io.Discard)
+	if err := build.Run(); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	state, err := captureTargetState(fsys, TargetConfig{Target: target, Partials: partialsDir, Mode: "merge", Comment: "#"}, "apply")
+	if err != nil {
+		t.Fatalf("Failed to capture state: %v", err)
+	}
+
+	command := NewPartialsStatusCommand(target, partialsDir, "#", "merge")
+	command.SetFS(fsys)
+	command.SetState(&state)
+	result, err := command.Check()
+	if err != nil || result.State != StatusInSync {
+		t.Fatalf("Expected in sync, got %+v (%v)", result, err)
+	}
+
+	edited := strings.Replace(readMem(t, fsys, target), "Host work", "Host edited", 1)
+	if err := fsys.WriteFile(target, []byte(edited), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if result, err = command.Check(); err != nil || result.State != StatusEdited {
+		t.Errorf("Expected edited, got %+v (%v)", result, err)
+	}
+}
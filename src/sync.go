@@ -2,7 +2,7 @@ package src
 
 import (
 	"fmt"
-	"os"
+	"io"
 	"path/filepath"
 	"strings"
 )
@@ -12,136 +12,708 @@ type SyncResult struct {
 	UpdatedFiles int
 	SkippedFiles int
 	ChangedPaths []string
+	Bytes        int             // size of the partial content written
+	Merged       []string        // partials that now combine edits from both sides
+	Stale        []string        // partials edited since the last apply, target section unchanged
+	Conflicts    []SyncConflict  // partials both sides changed in overlapping places
+	Orphaned     []OrphanedChunk // managed content that belongs to no partial
+	Deleted      []string        // partials whose section was deleted from the target
+	Pruned       bool            // Deleted partials were deleted or disabled
 }
 
-// ExtractPartialSections parses file content and splits it by "# Source: <path>" comments.
-// Returns a map of source-path -> content-after-that-comment.
+// SyncConflict lists the overlapping edits found in one partial
+type SyncConflict struct {
+	Path      string
+	Conflicts []MergeConflict
+	Written   bool // false when the partial was left alone
+	Resolved  bool // settled by --ours or --theirs
+}
+
+// NeedsApply reports whether partials and target still differ after the sync
+func (r *SyncResult) NeedsApply() bool {
+	return len(r.Merged) > 0 || len(r.Stale) > 0 || len(r.Conflicts) > 0 || len(r.Orphaned) > 0 ||
+		(len(r.Deleted) > 0 && !r.Pruned)
+}
+
+// Unresolved returns the conflicts left for the user: refused or written with markers
+func (r *SyncResult) Unresolved() []SyncConflict {
+	var unresolved []SyncConflict
+	for _, conflict := range r.Conflicts {
+		if !conflict.Resolved {
+			unresolved = append(unresolved, conflict)
+		}
+	}
+	return unresolved
+}
+
+// Captured reports whether every edit found in the target is now in the
+// partials: no conflict was refused, no orphaned content left unadopted and
+// no deleted section left unpruned
+func (r *SyncResult) Captured() bool {
+	for _, conflict := range r.Conflicts {
+		if !conflict.Written {
+			return false
+		}
+	}
+	return len(r.Unadopted()) == 0 && (len(r.Deleted) == 0 || r.Pruned)
+}
+
+// Unadopted returns the orphaned chunks left in the target only
+func (r *SyncResult) Unadopted() []OrphanedChunk {
+	var unadopted []OrphanedChunk
+	for _, orphan := range r.Orphaned {
+		if orphan.Adopted == "" {
+			unadopted = append(unadopted, orphan)
+		}
+	}
+	return unadopted
+}
+
+// ExtractPartialSections parses file content and splits it by "# Source: <path>" comments
+// or per-partial delimiters. Returns a map of source-path -> content-after-that-comment,
+// with paths as written (see SourcePaths); delimited sections keep their exact content.
 func ExtractPartialSections(content, commentChars string) (map[string]string, error) {
+	sections := make(map[string]string)
+	for _, section := range scanSections(content, commentChars) {
+		if section.Source != "" {
+			sections[section.Source] = section.Content
+		}
+	}
+	return sections, nil
+}
+
+// ExtractOrphanedChunks returns the content that precedes the first
+// "# Source: <path>" comment, which belongs to no partial. Blank lines and
+// marker lines don't count.
+func ExtractOrphanedChunks(content, commentChars string) []OrphanedChunk {
+	var orphans []OrphanedChunk
+	for _, section := range scanSections(content, commentChars) {
+		if section.Source == "" {
+			orphans = append(orphans, OrphanedChunk{Line: section.Line, Content: section.Content})
+		}
+	}
+	return orphans
+}
+
+// sourceSection is the content following a source comment, starting at Line (1-based)
+type sourceSection struct {
+	Source    string
+	Line      int
+	Content   string
+	Delimited bool // between delimiters: Source is relative and Content exact
+}
+
+// scanSections splits content at source comments or delimiters, in order.
+// Content outside any partial is returned as a section without a Source, and
+// only if it has a non-blank line.
+func scanSections(content, commentChars string) []sourceSection {
 	style := ResolveCommentStyle(commentChars, "")
 	prefix := fmt.Sprintf("%s Source: ", style.Start)
 
-	lines := strings.Split(content, "\n")
-	sections := make(map[string]string)
-	var currentPath string
+	var sections []sourceSection
+	current := sourceSection{Line: 1}
 	var currentContent strings.Builder
+	flush := func() {
+		current.Content = currentContent.String()
+		if !current.Delimited {
+			current.Content = normalizeSectionContent(current.Content)
+		}
+		if current.Source != "" || strings.TrimSpace(current.Content) != "" {
+			sections = append(sections, current)
+		}
+		currentContent.Reset()
+	}
+
+	for i, line := range strings.Split(content, "\n") {
+		if word, rel, ok := parseDelimiter(line, style); ok {
+			if word == DelimiterBegin {
+				flush()
+				current = sourceSection{Source: rel, Line: i + 1, Delimited: true}
+				continue
+			}
+			if current.Delimited {
+				text := currentContent.String()
+				if word == DelimiterEndNoEOL {
+					text = strings.TrimSuffix(text, "\n")
+				}
+				currentContent.Reset()
+				currentContent.WriteString(text)
+				flush()
+				current = sourceSection{Line: i + 2}
+				continue
+			}
+		}
 
-	for _, line := range lines {
 		// Check for source comment
 		if strings.HasPrefix(line, prefix) {
-			// Save previous section
-			if currentPath != "" {
-				sections[currentPath] = normalizeSectionContent(currentContent.String())
-			}
+			flush()
 			// Extract path from source comment
 			pathPart := strings.TrimPrefix(line, prefix)
 			// Remove closing comment chars if present (e.g., " */")
 			if style.End != "" {
 				pathPart = strings.TrimSuffix(pathPart, " "+style.End)
 			}
-			currentPath = strings.TrimSpace(pathPart)
-			currentContent.Reset()
+			current = sourceSection{Source: strings.TrimSpace(pathPart), Line: i + 1}
 			continue
 		}
 
-		// Skip marker lines (PARTIALS>>>>>, PARTIALS<<<<<, separator)
-		if strings.Contains(line, PartialStartMarker) ||
-			strings.Contains(line, PartialEndMarker) ||
-			strings.Contains(line, MarkerSeparator) {
+		// Skip marker lines (PARTIALS>>>>>, PARTIALS<<<<<, separator, checksum)
+		// and restore lines build escaped so they weren't read as markers
+		if isMarkerSyntax(line, style.Start) && !strings.HasPrefix(line, style.Start+" "+EscapeMarker+" ") {
 			continue
 		}
+		line = unescapeLine(line, style.Start)
 
-		if currentPath != "" {
-			currentContent.WriteString(line)
-			currentContent.WriteByte('\n')
+		if current.Source == "" && currentContent.Len() == 0 && strings.TrimSpace(line) == "" {
+			current.Line = i + 2 // an orphaned chunk starts at its first non-blank line
+			continue
 		}
+		currentContent.WriteString(line)
+		currentContent.WriteByte('\n')
 	}
+	flush()
+
+	return sections
+}
 
-	// Save last section
-	if currentPath != "" {
-		sections[currentPath] = normalizeSectionContent(currentContent.String())
+// PartialsSyncCommand handles pulling edits made in a target file back into partials
+type PartialsSyncCommand struct {
+	targetFile     string
+	partialsDir    string
+	commentChars   string
+	mode           string
+	sectionID      string
+	partialOptions PartialOptions
+	state          *TargetState
+	conflictMode   string
+	adopt          bool
+	adoptName      string
+	prune          string
+	sourcePaths    SourcePaths
+	dryRun         bool
+	backups        *BackupStore
+	out            io.Writer
+	fsys           FS
+}
+
+// NewPartialsSyncCommand creates a new sync command.
+// mode is "merge" (only the PARTIALS section is read) or "own" (the whole file).
+func NewPartialsSyncCommand(targetFile, partialsDir, commentChars, mode string) PartialsSyncCommand {
+	return PartialsSyncCommand{
+		targetFile:   targetFile,
+		partialsDir:  partialsDir,
+		commentChars: commentChars,
+		mode:         mode,
 	}
+}
 
-	return sections, nil
+// SetDryRun sets the dry-run mode
+func (p *PartialsSyncCommand) SetDryRun(dryRun bool) {
+	p.dryRun = dryRun
+}
+
+// SetSectionID selects the named PARTIALS section to read in merge mode.
+// Returns an error if the ID cannot be embedded in a marker line.
+func (p *PartialsSyncCommand) SetSectionID(id string) error {
+	if err := ValidateSectionID(id); err != nil {
+		return err
+	}
+	p.sectionID = id
+	return nil
+}
+
+// SetPartialOptions must match the options the target was built with, so that
+// sections map back to the same set of (possibly nested) partial files
+func (p *PartialsSyncCommand) SetPartialOptions(opts PartialOptions) {
+	p.partialOptions = opts
+}
+
+// SetState supplies what the last apply recorded for the target. The recorded
+// partials are the merge base: a partial edited since then is merged with the
+// target's edits instead of being overwritten.
+func (p *PartialsSyncCommand) SetState(state *TargetState) {
+	p.state = state
+}
+
+// SetConflictMode chooses how edits both sides made to the same lines are
+// settled: ConflictRefuse (the default), ConflictMarkers, ConflictOurs (keep
+// the partial) or ConflictTheirs (take the target)
+func (p *PartialsSyncCommand) SetConflictMode(mode string) error {
+	if err := ValidateConflictMode(mode); err != nil {
+		return err
+	}
+	p.conflictMode = mode
+	return nil
+}
+
+// SetAdopt writes content in the managed block that belongs to no partial
+// into new partial files, named by pattern (DefaultAdoptName if empty; see
+// ValidateAdoptName). Without it such content is only reported.
+func (p *PartialsSyncCommand) SetAdopt(adopt bool, pattern string) error {
+	if err := ValidateAdoptName(pattern); err != nil {
+		return err
+	}
+	p.adopt = adopt
+	p.adoptName = pattern
+	return nil
+}
+
+// SetPrune chooses what happens to partials whose section was deleted from
+// the target: PruneDelete, PruneDisable, or "" to only report them
+func (p *PartialsSyncCommand) SetPrune(mode string) error {
+	if err := ValidatePruneMode(mode); err != nil {
+		return err
+	}
+	p.prune = mode
+	return nil
+}
+
+// SetSourcePaths must match the target's source_path setting, so Source
+// comments resolve to the partials apply wrote them for
+func (p *PartialsSyncCommand) SetSourcePaths(paths SourcePaths) {
+	p.sourcePaths = paths
+}
+
+// SetBackupStore enables backups of partial files before they are overwritten
+func (p *PartialsSyncCommand) SetBackupStore(store *BackupStore) {
+	p.backups = store
+}
+
+// SetOutput sends progress messages and dry-run previews to w instead of stdout
+func (p *PartialsSyncCommand) SetOutput(w io.Writer) {
+	p.out = w
+}
+
+// SetFS reads and writes files through fsys instead of the real filesystem.
+// Backups are still stored on disk.
+func (p *PartialsSyncCommand) SetFS(fsys FS) {
+	p.fsys = fsys
 }
 
 // SyncTarget reads the target file, extracts sections by source comment,
 // and writes changed content back to the partial files.
 func SyncTarget(targetFile, partialsDir, commentChars, mode string, dryRun bool) (*SyncResult, error) {
-	content, err := os.ReadFile(targetFile)
+	command := NewPartialsSyncCommand(targetFile, partialsDir, commentChars, mode)
+	command.SetDryRun(dryRun)
+	return command.Run()
+}
+
+// Plan maps the target's sections back onto partials and returns the partial
+// files whose content would change, without writing anything
+func (p PartialsSyncCommand) Plan() ([]*FileChange, *SyncResult, error) {
+	targetFile, partialsDir := p.targetFile, p.partialsDir
+	fsys := fileSystem(p.fsys)
+
+	content, err := fsys.ReadFile(targetFile)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read target file '%s': %w", targetFile, err)
+		return nil, nil, fmt.Errorf("failed to read target file '%s': %w", targetFile, err)
 	}
 
-	var sectionContent string
-	contentStr := string(content)
-
-	if mode == "merge" {
-		// Extract only the content between PARTIALS markers
-		style := ResolveCommentStyle(commentChars, targetFile)
-		startFlag := buildStartFlag(style)
-		endFlag := buildEndFlag(style)
+	style := ResolveCommentStyle(p.commentChars, targetFile)
+	sectionContent, bodyStart := string(content), 0
+	if p.mode == "merge" {
+		section, parseErr := parseSection(string(content), style, p.sectionID)
+		if parseErr != nil {
+			return nil, nil, malformedSection(targetFile, parseErr)
+		}
+		if section == nil {
+			return nil, &SyncResult{}, nil // No managed section found
+		}
+		// Only the body: the start flag's comment lines would otherwise look
+		// like content before the first source comment
+		sectionContent, bodyStart = string(content[section.BodyStart:section.BodyEnd]), section.BodyStart
+	}
+	lineOffset := strings.Count(string(content[:bodyStart]), "\n")
 
-		startIdx := strings.Index(contentStr, startFlag)
-		endIdx := strings.Index(contentStr, endFlag)
-		if startIdx == -1 || endIdx == -1 {
-			return &SyncResult{}, nil // No managed section found
+	// Index sections by absolute path so nested partials map back regardless
+	// of how the partials directory was spelled when the target was built.
+	// Pass the resolved style so "auto" matches the headers build wrote.
+	sectionsByPath := make(map[string]sourceSection)
+	var orphans []OrphanedChunk
+	var sourced []sourceSection
+	for _, section := range scanSections(sectionContent, style.Start) {
+		section.Line += lineOffset
+		if section.Source == "" {
+			orphans = append(orphans, OrphanedChunk{Line: section.Line, Content: section.Content})
+			continue
 		}
-		sectionContent = contentStr[startIdx:endIdx]
-	} else {
-		// Own mode: entire file is managed
-		sectionContent = contentStr
+		absSource, absErr := p.sourcePaths.resolve(partialsDir, section.Source, section.Delimited)
+		if absErr != nil {
+			return nil, nil, absErr
+		}
+		sectionsByPath[absSource] = section
+		section.Source = absSource
+		sourced = append(sourced, section)
 	}
 
-	sections, err := ExtractPartialSections(sectionContent, commentChars)
+	if p.sourcePaths.hidden() && len(sourced) == 0 && len(orphans) > 0 {
+		return nil, nil, fmt.Errorf("'%s': %w, so its content can't be mapped back to partials (set delimit_partials or another source_path)", targetFile, ErrSourcesHidden)
+	}
+
+	partials, err := listPartials(fsys, partialsDir, p.partialOptions)
 	if err != nil {
-		return nil, fmt.Errorf("failed to extract sections: %w", err)
+		return nil, nil, err
 	}
 
 	result := &SyncResult{}
+	var changes []*FileChange
+	matched := make(map[string]bool)
 
-	for sourcePath, newContent := range sections {
-		// Verify the source path is within the partials directory
-		absSource, _ := filepath.Abs(sourcePath)
-		absPartials, _ := filepath.Abs(partialsDir)
-		if !strings.HasPrefix(absSource, absPartials) {
-			result.SkippedFiles++
+	// Walk the partials in build order so updates are reported deterministically
+	for _, partial := range partials {
+		sourcePath := partial.Path
+		absSource, absErr := filepath.Abs(sourcePath)
+		if absErr != nil {
+			return nil, nil, fmt.Errorf("failed to get absolute path for '%s': %w", sourcePath, absErr)
+		}
+		section, exists := sectionsByPath[absSource]
+		if !exists {
+			if p.sectionDeleted(partial, absSource) {
+				result.Deleted = append(result.Deleted, sourcePath)
+			}
 			continue
 		}
+		matched[absSource] = true
 
 		// Read current partial content
-		existing, readErr := os.ReadFile(sourcePath)
-		if readErr != nil {
+		existing, readErr := fsys.ReadFile(sourcePath)
+		info, statErr := fsys.Stat(sourcePath)
+		if readErr != nil || statErr != nil {
 			result.SkippedFiles++
 			continue
 		}
 
-		// Compare — trim trailing newlines for comparison to handle minor formatting diffs
-		existingTrimmed := strings.TrimRight(string(existing), "\n")
-		newTrimmed := strings.TrimRight(newContent, "\n")
-
-		if existingTrimmed == newTrimmed {
+		ours := string(existing)
+		theirs := section.partialContent(ours)
+		if ours == theirs {
 			continue // No change
 		}
 
+		newContent := theirs
+		if base, ok := p.baseContent(absSource); ok && base != ours {
+			if theirs == base {
+				// Only the partial changed; apply will carry it to the target
+				result.Stale = append(result.Stale, sourcePath)
+				continue
+			}
+			merged := Merge3(base, ours, theirs, p.resolution(), "partial", "target")
+			if len(merged.Conflicts) > 0 {
+				result.Conflicts = append(result.Conflicts, SyncConflict{
+					Path:      sourcePath,
+					Conflicts: merged.Conflicts,
+					Written:   p.resolution() != ConflictRefuse,
+					Resolved:  p.resolution() == ConflictOurs || p.resolution() == ConflictTheirs,
+				})
+				if p.resolution() == ConflictRefuse {
+					continue
+				}
+			}
+			newContent = merged.Content
+			if newContent == ours {
+				continue
+			}
+			if newContent != theirs {
+				result.Merged = append(result.Merged, sourcePath)
+			}
+		}
+
 		result.UpdatedFiles++
 		result.ChangedPaths = append(result.ChangedPaths, sourcePath)
+		changes = append(changes, &FileChange{
+			Path:         sourcePath,
+			Content:      []byte(newContent),
+			Mode:         info.Mode(),
+			Original:     existing,
+			OriginalMode: info.Mode(),
+			Existed:      true,
+		})
+	}
+
+	// Sections that point outside the selected partials are not written back
+	result.SkippedFiles += len(sectionsByPath) - len(matched)
+	for _, section := range sourced {
+		if !matched[section.Source] && strings.TrimSpace(section.Content) != "" {
+			orphans = append(orphans, OrphanedChunk{Source: section.Source, Line: section.Line, Content: section.Content})
+			matched[section.Source] = true // report a repeated header once
+		}
+	}
+
+	adopted, err := p.adoptOrphans(orphans, result)
+	if err != nil {
+		return nil, nil, err
+	}
+	result.Orphaned = orphans
+	changes = append(changes, adopted...)
+	for _, change := range changes {
+		result.Bytes += len(change.Content)
+	}
+	result.Pruned = p.prune != "" && len(result.Deleted) > 0
 
-		if dryRun {
-			fmt.Printf("DRY RUN: Would update '%s'\n", sourcePath)
+	return changes, result, nil
+}
+
+// adoptOrphans names a new partial for each orphaned chunk when adopting,
+// recording the name in the chunk, and returns the files to create
+func (p PartialsSyncCommand) adoptOrphans(orphans []OrphanedChunk, result *SyncResult) ([]*FileChange, error) {
+	if !p.adopt {
+		return nil, nil
+	}
+	taken := make(map[string]bool)
+	var changes []*FileChange
+	for i := range orphans {
+		name, err := adoptName(fileSystem(p.fsys), p.partialsDir, p.adoptName, p.partialOptions, orphans[i], taken)
+		if err != nil {
+			return nil, err
+		}
+		taken[name] = true
+		orphans[i].Adopted = name
+		result.UpdatedFiles++
+		result.ChangedPaths = append(result.ChangedPaths, name)
+		changes = append(changes, &FileChange{
+			Path:    name,
+			Content: []byte(orphans[i].Content),
+			Mode:    0644,
+		})
+	}
+	return changes, nil
+}
+
+// sectionDeleted reports whether a partial without a section in the target
+// had one that the user deleted. With state the partial must have been
+// applied and be unchanged since; without, it must be older than the target.
+// Partials whose condition doesn't hold were never in the target.
+func (p PartialsSyncCommand) sectionDeleted(partial Partial, absPath string) bool {
+	fsys := fileSystem(p.fsys)
+	kept, _, err := filterPartials(fsys, []Partial{partial}, CurrentFacts())
+	if err != nil || len(kept) == 0 {
+		return false
+	}
+	if p.state != nil {
+		data, readErr := fsys.ReadFile(partial.Path)
+		hash, recorded := p.state.partialHashes()[absPath]
+		return readErr == nil && recorded && hash == HashContent(data)
+	}
+	partialInfo, partialErr := fsys.Stat(partial.Path)
+	targetInfo, targetErr := fsys.Stat(p.targetFile)
+	return partialErr == nil && targetErr == nil && !partialInfo.ModTime().After(targetInfo.ModTime())
+}
+
+// baseContent returns the partial as last applied
+func (p PartialsSyncCommand) baseContent(absPath string) (string, bool) {
+	if p.state == nil {
+		return "", false
+	}
+	return p.state.baseContent(absPath)
+}
+
+// resolution returns the conflict mode, defaulting to ConflictRefuse
+func (p PartialsSyncCommand) resolution() string {
+	if p.conflictMode == "" {
+		return ConflictRefuse
+	}
+	return p.conflictMode
+}
+
+// Run executes the sync command
+func (p PartialsSyncCommand) Run() (*SyncResult, error) {
+	out := messageOutput(p.out)
+	fsys := fileSystem(p.fsys)
+	changes, result, err := p.Plan()
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := ""
+	if p.dryRun {
+		prefix = "DRY RUN: "
+	}
+	for _, path := range result.Stale {
+		fmt.Fprintf(out, "%sSkipping '%s': partial changed since the last apply; run 'parts apply'\n", prefix, path)
+	}
+	for _, conflict := range result.Conflicts {
+		fmt.Fprintf(out, "%sCONFLICT in '%s': %s\n", prefix, conflict.Path, describeConflict(conflict, p.resolution()))
+	}
+	for _, orphan := range result.Orphaned {
+		if orphan.Adopted == "" {
+			fmt.Fprintf(out, "%sFound %s in '%s' that belongs to no partial; rerun with --adopt to keep it\n",
+				prefix, describeOrphan(orphan), p.targetFile)
+		}
+	}
+
+	for _, change := range changes {
+		adopted := adoptedChunk(result.Orphaned, change.Path)
+		if p.dryRun {
+			if adopted != nil {
+				fmt.Fprintf(out, "DRY RUN: Would adopt %s into '%s'\n", describeOrphan(*adopted), change.Path)
+			} else {
+				fmt.Fprintf(out, "DRY RUN: Would update '%s'\n", change.Path)
+			}
 			continue
 		}
 
-		// Write back — preserve trailing newline
-		writeContent := strings.TrimRight(newContent, "\n") + "\n"
-		if writeErr := os.WriteFile(sourcePath, []byte(writeContent), 0644); writeErr != nil {
-			return nil, fmt.Errorf("failed to write partial '%s': %w", sourcePath, writeErr)
+		if adopted != nil {
+			if mkdirErr := fsys.MkdirAll(filepath.Dir(change.Path), 0755); mkdirErr != nil {
+				return nil, fmt.Errorf("failed to create directory for partial '%s': %w", change.Path, mkdirErr)
+			}
+		}
+		if backupErr := backupBeforeWrite(fsys, p.backups, change.Path); backupErr != nil {
+			return nil, backupErr
+		}
+		if writeErr := fsys.WriteFile(change.Path, change.Content, change.Mode); writeErr != nil {
+			return nil, fmt.Errorf("failed to write partial '%s': %w", change.Path, writeErr)
+		}
+		if adopted != nil {
+			fmt.Fprintf(out, "Adopted %s into '%s'\n", describeOrphan(*adopted), change.Path)
+		} else {
+			fmt.Fprintf(out, "Updated '%s'\n", change.Path)
+		}
+	}
+
+	for _, path := range result.Deleted {
+		switch {
+		case p.prune == "":
+			fmt.Fprintf(out, "%sSection of '%s' was deleted from '%s'; rerun with --prune to remove the partial\n", prefix, path, p.targetFile)
+		case p.dryRun:
+			fmt.Fprintf(out, "DRY RUN: Would %s '%s'\n", p.prune, path)
+		default:
+			disabled, pruneErr := prunePartial(fsys, path, p.prune, p.backups)
+			if pruneErr != nil {
+				return nil, pruneErr
+			}
+			if disabled != "" {
+				fmt.Fprintf(out, "Disabled '%s' (renamed to '%s')\n", path, disabled)
+			} else {
+				fmt.Fprintf(out, "Deleted '%s'\n", path)
+			}
+		}
+	}
+
+	if !p.dryRun && result.Captured() {
+		accepted, acceptErr := p.acceptEdits(fsys)
+		if acceptErr != nil {
+			return nil, acceptErr
+		}
+		if accepted {
+			fmt.Fprintf(out, "Updated the checksum of '%s' to accept its edits\n", p.targetFile)
 		}
-		fmt.Printf("Updated '%s'\n", sourcePath)
 	}
 
 	return result, nil
 }
 
+// acceptEdits updates the target's checksum line once its edits are in the
+// partials, so the next apply doesn't refuse to overwrite them
+func (p PartialsSyncCommand) acceptEdits(fsys FS) (bool, error) {
+	content, err := fsys.ReadFile(p.targetFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to read target file '%s': %w", p.targetFile, err)
+	}
+	info, err := fsys.Stat(p.targetFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to stat target file '%s': %w", p.targetFile, err)
+	}
+
+	style := ResolveCommentStyle(p.commentChars, p.targetFile)
+	output := string(content)
+	if p.mode == "merge" {
+		section, parseErr := parseSection(output, style, p.sectionID)
+		if parseErr != nil || section == nil {
+			return false, nil
+		}
+		body, accepted := acceptEdits(style, output[section.BodyStart:section.BodyEnd])
+		if !accepted {
+			return false, nil
+		}
+		output = output[:section.BodyStart] + body + output[section.BodyEnd:]
+	} else {
+		body, accepted := acceptEdits(style, output)
+		if !accepted {
+			return false, nil
+		}
+		output = body
+	}
+
+	change := &FileChange{
+		Path:         p.targetFile,
+		Content:      []byte(output),
+		Mode:         info.Mode(),
+		Original:     content,
+		OriginalMode: info.Mode(),
+		Existed:      true,
+	}
+	if err := writeChange(fsys, change, p.backups); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// adoptedChunk returns the orphaned chunk adopted into path, if any
+func adoptedChunk(orphans []OrphanedChunk, path string) *OrphanedChunk {
+	for i := range orphans {
+		if orphans[i].Adopted == path {
+			return &orphans[i]
+		}
+	}
+	return nil
+}
+
+// describeOrphan says where an orphaned chunk is, e.g. "3 line(s) at line 12"
+func describeOrphan(orphan OrphanedChunk) string {
+	lines := strings.Count(orphan.Content, "\n")
+	if orphan.Source != "" {
+		return fmt.Sprintf("%d line(s) under 'Source: %s' at line %d", lines, orphan.Source, orphan.Line)
+	}
+	return fmt.Sprintf("%d line(s) before the first source comment at line %d", lines, orphan.Line)
+}
+
+// describeConflict summarizes a conflict and what sync did about it
+func describeConflict(conflict SyncConflict, mode string) string {
+	lines := make([]string, len(conflict.Conflicts))
+	for i, c := range conflict.Conflicts {
+		lines[i] = fmt.Sprint(c.Line)
+	}
+	msg := fmt.Sprintf("%d hunk(s) changed in both the partial and the target (base line %s); ",
+		len(conflict.Conflicts), strings.Join(lines, ", "))
+	switch mode {
+	case ConflictOurs:
+		return msg + "kept the partial's version"
+	case ConflictTheirs:
+		return msg + "took the target's version"
+	case ConflictMarkers:
+		return msg + "wrote conflict markers into the partial"
+	}
+	return msg + "partial left unchanged; resolve with --ours, --theirs or --markers"
+}
+
+// managedContent returns the part of a target file that parts manages: the
+// PARTIALS section in merge mode (without its end flag, so the flag's comment
+// lines are not read as content) or the whole file in own mode. Broken
+// markers are returned as a *MarkerError.
+func managedContent(content string, style CommentStyle, mode, sectionID string) (string, bool, error) {
+	if mode != "merge" {
+		return content, true, nil
+	}
+	section, err := parseSection(content, style, sectionID)
+	if err != nil || section == nil {
+		return "", false, err
+	}
+	return content[section.Start:section.BodyEnd], true, nil
+}
+
+// partialContent returns the section as the content of the partial it came
+// from, whose current content is current. Delimited sections are exact. A
+// Source section ends with the newline build added after the partial, so
+// the partial keeps the trailing newlines it has and everything before them
+// comes from the target: an unedited section round-trips byte for byte, but
+// trailing blank lines added or removed in the target are not synced back.
+func (s sourceSection) partialContent(current string) string {
+	if s.Delimited {
+		return s.Content
+	}
+	trailing := len(current) - len(strings.TrimRight(current, "\n"))
+	return strings.TrimRight(s.Content, "\n") + strings.Repeat("\n", trailing)
+}
+
 // normalizeSectionContent trims extra trailing newlines from split artifacts
 // and ensures content ends with exactly one newline.
 func normalizeSectionContent(s string) string {
@@ -151,21 +723,3 @@ func normalizeSectionContent(s string) string {
 	}
 	return s
 }
-
-// buildStartFlag constructs the start marker string for a given comment style
-func buildStartFlag(style CommentStyle) string {
-	if style.End != "" {
-		return fmt.Sprintf("%s\n%s %s\n%s", style.Start, style.Start, PartialStartMarker, style.End)
-	}
-	return fmt.Sprintf("%s %s\n%s %s\n%s %s",
-		style.Start, MarkerSeparator, style.Start, PartialStartMarker, style.Start, MarkerSeparator)
-}
-
-// buildEndFlag constructs the end marker string for a given comment style
-func buildEndFlag(style CommentStyle) string {
-	if style.End != "" {
-		return fmt.Sprintf("%s\n%s %s\n%s", style.Start, style.Start, PartialEndMarker, style.End)
-	}
-	return fmt.Sprintf("%s %s\n%s %s\n%s %s",
-		style.Start, MarkerSeparator, style.Start, PartialEndMarker, style.Start, MarkerSeparator)
-}
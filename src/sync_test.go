@@ -315,3 +315,197 @@ func TestSyncTarget_NoManagedSection(t *testing.T) {
 		t.Errorf("Expected 0 updates when no managed section, got %d", result.UpdatedFiles)
 	}
 }
+
+// targetFixture writes a target holding "# My config" to root/config in fsys
+// and partials (path relative to the partials directory -> content) to root/partials
+func targetFixture(t *testing.T, fsys FS, root string, partials map[string]string) (targetFile, partialsDir string) {
+	t.Helper()
+	targetFile = filepath.Join(root, "config")
+	partialsDir = filepath.Join(root, "partials")
+	files := map[string]string{targetFile: "# My config\n"}
+	for rel, content := range partials {
+		files[filepath.Join(partialsDir, filepath.FromSlash(rel))] = content
+	}
+	memTree(t, fsys, files)
+	return targetFile, partialsDir
+}
+
+// appliedSyncFixture builds a target from partials on disk and records it as
+// applied; tests then edit the target or the partials to set up their case
+func appliedSyncFixture(t *testing.T, partials map[string]string) (targetFile, partialsDir string, state TargetState) {
+	t.Helper()
+	targetFile, partialsDir = targetFixture(t, OSFS{}, t.TempDir(), partials)
+	buildCmd, _ := NewPartialsBuildCommand(targetFile, partialsDir, "#")
+	if err := buildCmd.Run(); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	state, err := CaptureTargetState(TargetConfig{Target: targetFile, Partials: partialsDir, Mode: "merge", Comment: "#"}, "apply")
+	if err != nil {
+		t.Fatalf("Failed to capture state: %v", err)
+	}
+	return targetFile, partialsDir, state
+}
+
+func replaceInFile(t *testing.T, path, old, new string) {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(strings.Replace(string(content), old, new, 1)), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+}
+
+func TestSyncTarget_ThreeWay(t *testing.T) {
+	const partial = "Host work\n    User admin\n    HostName work.example.com\n    Port 22\n"
+
+	tests := []struct {
+		name          string
+		partialEdit   [2]string
+		targetEdit    [2]string
+		mode          string
+		want          string
+		wantStale     bool
+		wantMerged    bool
+		wantConflicts int
+		wantResolved  bool
+	}{
+		{
+			name:        "partial only",
+			partialEdit: [2]string{"Port 22", "Port 2222"},
+			mode:        ConflictRefuse,
+			want:        "Host work\n    User admin\n    HostName work.example.com\n    Port 2222\n",
+			wantStale:   true,
+		},
+		{
+			name:        "separate lines",
+			partialEdit: [2]string{"Port 22", "Port 2222"},
+			targetEdit:  [2]string{"User admin", "User root"},
+			mode:        ConflictRefuse,
+			want:        "Host work\n    User root\n    HostName work.example.com\n    Port 2222\n",
+			wantMerged:  true,
+		},
+		{
+			name:          "same line refused",
+			partialEdit:   [2]string{"User admin", "User ops"},
+			targetEdit:    [2]string{"User admin", "User root"},
+			mode:          ConflictRefuse,
+			want:          "Host work\n    User ops\n    HostName work.example.com\n    Port 22\n",
+			wantConflicts: 1,
+		},
+		{
+			name:          "same line theirs",
+			partialEdit:   [2]string{"User admin", "User ops"},
+			targetEdit:    [2]string{"User admin", "User root"},
+			mode:          ConflictTheirs,
+			want:          "Host work\n    User root\n    HostName work.example.com\n    Port 22\n",
+			wantConflicts: 1,
+			wantResolved:  true,
+		},
+		{
+			name:          "same line markers",
+			partialEdit:   [2]string{"User admin", "User ops"},
+			targetEdit:    [2]string{"User admin", "User root"},
+			mode:          ConflictMarkers,
+			want:          "Host work\n<<<<<<< partial\n    User ops\n=======\n    User root\n>>>>>>> target\n    HostName work.example.com\n    Port 22\n",
+			wantConflicts: 1,
+			wantMerged:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targetFile, partialsDir, state := appliedSyncFixture(t, map[string]string{"work": partial})
+			partialFile := filepath.Join(partialsDir, "work")
+			replaceInFile(t, partialFile, tt.partialEdit[0], tt.partialEdit[1])
+			if tt.targetEdit[0] != "" {
+				replaceInFile(t, targetFile, tt.targetEdit[0], tt.targetEdit[1])
+			}
+
+			command := NewPartialsSyncCommand(targetFile, partialsDir, "#", "merge")
+			command.SetState(&state)
+			if err := command.SetConflictMode(tt.mode); err != nil {
+				t.Fatalf("SetConflictMode failed: %v", err)
+			}
+			result, err := command.Run()
+			if err != nil {
+				t.Fatalf("Sync failed: %v", err)
+			}
+
+			got, _ := os.ReadFile(partialFile)
+			if string(got) != tt.want {
+				t.Errorf("Expected partial %q, got %q", tt.want, got)
+			}
+			if (len(result.Stale) > 0) != tt.wantStale {
+				t.Errorf("Expected stale=%v, got %v", tt.wantStale, result.Stale)
+			}
+			if (len(result.Merged) > 0) != tt.wantMerged {
+				t.Errorf("Expected merged=%v, got %v", tt.wantMerged, result.Merged)
+			}
+			if len(result.Conflicts) != tt.wantConflicts {
+				t.Fatalf("Expected %d conflict(s), got %v", tt.wantConflicts, result.Conflicts)
+			}
+			if tt.wantConflicts > 0 && (len(result.Unresolved()) == 0) != tt.wantResolved {
+				t.Errorf("Expected resolved=%v, got %v", tt.wantResolved, result.Unresolved())
+			}
+			if !result.NeedsApply() {
+				t.Error("Expected the target to still need an apply")
+			}
+		})
+	}
+}
+
+func TestSyncTarget_StateWithUneditedPartialTakesTarget(t *testing.T) {
+	targetFile, partialsDir, state := appliedSyncFixture(t, map[string]string{"work": "Host work\n    User admin\n"})
+	replaceInFile(t, targetFile, "User admin", "User root")
+
+	command := NewPartialsSyncCommand(targetFile, partialsDir, "#", "merge")
+	command.SetState(&state)
+	result, err := command.Run()
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if result.UpdatedFiles != 1 || result.NeedsApply() {
+		t.Errorf("Expected a plain update, got %+v", result)
+	}
+	got, _ := os.ReadFile(filepath.Join(partialsDir, "work"))
+	if string(got) != "Host work\n    User root\n" {
+		t.Errorf("Unexpected partial: %q", got)
+	}
+}
+
+func TestSyncTarget_KeepsEndingsAndMode(t *testing.T) {
+	dir := t.TempDir()
+	partialsDir := filepath.Join(dir, "partials")
+	writeTree(t, partialsDir, map[string]string{"a": "Host a", "b": "Host b\n\n"})
+	if err := os.Chmod(filepath.Join(partialsDir, "a"), 0600); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	target := filepath.Join(dir, "config")
+	if err := os.WriteFile(target, []byte("# mine\n"), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	build, _ := NewPartialsBuildCommand(target, partialsDir, "#")
+	if err := build.Run(); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	content, _ := os.ReadFile(target)
+	edited := strings.Replace(strings.Replace(string(content), "Host a", "Host a2", 1), "Host b", "Host b2", 1)
+	if err := os.WriteFile(target, []byte(edited), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if _, err := SyncTarget(target, partialsDir, "#", "merge", false); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	a, _ := os.ReadFile(filepath.Join(partialsDir, "a"))
+	b, _ := os.ReadFile(filepath.Join(partialsDir, "b"))
+	if string(a) != "Host a2" || string(b) != "Host b2\n\n" {
+		t.Errorf("Expected the partials' endings to be kept, got %q and %q", a, b)
+	}
+	if info, err := os.Stat(filepath.Join(partialsDir, "a")); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600 to be kept, got %v (%v)", info, err)
+	}
+}
@@ -0,0 +1,97 @@
+package src
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/user"
+	"runtime"
+	"strings"
+	"text/template"
+)
+
+// Facts describes the machine partials are rendered on
+type Facts struct {
+	Hostname string
+	OS       string
+	Arch     string
+	User     string
+	Home     string
+}
+
+// TemplateData is the data a templated partial is rendered with:
+// {{ .Vars.email }}, {{ .Env.HOME }}, {{ .Facts.Hostname }}
+type TemplateData struct {
+	Vars  map[string]interface{}
+	Env   map[string]string
+	Facts Facts
+}
+
+// NewTemplateData collects the environment and machine facts alongside vars
+func NewTemplateData(vars map[string]interface{}) TemplateData {
+	if vars == nil {
+		vars = map[string]interface{}{}
+	}
+	return TemplateData{
+		Vars:  vars,
+		Env:   environMap(),
+		Facts: CurrentFacts(),
+	}
+}
+
+// environMap returns the process environment as a map
+func environMap() map[string]string {
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		if i := strings.IndexByte(kv, '='); i > 0 {
+			env[kv[:i]] = kv[i+1:]
+		}
+	}
+	return env
+}
+
+// CurrentFacts gathers facts about this machine; unavailable facts are left empty
+func CurrentFacts() Facts {
+	facts := Facts{OS: runtime.GOOS, Arch: runtime.GOARCH}
+	if hostname, err := os.Hostname(); err == nil {
+		facts.Hostname = hostname
+	}
+	if current, err := user.Current(); err == nil {
+		facts.User = current.Username
+		facts.Home = current.HomeDir
+	} else {
+		facts.User = os.Getenv("USER")
+	}
+	if facts.Home == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			facts.Home = home
+		}
+	}
+	return facts
+}
+
+// RenderTemplate renders content as a text/template named after the partial.
+// Referencing a missing var or environment variable is an error.
+func RenderTemplate(name string, content []byte, data TemplateData) ([]byte, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template '%s': %w", name, err)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return nil, fmt.Errorf("failed to render template '%s': %w", name, err)
+	}
+	return out.Bytes(), nil
+}
+
+// readPartial reads a partial file, rendering it when data is non-nil
+func readPartial(fsys FS, path string, data *TemplateData) ([]byte, error) {
+	content, err := fsys.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read partial file '%s': %w", path, err)
+	}
+	if data == nil {
+		return content, nil
+	}
+	return RenderTemplate(path, content, *data)
+}
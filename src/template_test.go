@@ -0,0 +1,82 @@
+package src
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	t.Setenv("PARTS_TEST_KEY", "~/.ssh/id_work")
+	data := NewTemplateData(map[string]interface{}{
+		"user": "alice",
+		"git":  map[string]interface{}{"email": "alice@example.com"},
+	})
+
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{"var", "User {{ .Vars.user }}\n", "User alice\n"},
+		{"nested var", "email = {{ .Vars.git.email }}\n", "email = alice@example.com\n"},
+		{"env", "IdentityFile {{ .Env.PARTS_TEST_KEY }}\n", "IdentityFile ~/.ssh/id_work\n"},
+		{"facts", "{{ .Facts.OS }}/{{ .Facts.Arch }}", runtime.GOOS + "/" + runtime.GOARCH},
+		{"plain", "Host *\n", "Host *\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RenderTemplate("partial", []byte(tt.content), data)
+			if err != nil {
+				t.Fatalf("RenderTemplate failed: %v", err)
+			}
+			if string(got) != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRenderTemplate_Errors(t *testing.T) {
+	data := NewTemplateData(map[string]interface{}{"user": "alice"})
+
+	for _, content := range []string{
+		"{{ .Vars.missing }}",
+		"{{ .Env.PARTS_SURELY_UNSET_VARIABLE }}",
+		"{{ .Facts.Nope }}",
+		"{{ .Vars.user",
+	} {
+		if _, err := RenderTemplate("partial", []byte(content), data); err == nil {
+			t.Errorf("Expected error rendering %q", content)
+		}
+	}
+}
+
+func TestPartialsBuildCommand_Template(t *testing.T) {
+	dir := t.TempDir()
+	partialsDir := filepath.Join(dir, "ssh")
+	writeTree(t, partialsDir, map[string]string{"work": "Host work\n    User {{ .Vars.user }}\n"})
+	target := filepath.Join(dir, "config")
+	if err := os.WriteFile(target, []byte(""), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	command, _ := NewPartialsBuildCommand(target, partialsDir, "#")
+	data := NewTemplateData(map[string]interface{}{"user": "alice"})
+	command.SetTemplateData(&data)
+	change, err := command.Plan()
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if !strings.Contains(string(change.Content), "    User alice\n") {
+		t.Errorf("Expected rendered partial, got:\n%s", change.Content)
+	}
+
+	empty := NewTemplateData(nil)
+	command.SetTemplateData(&empty)
+	if _, err := command.Plan(); err == nil || !strings.Contains(err.Error(), "failed to render template") {
+		t.Errorf("Expected missing var error, got %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package src
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"os/user"
 	"path/filepath"
@@ -60,3 +61,11 @@ func resolveHomeDir() (string, error) {
 	}
 	return usr.HomeDir, nil
 }
+
+// messageOutput returns w, or stdout if no output was set
+func messageOutput(w io.Writer) io.Writer {
+	if w == nil {
+		return os.Stdout
+	}
+	return w
+}
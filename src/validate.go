@@ -0,0 +1,341 @@
+package src
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Built-in validators accepted by ParseValidator
+const (
+	ValidateYAML  = "yaml"
+	ValidateJSON  = "json"
+	ValidateSSH   = "ssh"
+	ValidateHosts = "hosts"
+)
+
+// ValidatorPlaceholder is replaced with the rendered file's path in a validator command
+const ValidatorPlaceholder = "{}"
+
+// DefaultValidatorTimeout bounds how long a validator command may run
+const DefaultValidatorTimeout = 30 * time.Second
+
+// builtinValidators check rendered content; they return one message per problem
+var builtinValidators = map[string]func(content []byte) []string{
+	ValidateYAML:  validateYAML,
+	ValidateJSON:  validateJSON,
+	ValidateSSH:   validateSSHConfig,
+	ValidateHosts: validateHosts,
+}
+
+// Validator checks rendered content before it is written: either a built-in
+// syntax check or an external command run on a temporary copy of the file
+type Validator struct {
+	Builtin string
+	Command string
+}
+
+// ValidationError reports rendered content a validator rejected
+type ValidationError struct {
+	Path      string
+	Validator string
+	Output    string
+}
+
+func (e *ValidationError) Error() string {
+	msg := fmt.Sprintf("validation of '%s' failed (%s)", e.Path, e.Validator)
+	if output := strings.TrimRight(e.Output, "\n"); output != "" {
+		msg += ":\n" + output
+	}
+	return msg
+}
+
+// ParseValidator parses a validate setting: the name of a built-in validator
+// (yaml, json, ssh, hosts) or a command in which {} is replaced with the path
+// of a temporary copy of the rendered file. A command with arguments but no
+// {} gets the path appended.
+// An empty spec returns nil, which accepts everything.
+func ParseValidator(spec string) (*Validator, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+	if _, ok := builtinValidators[spec]; ok {
+		return &Validator{Builtin: spec}, nil
+	}
+	// A single word is taken for a misspelt built-in rather than a command
+	if !strings.ContainsAny(spec, " \t") && !strings.Contains(spec, ValidatorPlaceholder) {
+		return nil, fmt.Errorf("unknown validator '%s' (built-in validators: %s; or a command such as 'sshd -t -f {}')",
+			spec, strings.Join(builtinValidatorNames(), ", "))
+	}
+	return &Validator{Command: spec}, nil
+}
+
+// builtinValidatorNames returns the built-in validator names in sorted order
+func builtinValidatorNames() []string {
+	names := make([]string, 0, len(builtinValidators))
+	for name := range builtinValidators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// String returns the validator as written in the manifest
+func (v *Validator) String() string {
+	if v.Builtin != "" {
+		return v.Builtin
+	}
+	return v.Command
+}
+
+// Validate checks content rendered for path. Returns a *ValidationError with
+// the validator's output if the content is rejected. A nil validator accepts
+// everything.
+func (v *Validator) Validate(path string, content []byte) error {
+	if v == nil {
+		return nil
+	}
+	if v.Builtin != "" {
+		problems := builtinValidators[v.Builtin](content)
+		if len(problems) == 0 {
+			return nil
+		}
+		return &ValidationError{Path: path, Validator: v.Builtin, Output: strings.Join(problems, "\n")}
+	}
+	return v.runCommand(path, content)
+}
+
+// runCommand writes content to a temporary file named like path and runs the
+// validator command on it; a non-zero exit rejects the content
+func (v *Validator) runCommand(path string, content []byte) error {
+	tmp, err := os.CreateTemp("", "parts-validate-*-"+filepath.Base(path))
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file for validation: %w", err)
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName)
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temporary file '%s': %w", tmpName, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temporary file '%s': %w", tmpName, err)
+	}
+
+	command := v.Command
+	if strings.Contains(command, ValidatorPlaceholder) {
+		command = strings.ReplaceAll(command, ValidatorPlaceholder, ShellQuote(tmpName))
+	} else {
+		command += " " + ShellQuote(tmpName)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultValidatorTimeout)
+	defer cancel()
+	output, err := ShellCommand(ctx, command).CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return &ValidationError{Path: path, Validator: v.Command, Output: fmt.Sprintf("timed out after %s", DefaultValidatorTimeout)}
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return &ValidationError{Path: path, Validator: v.Command, Output: string(output)}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to run validator '%s': %w", v.Command, err)
+	}
+	return nil
+}
+
+// ValidateChange validates a planned change before it is written. Unchanged
+// files are not validated, since nothing would be written.
+func ValidateChange(change *FileChange, validator *Validator) error {
+	if validator == nil || !change.Changed() {
+		return nil
+	}
+	return validator.Validate(change.Path, change.Content)
+}
+
+// ShellCommand runs command through the platform shell
+func ShellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "/bin/sh", "-c", command)
+}
+
+// ShellQuote quotes s as a single word for the platform shell
+func ShellQuote(s string) string {
+	if runtime.GOOS == "windows" {
+		return `"` + s + `"`
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// validateYAML parses every document in content
+func validateYAML(content []byte) []string {
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		var doc interface{}
+		err := decoder.Decode(&doc)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return []string{err.Error()}
+		}
+	}
+}
+
+// validateJSON parses content as a single JSON value
+func validateJSON(content []byte) []string {
+	var value interface{}
+	err := json.Unmarshal(content, &value)
+	if err == nil {
+		return nil
+	}
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		line := 1 + bytes.Count(content[:syntaxErr.Offset], []byte("\n"))
+		return []string{fmt.Sprintf("line %d: %v", line, err)}
+	}
+	return []string{err.Error()}
+}
+
+// sshKeywords lists the ssh_config(5) keywords, lowercased
+var sshKeywords = stringSet(
+	"host", "match", "include",
+	"addkeystoagent", "addressfamily", "batchmode", "bindaddress", "bindinterface",
+	"canonicaldomains", "canonicalizefallbacklocal", "canonicalizehostname",
+	"canonicalizemaxdots", "canonicalizepermittedcnames", "casignaturealgorithms",
+	"certificatefile", "channeltimeout", "checkhostip", "ciphers", "clearallforwardings",
+	"compression", "connectionattempts", "connecttimeout", "controlmaster",
+	"controlpath", "controlpersist", "dynamicforward", "enableescapecommandline",
+	"enablesshkeysign", "escapechar", "exitonforwardfailure", "fingerprinthash",
+	"forkafterauthentication", "forwardagent", "forwardx11", "forwardx11timeout",
+	"forwardx11trusted", "gatewayports", "globalknownhostsfile",
+	"gssapiauthentication", "gssapidelegatecredentials", "hashknownhosts",
+	"hostbasedacceptedalgorithms", "hostbasedauthentication", "hostkeyalgorithms",
+	"hostkeyalias", "hostname", "identitiesonly", "identityagent", "identityfile",
+	"ignoreunknown", "ipqos", "kbdinteractiveauthentication", "kbdinteractivedevices",
+	"kexalgorithms", "knownhostscommand", "localcommand", "localforward", "loglevel",
+	"logverbose", "macs", "nohostauthenticationforlocalhost", "numberofpasswordprompts",
+	"obscurekeystroketiming", "passwordauthentication", "permitlocalcommand",
+	"permitremoteopen", "pkcs11provider", "port", "preferredauthentications",
+	"proxycommand", "proxyjump", "proxyusefdpass", "pubkeyacceptedalgorithms",
+	"pubkeyacceptedkeytypes", "pubkeyauthentication", "rekeylimit", "remotecommand",
+	"remoteforward", "requesttty", "requiredrsasize", "revokedhostkeys",
+	"securitykeyprovider", "sendenv", "serveralivecountmax", "serveraliveinterval",
+	"sessiontype", "setenv", "stdinnull", "streamlocalbindmask",
+	"streamlocalbindunlink", "stricthostkeychecking", "syslogfacility", "tag",
+	"tcpkeepalive", "tunnel", "tunneldevice", "updatehostkeys", "useblacklistedkeys",
+	"user", "userknownhostsfile", "verifyhostkeydns", "visualhostkey", "xauthlocation",
+)
+
+// validateSSHConfig checks that every line of an ssh config is a known
+// keyword followed by a value
+func validateSSHConfig(content []byte) []string {
+	var problems []string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// Keyword and value are separated by whitespace or a single '='
+		keyword := line
+		value := ""
+		if i := strings.IndexAny(line, " \t="); i >= 0 {
+			keyword = line[:i]
+			value = strings.TrimSpace(line[i:])
+			value = strings.TrimSpace(strings.TrimPrefix(value, "="))
+		}
+		if !sshKeywords[strings.ToLower(keyword)] {
+			problems = append(problems, fmt.Sprintf("line %d: unknown keyword '%s'", lineNum, keyword))
+			continue
+		}
+		if value == "" {
+			problems = append(problems, fmt.Sprintf("line %d: '%s' has no value", lineNum, keyword))
+		}
+	}
+	return problems
+}
+
+// validateHosts checks that every line of a hosts file is an IP address
+// followed by at least one valid hostname
+func validateHosts(content []byte) []string {
+	var problems []string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		// Link-local IPv6 addresses may carry a zone (fe80::1%lo0)
+		addr := fields[0]
+		if i := strings.Index(addr, "%"); i >= 0 && strings.Contains(addr, ":") {
+			addr = addr[:i]
+		}
+		if net.ParseIP(addr) == nil {
+			problems = append(problems, fmt.Sprintf("line %d: invalid IP address '%s'", lineNum, fields[0]))
+			continue
+		}
+		if len(fields) == 1 {
+			problems = append(problems, fmt.Sprintf("line %d: no hostname for '%s'", lineNum, fields[0]))
+			continue
+		}
+		for _, host := range fields[1:] {
+			if !validHostname(host) {
+				problems = append(problems, fmt.Sprintf("line %d: invalid hostname '%s'", lineNum, host))
+			}
+		}
+	}
+	return problems
+}
+
+// validHostname reports whether name is a syntactically valid hostname.
+// Underscores are tolerated, as resolvers accept them in practice.
+func validHostname(name string) bool {
+	name = strings.TrimSuffix(name, ".")
+	if name == "" || len(name) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, r := range label {
+			isAlnum := (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+			if !isAlnum && r != '-' && r != '_' {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// stringSet builds a lookup table from values
+func stringSet(values ...string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
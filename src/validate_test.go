@@ -0,0 +1,114 @@
+package src
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseValidator(t *testing.T) {
+	if v, err := ParseValidator(""); v != nil || err != nil {
+		t.Errorf("Expected no validator for an empty spec, got %v, %v", v, err)
+	}
+	if v, err := ParseValidator("ssh"); err != nil || v.Builtin != ValidateSSH {
+		t.Errorf("Expected the built-in ssh validator, got %+v, %v", v, err)
+	}
+	if v, err := ParseValidator("sshd -t -f {}"); err != nil || v.Command != "sshd -t -f {}" {
+		t.Errorf("Expected a command validator, got %+v, %v", v, err)
+	}
+	if _, err := ParseValidator("yml"); err == nil || !strings.Contains(err.Error(), "unknown validator 'yml'") {
+		t.Errorf("Expected an unknown validator error, got %v", err)
+	}
+}
+
+func TestBuiltinValidators(t *testing.T) {
+	tests := []struct {
+		validator string
+		content   string
+		problem   string // "" if the content is valid
+	}{
+		{"yaml", "a: 1\n---\nb: [1, 2]\n", ""},
+		{"yaml", "a: [1, 2\n", "did not find expected"},
+		{"json", "{\"a\": [1, 2]}\n", ""},
+		{"json", "{\n  \"a\": 1,\n}\n", "line 3:"},
+		{"ssh", "# comment\nHost work\n  HostName work.example.com\n  Port=2222\n", ""},
+		{"ssh", "Host work\n  Hots work.example.com\n", "line 2: unknown keyword 'Hots'"},
+		{"ssh", "Host work\n  User\n", "line 2: 'User' has no value"},
+		{"hosts", "127.0.0.1 localhost # loopback\n::1 localhost ip6-localhost\nfe80::1%lo0 localhost\n", ""},
+		{"hosts", "127.0.0.300 bad\n", "line 1: invalid IP address '127.0.0.300'"},
+		{"hosts", "10.0.0.1\n", "line 1: no hostname for '10.0.0.1'"},
+		{"hosts", "10.0.0.1 good -bad- bad!host\n", "invalid hostname '-bad-'"},
+	}
+
+	for _, tt := range tests {
+		validator, err := ParseValidator(tt.validator)
+		if err != nil {
+			t.Fatalf("ParseValidator(%s) failed: %v", tt.validator, err)
+		}
+		err = validator.Validate("/tmp/file", []byte(tt.content))
+		if tt.problem == "" {
+			if err != nil {
+				t.Errorf("%s: expected %q to be valid, got %v", tt.validator, tt.content, err)
+			}
+			continue
+		}
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) || !strings.Contains(validationErr.Output, tt.problem) {
+			t.Errorf("%s: expected %q in the error for %q, got %v", tt.validator, tt.problem, tt.content, err)
+		}
+	}
+}
+
+func TestCommandValidator(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("requires /bin/sh")
+	}
+	validator, err := ParseValidator("grep -q '^ok' {} || { echo \"rejected $(basename {})\"; exit 1; }")
+	if err != nil {
+		t.Fatalf("ParseValidator failed: %v", err)
+	}
+	if err := validator.Validate("/etc/app.conf", []byte("ok\n")); err != nil {
+		t.Errorf("Expected the content to pass, got %v", err)
+	}
+	err = validator.Validate("/etc/app.conf", []byte("bad\n"))
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || !strings.Contains(validationErr.Output, "rejected parts-validate-") ||
+		!strings.HasSuffix(strings.TrimSpace(validationErr.Output), "-app.conf") {
+		t.Errorf("Expected the command's output in the error, got %v", err)
+	}
+
+	// Without {} the file is appended to the command
+	appended, _ := ParseValidator("grep -q ok")
+	if err := appended.Validate("/etc/app.conf", []byte("ok\n")); err != nil {
+		t.Errorf("Expected the appended path to be checked, got %v", err)
+	}
+}
+
+func TestPartialsBuildCommand_ValidationBlocksWrite(t *testing.T) {
+	dir := t.TempDir()
+	partialsDir := filepath.Join(dir, "hosts.d")
+	writeTree(t, partialsDir, map[string]string{"dev": "10.0.0.1 dev.local\nnot-an-ip dev2\n"})
+	target := filepath.Join(dir, "hosts")
+	original := "127.0.0.1 localhost\n"
+	if err := os.WriteFile(target, []byte(original), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+
+	build, _ := NewPartialsBuildCommand(target, partialsDir, "#")
+	validator, _ := ParseValidator("hosts")
+	build.SetValidator(validator)
+	err := build.Run()
+	if err == nil || !strings.Contains(err.Error(), "invalid IP address 'not-an-ip'") {
+		t.Errorf("Expected a validation error, got %v", err)
+	}
+	if content, _ := os.ReadFile(target); string(content) != original {
+		t.Errorf("Expected the target to be left alone, got:\n%s", content)
+	}
+
+	writeTree(t, partialsDir, map[string]string{"dev": "10.0.0.1 dev.local\n"})
+	if err := build.Run(); err != nil {
+		t.Errorf("Expected valid content to be written, got %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+package src
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Default polling settings for Watcher
+const (
+	DefaultWatchInterval = 500 * time.Millisecond
+	DefaultWatchDebounce = 300 * time.Millisecond
+)
+
+// FileStamp identifies a version of a file for change detection
+type FileStamp struct {
+	ModTime time.Time
+	Size    int64
+	Mode    fs.FileMode
+}
+
+// Snapshot maps every file under a set of watched paths to its stamp
+type Snapshot map[string]FileStamp
+
+// TakeSnapshot stamps each path and, for directories, every file beneath it.
+// Paths that don't exist are left out, so creating them later is a change.
+func TakeSnapshot(paths ...string) (Snapshot, error) {
+	snapshot := Snapshot{}
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			info, err := d.Info()
+			if os.IsNotExist(err) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			snapshot[path] = FileStamp{ModTime: info.ModTime(), Size: info.Size(), Mode: info.Mode()}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan '%s': %w", root, err)
+		}
+	}
+	return snapshot, nil
+}
+
+// Equal reports whether two snapshots stamp the same files identically
+func (s Snapshot) Equal(other Snapshot) bool {
+	if len(s) != len(other) {
+		return false
+	}
+	for path, stamp := range s {
+		otherStamp, ok := other[path]
+		if !ok || !stamp.ModTime.Equal(otherStamp.ModTime) || stamp.Size != otherStamp.Size || stamp.Mode != otherStamp.Mode {
+			return false
+		}
+	}
+	return true
+}
+
+// Watcher polls named groups of paths and reports a group once its files
+// changed and then stayed unchanged for the debounce period, so a burst of
+// editor writes triggers a single rebuild. It needs no OS notification API.
+type Watcher struct {
+	debounce  time.Duration
+	paths     map[string][]string
+	snapshots map[string]Snapshot
+	pending   map[string]time.Time
+}
+
+// NewWatcher creates a watcher with the given debounce period
+func NewWatcher(debounce time.Duration) *Watcher {
+	return &Watcher{
+		debounce:  debounce,
+		paths:     map[string][]string{},
+		snapshots: map[string]Snapshot{},
+		pending:   map[string]time.Time{},
+	}
+}
+
+// Watch starts watching a group of paths under name, replacing any previous
+// group of that name. The current state of the files is the baseline.
+func (w *Watcher) Watch(name string, paths ...string) error {
+	snapshot, err := TakeSnapshot(paths...)
+	if err != nil {
+		return err
+	}
+	w.paths[name] = paths
+	w.snapshots[name] = snapshot
+	delete(w.pending, name)
+	return nil
+}
+
+// Unwatch stops watching a group
+func (w *Watcher) Unwatch(name string) {
+	delete(w.paths, name)
+	delete(w.snapshots, name)
+	delete(w.pending, name)
+}
+
+// Names returns the watched group names in sorted order
+func (w *Watcher) Names() []string {
+	names := make([]string, 0, len(w.paths))
+	for name := range w.paths {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Poll rescans every group and returns, in sorted order, the groups that
+// changed and have been quiet for the debounce period as of now. Groups that
+// can't be scanned are reported in the error and checked again next poll.
+func (w *Watcher) Poll(now time.Time) ([]string, error) {
+	var ready []string
+	var firstErr error
+	for _, name := range w.Names() {
+		snapshot, err := TakeSnapshot(w.paths[name]...)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if !snapshot.Equal(w.snapshots[name]) {
+			w.snapshots[name] = snapshot
+			w.pending[name] = now
+			continue
+		}
+		if changed, ok := w.pending[name]; ok && now.Sub(changed) >= w.debounce {
+			delete(w.pending, name)
+			ready = append(ready, name)
+		}
+	}
+	return ready, firstErr
+}
@@ -0,0 +1,86 @@
+package src
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTakeSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"a": "1\n", "sub/b": "2\n"})
+
+	snapshot, err := TakeSnapshot(dir, filepath.Join(dir, "missing"))
+	if err != nil {
+		t.Fatalf("TakeSnapshot failed: %v", err)
+	}
+	for _, path := range []string{dir, filepath.Join(dir, "a"), filepath.Join(dir, "sub", "b")} {
+		if _, ok := snapshot[path]; !ok {
+			t.Errorf("Expected %s in snapshot", path)
+		}
+	}
+
+	same, _ := TakeSnapshot(dir, filepath.Join(dir, "missing"))
+	if !snapshot.Equal(same) {
+		t.Error("Expected identical snapshots to be equal")
+	}
+	writeTree(t, dir, map[string]string{"missing": "now here\n"})
+	grown, _ := TakeSnapshot(dir, filepath.Join(dir, "missing"))
+	if snapshot.Equal(grown) {
+		t.Error("Expected a created file to change the snapshot")
+	}
+}
+
+func TestWatcher_Poll(t *testing.T) {
+	dir := t.TempDir()
+	sshDir := filepath.Join(dir, "ssh")
+	gitDir := filepath.Join(dir, "git")
+	writeTree(t, dir, map[string]string{"ssh/work": "Host work\n", "git/user": "[user]\n"})
+
+	watcher := NewWatcher(time.Second)
+	if err := watcher.Watch("ssh", sshDir); err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	if err := watcher.Watch("git", gitDir); err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+
+	start := time.Now()
+	if ready, err := watcher.Poll(start); err != nil || len(ready) != 0 {
+		t.Fatalf("Expected nothing to rebuild, got %v (%v)", ready, err)
+	}
+
+	// A burst of writes is reported once, after the debounce period
+	writeTree(t, dir, map[string]string{"ssh/work": "Host work2\n"})
+	if ready, _ := watcher.Poll(start.Add(100 * time.Millisecond)); len(ready) != 0 {
+		t.Errorf("Expected the change to be debounced, got %v", ready)
+	}
+	writeTree(t, dir, map[string]string{"ssh/new": "Host new\n"})
+	if ready, _ := watcher.Poll(start.Add(900 * time.Millisecond)); len(ready) != 0 {
+		t.Errorf("Expected the second write to restart the debounce, got %v", ready)
+	}
+	if ready, _ := watcher.Poll(start.Add(1500 * time.Millisecond)); len(ready) != 0 {
+		t.Errorf("Expected to wait a full debounce period, got %v", ready)
+	}
+	ready, _ := watcher.Poll(start.Add(2 * time.Second))
+	if len(ready) != 1 || ready[0] != "ssh" {
+		t.Errorf("Expected only ssh to be ready, got %v", ready)
+	}
+	if ready, _ := watcher.Poll(start.Add(5 * time.Second)); len(ready) != 0 {
+		t.Errorf("Expected a change to be reported once, got %v", ready)
+	}
+
+	// Removed directories are a change; unwatched groups are not reported
+	if err := os.RemoveAll(gitDir); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	watcher.Poll(start.Add(6 * time.Second))
+	watcher.Unwatch("git")
+	if ready, _ := watcher.Poll(start.Add(10 * time.Second)); len(ready) != 0 {
+		t.Errorf("Expected unwatched group to be dropped, got %v", ready)
+	}
+	if names := watcher.Names(); len(names) != 1 || names[0] != "ssh" {
+		t.Errorf("Unexpected names: %v", names)
+	}
+}
@@ -0,0 +1,189 @@
+package src
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// WhenSuffix marks a sidecar file holding the condition of the partial it is
+// named after: "work.when" applies to the partial "work". Sidecars are never
+// merged themselves.
+const WhenSuffix = ".when"
+
+// Patterns is a list of glob patterns that may be written in YAML as a
+// single string or a sequence; it matches when any pattern matches
+type Patterns []string
+
+// UnmarshalYAML accepts a scalar or a sequence of scalars
+func (p *Patterns) UnmarshalYAML(value *yaml.Node) error {
+	switch value.Kind {
+	case yaml.ScalarNode:
+		*p = Patterns{value.Value}
+		return nil
+	case yaml.SequenceNode:
+		var list []string
+		if err := value.Decode(&list); err != nil {
+			return err
+		}
+		*p = list
+		return nil
+	}
+	return fmt.Errorf("line %d: expected a string or a list of strings", value.Line)
+}
+
+// match reports whether value matches any pattern; an empty list matches anything
+func (p Patterns) match(value string) bool {
+	if len(p) == 0 {
+		return true
+	}
+	for _, pattern := range p {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// When restricts a target or partial to matching machines. Every condition
+// that is set must hold; a condition with several values holds if any does.
+// Host, OS and User are globs; Env entries are "VAR" (set) or "VAR=value".
+type When struct {
+	Host Patterns `yaml:"host"`
+	OS   Patterns `yaml:"os"`
+	User Patterns `yaml:"user"`
+	Env  Patterns `yaml:"env"`
+}
+
+// Validate checks that every glob is well-formed
+func (w *When) Validate() error {
+	if w == nil {
+		return nil
+	}
+	for _, pattern := range append(append(append([]string{}, w.Host...), w.OS...), w.User...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid when pattern '%s': %w", pattern, err)
+		}
+	}
+	for _, entry := range w.Env {
+		if name := strings.SplitN(entry, "=", 2)[0]; name == "" {
+			return fmt.Errorf("invalid when env '%s': missing variable name", entry)
+		}
+	}
+	return nil
+}
+
+// Match reports whether the condition holds for facts and the current
+// environment. When it doesn't, the reason names the first failing condition.
+// A nil condition always matches.
+func (w *When) Match(facts Facts) (bool, string) {
+	if w == nil {
+		return true, ""
+	}
+	if !w.Host.match(facts.Hostname) {
+		return false, mismatch("host", facts.Hostname, w.Host)
+	}
+	if !w.OS.match(facts.OS) {
+		return false, mismatch("os", facts.OS, w.OS)
+	}
+	if !w.User.match(facts.User) {
+		return false, mismatch("user", facts.User, w.User)
+	}
+	if len(w.Env) > 0 && !envMatch(w.Env) {
+		return false, fmt.Sprintf("env does not satisfy %s", quoteList(w.Env))
+	}
+	return true, ""
+}
+
+// envMatch reports whether any "VAR" entry is set or any "VAR=value" entry equals
+func envMatch(entries Patterns) bool {
+	for _, entry := range entries {
+		parts := strings.SplitN(entry, "=", 2)
+		value, set := os.LookupEnv(parts[0])
+		if set && (len(parts) == 1 || value == parts[1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// mismatch describes a failed glob condition
+func mismatch(field, value string, patterns Patterns) string {
+	return fmt.Sprintf("%s '%s' does not match %s", field, value, quoteList(patterns))
+}
+
+// quoteList formats values as 'a' or 'b'
+func quoteList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = "'" + v + "'"
+	}
+	return strings.Join(quoted, " or ")
+}
+
+// SkippedPartial is a partial left out because its condition doesn't hold
+type SkippedPartial struct {
+	Partial
+	Reason string
+}
+
+// loadPartialCondition reads the .when sidecar of a partial, or returns nil if it has none
+func loadPartialCondition(fsys FS, partial Partial) (*When, error) {
+	sidecar := partial.Path + WhenSuffix
+	data, err := fsys.ReadFile(sidecar)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read condition '%s': %w", sidecar, err)
+	}
+
+	var when When
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&when); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse condition '%s': %w", sidecar, err)
+	}
+	if err := when.Validate(); err != nil {
+		return nil, fmt.Errorf("condition '%s': %w", sidecar, err)
+	}
+	return &when, nil
+}
+
+// FilterPartials drops partials whose .when sidecar doesn't hold for facts,
+// returning the kept partials and the skipped ones with their reasons
+func FilterPartials(partials []Partial, facts Facts) ([]Partial, []SkippedPartial, error) {
+	return filterPartials(OSFS{}, partials, facts)
+}
+
+// filterPartials is FilterPartials reading sidecars from fsys
+func filterPartials(fsys FS, partials []Partial, facts Facts) ([]Partial, []SkippedPartial, error) {
+	var kept []Partial
+	var skipped []SkippedPartial
+	for _, partial := range partials {
+		when, err := loadPartialCondition(fsys, partial)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok, reason := when.Match(facts); !ok {
+			skipped = append(skipped, SkippedPartial{Partial: partial, Reason: reason})
+			continue
+		}
+		kept = append(kept, partial)
+	}
+	return kept, skipped, nil
+}
+
+// printSkippedPartials reports partials left out of a dry-run preview
+func printSkippedPartials(out io.Writer, skipped []SkippedPartial) {
+	for _, partial := range skipped {
+		fmt.Fprintf(out, "Skipping partial '%s': %s\n", partial.Path, partial.Reason)
+	}
+}
@@ -0,0 +1,102 @@
+package src
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestWhen_Match(t *testing.T) {
+	t.Setenv("PARTS_TEST_ROLE", "ci")
+	facts := Facts{Hostname: "dev-vm-01", OS: "linux", User: "alice"}
+
+	tests := []struct {
+		name   string
+		when   *When
+		match  bool
+		reason string
+	}{
+		{"nil", nil, true, ""},
+		{"empty", &When{}, true, ""},
+		{"host glob", &When{Host: Patterns{"dev-*"}}, true, ""},
+		{"host mismatch", &When{Host: Patterns{"prod-*", "db-?"}}, false, "host 'dev-vm-01' does not match 'prod-*' or 'db-?'"},
+		{"os any of", &When{OS: Patterns{"darwin", "linux"}}, true, ""},
+		{"os mismatch", &When{OS: Patterns{"windows"}}, false, "os 'linux' does not match 'windows'"},
+		{"user", &When{User: Patterns{"alice"}}, true, ""},
+		{"env set", &When{Env: Patterns{"PARTS_TEST_ROLE"}}, true, ""},
+		{"env equals", &When{Env: Patterns{"PARTS_TEST_ROLE=ci"}}, true, ""},
+		{"env differs", &When{Env: Patterns{"PARTS_TEST_ROLE=dev"}}, false, "env does not satisfy 'PARTS_TEST_ROLE=dev'"},
+		{"env unset", &When{Env: Patterns{"PARTS_TEST_UNSET_VARIABLE"}}, false, "env does not satisfy 'PARTS_TEST_UNSET_VARIABLE'"},
+		{"all must hold", &When{OS: Patterns{"linux"}, User: Patterns{"bob"}}, false, "user 'alice' does not match 'bob'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, reason := tt.when.Match(facts)
+			if match != tt.match || reason != tt.reason {
+				t.Errorf("Expected (%v, %q), got (%v, %q)", tt.match, tt.reason, match, reason)
+			}
+		})
+	}
+}
+
+func TestWhen_UnmarshalAndValidate(t *testing.T) {
+	var when When
+	if err := yaml.Unmarshal([]byte("host: dev-*\nos: [linux, darwin]\n"), &when); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(when.Host, Patterns{"dev-*"}) || !reflect.DeepEqual(when.OS, Patterns{"linux", "darwin"}) {
+		t.Errorf("Unexpected condition: %+v", when)
+	}
+
+	for _, bad := range []When{{Host: Patterns{"[dev"}}, {Env: Patterns{"=value"}}} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Expected %+v to be rejected", bad)
+		}
+	}
+}
+
+func TestFilterPartials_Sidecars(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"base":          "base\n",
+		"linux":         "linux only\n",
+		"linux.when":    "os: linux\n",
+		"windows":       "windows only\n",
+		"windows.when":  "os: windows\n",
+		"empty.when":    "",
+		"empty":         "always\n",
+		"sub/work":      "work\n",
+		"sub/work.when": "host: work-*\n",
+	})
+
+	partials, err := ListPartials(dir, PartialOptions{Recursive: true})
+	if err != nil {
+		t.Fatalf("ListPartials failed: %v", err)
+	}
+	if got := relPaths(partials); !reflect.DeepEqual(got, []string{"base", "empty", "linux", "sub/work", "windows"}) {
+		t.Fatalf("Expected sidecars to be excluded from listing, got %v", got)
+	}
+
+	kept, skipped, err := FilterPartials(partials, Facts{Hostname: "laptop", OS: "linux"})
+	if err != nil {
+		t.Fatalf("FilterPartials failed: %v", err)
+	}
+	if got := relPaths(kept); !reflect.DeepEqual(got, []string{"base", "empty", "linux"}) {
+		t.Errorf("Unexpected kept partials: %v", got)
+	}
+	if len(skipped) != 2 || skipped[0].RelPath != "sub/work" || !strings.Contains(skipped[0].Reason, "host 'laptop'") {
+		t.Errorf("Unexpected skipped partials: %+v", skipped)
+	}
+
+	// Unknown keys in a sidecar are errors rather than silently matching everywhere
+	if err := os.WriteFile(filepath.Join(dir, "base.when"), []byte("hots: dev\n"), 0644); err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	if _, _, err := FilterPartials(partials, Facts{}); err == nil || !strings.Contains(err.Error(), "base.when") {
+		t.Errorf("Expected sidecar parse error, got %v", err)
+	}
+}